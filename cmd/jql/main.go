@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"flag"
-	"fmt"
 	"os"
-	"strings"
 
 	"github.com/kotsmile/jql/internal/engine"
 	"github.com/kotsmile/jql/internal/lexer"
@@ -13,7 +12,7 @@ import (
 	"github.com/kotsmile/jql/util"
 )
 
-func processCmd(cmd string, e *engine.Engine, logger util.Logger) error {
+func processCmd(ctx context.Context, cmd string, e *engine.Engine, logger util.Logger) error {
 	l := lexer.New(logger.WithField("module", "lexer"))
 	l.Lex(cmd)
 
@@ -24,8 +23,16 @@ func processCmd(cmd string, e *engine.Engine, logger util.Logger) error {
 	}
 
 	for _, q := range queries {
-		if err := e.Process(q); err != nil {
-			continue
+		err := e.Process(ctx, q)
+		switch {
+		case errors.Is(err, context.Canceled):
+			// the rest of the line is canceled with the query
+			logger.Errorf("query canceled")
+			return nil
+		case errors.Is(err, context.DeadlineExceeded):
+			logger.Errorf("query timed out")
+		case err != nil:
+			logger.Errorf("failed to execute query: %s", err)
 		}
 	}
 
@@ -39,18 +46,27 @@ func main() {
 	flag.Parse()
 
 	logger := util.NewLogger(debug)
-	e := engine.New(os.Stdout)
 
-	e.LoadTable("./examples/simple.json", "simple")
+	if flag.Arg(0) == "serve" {
+		if err := serve(flag.Args()[1:], logger); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "pg" {
+		if err := pg(flag.Args()[1:], logger); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	e := engine.New(os.Stdout)
 
-	for {
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("> ")
-		cmd, _ := reader.ReadString('\n')
-		cmd = strings.TrimSpace(cmd)
+	e.LoadTable(context.Background(), "./examples/simple.json", "simple")
 
-		if err := processCmd(cmd, e, logger); err != nil {
+	util.Repl(os.Stdin, os.Stdout, "> ", func(ctx context.Context, cmd string) {
+		if err := processCmd(ctx, cmd, e, logger); err != nil {
 			logger.Errorf("failed to execute command: %s", err)
 		}
-	}
+	})
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/internal/pgwire"
+	"github.com/kotsmile/jql/util"
+)
+
+// pg runs 'jql pg [flags] paths...', serving the json files and directories
+// given to postgres clients until interrupted.
+func pg(args []string, logger util.Logger) error {
+	flags := flag.NewFlagSet("pg", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:5432", "address to listen on")
+	flags.Parse(args)
+
+	e := engine.New(io.Discard)
+	for _, path := range flags.Args() {
+		if err := e.LoadPath(context.Background(), path); err != nil {
+			return err
+		}
+	}
+	for _, table := range e.Tables() {
+		logger.Infof("loaded table '%s' with %d rows", table.Name, table.Rows)
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	logger.Infof("serving postgres clients on %s", l.Addr())
+	err = pgwire.New(e, logger.WithField("module", "pgwire")).Serve(l)
+	if ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return fmt.Errorf("failed to serve: %w", err)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kotsmile/jql"
+	"github.com/kotsmile/jql/internal/server"
+	"github.com/kotsmile/jql/util"
+)
+
+// serve runs 'jql serve [flags] paths...', serving the json files and
+// directories given over HTTP until interrupted.
+func serve(args []string, logger util.Logger) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	readOnly := flags.Bool("read-only", false, "reject statements other than queries")
+	timeout := flags.Duration("timeout", 30*time.Second, "bound on the time of a request, 0 for none")
+	flags.Parse(args)
+
+	db, err := jql.Open(flags.Args()...)
+	if err != nil {
+		return err
+	}
+	for _, table := range db.Tables() {
+		logger.Infof("loaded table '%s' with %d rows", table.Name, table.Rows)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(db, server.Options{ReadOnly: *readOnly, Timeout: *timeout}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	logger.Infof("serving on %s", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
@@ -1,25 +1,43 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/kotsmile/jql"
 	"github.com/kotsmile/jql/internal/engine"
 	"github.com/kotsmile/jql/internal/lexer"
 	"github.com/kotsmile/jql/internal/lexer/token"
+	"github.com/kotsmile/jql/internal/loader"
 	"github.com/kotsmile/jql/util"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func processCmd(cmd string, db *sql.DB, logger util.Logger) error {
-	if strings.HasPrefix(cmd, "load") {
+// settings are the options changed by 'set'.
+type settings struct {
+	// bound on the runtime of commands, off when zero
+	timeout time.Duration
+}
+
+func processCmd(ctx context.Context, cmd string, db *sql.DB, s *settings, logger util.Logger) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	if strings.HasPrefix(cmd, "set") {
+		return setCmd(cmd, s, logger)
+	} else if strings.HasPrefix(cmd, "load") {
 		lexer := lexer.New(logger)
 		lexer.Lex(cmd)
 		tokens, err := lexer.Collect()
@@ -47,93 +65,35 @@ func processCmd(cmd string, db *sql.DB, logger util.Logger) error {
 			return fmt.Errorf("table name is not specified")
 		}
 		tableName := tableNameToken.Value()
-		e := engine.New(os.Stdout)
 
-		if err := e.LoadTable(filename, tableName); err != nil {
-			return fmt.Errorf("failed to load table: %s", err)
+		// 'load <file> as <table> flatten' normalizes nested values
+		var options loader.Options
+		if optionToken, ok := util.At(tokens, 4); ok && !optionToken.Is(token.Semicolon) {
+			if !(optionToken.Is(token.Word) && optionToken.Value() == "flatten") {
+				return fmt.Errorf("unknown load option '%s'", optionToken.Value())
+			}
+			options.Flatten = true
 		}
 
-		table, _ := e.GetTable(tableName)
-		typedColumns := table.ToSqliteTypes()
-
-		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("CREATE TABLE \"%s\" (\n", tableName))
-		for i, c := range typedColumns {
-			if i == len(typedColumns)-1 {
-				sb.WriteString(fmt.Sprintf("\t\"%s\" %s\n", c.Name, c.SqliteType))
-			} else {
-				sb.WriteString(fmt.Sprintf("\t\"%s\" %s,\n", c.Name, c.SqliteType))
-			}
+		src, err := jql.Open()
+		if err != nil {
+			return err
 		}
-		sb.WriteString(");")
-		logger.Debugf("creating table:\n%s", sb.String())
-		if _, err = db.Exec(sb.String()); err != nil {
-			return fmt.Errorf("failed to create table:\n%s", err)
+		if err := src.LoadAs(filename, tableName); err != nil {
+			return err
 		}
 
-		for _, row := range table.Rows() {
-			var sb strings.Builder
-			sb.WriteString(fmt.Sprintf("INSERT INTO \"%s\" (\n", tableName))
-			for i, c := range typedColumns {
-				if i == len(typedColumns)-1 {
-					sb.WriteString(fmt.Sprintf("\t\"%s\"\n", c.Name))
-				} else {
-					sb.WriteString(fmt.Sprintf("\t\"%s\",\n", c.Name))
-				}
-			}
-
-			sb.WriteString(") VALUES (\n")
-
-			for i, c := range typedColumns {
-				value, ok := row[c.Name]
-
-				if ok {
-					if c.SqliteType == "TEXT" {
-						valueJSONBytes, err := json.Marshal(value)
-						if err != nil {
-							return fmt.Errorf("failed to marshal value: %s", err)
-						}
-
-						valueJSON := string(valueJSONBytes)
-						valueString, ok := value.(string)
-						if ok {
-							valueJSON = valueString
-						}
-
-						if i == len(typedColumns)-1 {
-							sb.WriteString(fmt.Sprintf("\t'%s'\n", valueJSON))
-						} else {
-							sb.WriteString(fmt.Sprintf("\t'%s',\n", valueJSON))
-						}
-					} else {
-						if i == len(typedColumns)-1 {
-							sb.WriteString(fmt.Sprintf("\t%v\n", value))
-						} else {
-							sb.WriteString(fmt.Sprintf("\t%v,\n", value))
-						}
-					}
-				} else {
-					if i == len(typedColumns)-1 {
-						sb.WriteString("\tNULL\n")
-					} else {
-						sb.WriteString("\tNULL,\n")
-					}
-				}
-			}
-			sb.WriteString(");")
-
-			logger.Debugf("inserting into table:\n%s", sb.String())
-			if _, err = db.Exec(sb.String()); err != nil {
-				return fmt.Errorf("failed to insert into table:\n%s", err)
-			}
+		n, err := loader.Load(ctx, db, src, tableName, options)
+		if err != nil {
+			return fmt.Errorf("failed to copy table: %w", err)
 		}
-
+		logger.Infof("loaded %d rows into '%s'", n, tableName)
 		return nil
 	} else if strings.HasPrefix(cmd, "save") {
 		return fmt.Errorf("save is not implemented yet")
 	}
 
-	rows, err := db.Query(cmd)
+	rows, err := db.QueryContext(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %s", err)
 	}
@@ -211,14 +171,52 @@ func main() {
 	// 	execute("load \"./examples/simple.json\" as simple;", db, logger)
 	// }
 
-	for {
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("> ")
-		cmd, _ := reader.ReadString('\n')
-		cmd = strings.TrimSpace(cmd)
-
-		if err := processCmd(cmd, db, logger); err != nil {
+	s := &settings{}
+	util.Repl(os.Stdin, os.Stdout, "> ", func(ctx context.Context, cmd string) {
+		err := processCmd(ctx, cmd, db, s, logger)
+		switch {
+		case errors.Is(err, context.Canceled):
+			logger.Errorf("command canceled")
+		case errors.Is(err, context.DeadlineExceeded):
+			logger.Errorf("command timed out")
+		case err != nil:
 			logger.Errorf("failed to execute command: %s", err)
 		}
+	})
+}
+
+// setCmd runs 'set timeout <seconds or duration>'.
+func setCmd(cmd string, s *settings, logger util.Logger) error {
+	lexer := lexer.New(logger)
+	lexer.Lex(cmd)
+	tokens, err := lexer.Collect()
+	if err != nil {
+		return fmt.Errorf("failed to tokenize expression: %s", err)
 	}
+
+	optionToken, ok := util.At(tokens, 1)
+	if !ok {
+		return fmt.Errorf("option is not specified")
+	}
+	if optionToken.Value() != engine.TimeoutOption {
+		return fmt.Errorf("unknown option '%s'", optionToken.Value())
+	}
+
+	valueToken, ok := util.At(tokens, 2)
+	if !ok {
+		return fmt.Errorf("timeout is not specified")
+	}
+	var value any = valueToken.Value()
+	if valueToken.Is(token.Number) {
+		if value, err = strconv.ParseFloat(valueToken.Value(), 64); err != nil {
+			return fmt.Errorf("failed to parse timeout: %s", err)
+		}
+	}
+
+	timeout, err := engine.ParseTimeout(value)
+	if err != nil {
+		return err
+	}
+	s.timeout = timeout
+	return nil
 }
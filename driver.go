@@ -0,0 +1,295 @@
+package jql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/engine"
+)
+
+// DriverName is the name the database/sql driver is registered under. The
+// data source name lists json files and directories separated by commas,
+// which are loaded as Open loads them:
+//
+//	db, err := sql.Open("jql", "people.json,data/")
+const DriverName = "jql"
+
+var ErrTxOptions = errors.New("read-only transactions and isolation levels are not supported")
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver. All connections of a sql.DB share the
+// tables loaded for its data source name.
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	var paths []string
+	for _, path := range strings.Split(dsn, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	db, err := Open(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{driver: d, db: db}, nil
+}
+
+type connector struct {
+	driver *Driver
+	db     *DB
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db, session: engine.NewSession()}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return c.driver }
+
+// conn is a session of the database: the transaction it begins is its own.
+type conn struct {
+	db      *DB
+	session *engine.Session
+}
+
+// context returns ctx carrying the session of the connection.
+func (c *conn) context(ctx context.Context) context.Context {
+	return engine.WithSession(ctx, c.session)
+}
+
+var (
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext parses a query, which runs when the statement is executed.
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	prepared, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{conn: c, stmt: prepared}, nil
+}
+
+// Close rolls back the transaction of the connection, if any.
+func (c *conn) Close() error {
+	c.db.engine.EndSession(c.session)
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a transaction with 'begin'. Only one transaction can be
+// open at a time: queries of other connections read the tables from before
+// it, and their other statements wait until it ends.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly || opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, ErrTxOptions
+	}
+	if _, err := c.db.Exec(c.context(ctx), "begin;"); err != nil {
+		return nil, err
+	}
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := argumentValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.db.Query(c.context(ctx), query, values...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values, err := argumentValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := c.db.Exec(c.context(ctx), query, values...)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+// argumentValues orders the arguments of a statement by position. Names
+// are not supported.
+func argumentValues(args []driver.NamedValue) ([]any, error) {
+	values := make([]any, len(args))
+	for _, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("%w: named argument '%s'", ErrArgumentType, arg.Name)
+		}
+		values[arg.Ordinal-1] = arg.Value
+	}
+	return values, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	_, err := t.conn.db.Exec(t.conn.context(context.Background()), "commit;")
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.conn.db.Exec(t.conn.context(context.Background()), "rollback;")
+	return err
+}
+
+// stmt is a prepared statement of a connection.
+type stmt struct {
+	conn *conn
+	stmt *Stmt
+}
+
+var (
+	_ driver.StmtQueryContext = (*stmt)(nil)
+	_ driver.StmtExecContext  = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return s.stmt.NumInput() }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	values, err := argumentValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.stmt.Exec(s.conn.context(ctx), values...)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := argumentValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.stmt.Query(s.conn.context(ctx), values...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return named
+}
+
+// rows passes on the rows of a query as driver values. Arrays and objects
+// are passed on as their json encoding.
+type rows struct {
+	rows *Rows
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*rows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rows)(nil)
+)
+
+func (r *rows) Columns() []string {
+	names := make([]string, 0, len(r.rows.Columns()))
+	for _, column := range r.rows.Columns() {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func (r *rows) Close() error { return r.rows.Close() }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	for i, value := range r.rows.Values() {
+		switch v := value.(type) {
+		case []any, map[string]any:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			dest[i] = string(b)
+		default:
+			dest[i] = v
+		}
+	}
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName names the type of a column in upper case, like
+// "NUMBER" or "TIMESTAMP".
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(string(r.rows.Columns()[index].Type))
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	switch t := r.rows.Columns()[index].Type; t {
+	case ArrayType, ObjectType:
+		return reflect.TypeFor[string]()
+	default:
+		return t.ScanType()
+	}
+}
+
+// ColumnTypeNullable reports every column as nullable, since any value of
+// a json file can be null.
+func (r *rows) ColumnTypeNullable(int) (bool, bool) {
+	return true, true
+}
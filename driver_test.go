@@ -0,0 +1,267 @@
+package jql
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestSQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "people.json"), []byte(peopleJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pets := filepath.Join(t.TempDir(), "pets.json")
+	if err := os.WriteFile(pets, []byte(`[{"owner": 1, "pet": "cat"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(DriverName, dir+", "+pets)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func Test_DriverQuery(t *testing.T) {
+	db := openTestSQL(t)
+
+	rows, err := db.Query("select p.name, p.age, p.tags, pet from people p left join pets on pets.owner = p.id order by p.id")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes() error = %v", err)
+	}
+	var names []string
+	for _, column := range types {
+		names = append(names, column.Name()+" "+column.DatabaseTypeName()+" "+column.ScanType().String())
+	}
+	wantNames := []string{"name STRING string", "age NUMBER float64", "tags ARRAY string", "pet STRING string"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("ColumnTypes() = %v, want %v", names, wantNames)
+	}
+
+	var got [][]any
+	for rows.Next() {
+		var name, tags string
+		var age sql.NullInt64
+		var pet sql.NullString
+		if err := rows.Scan(&name, &age, &tags, &pet); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, []any{name, age, tags, pet})
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	want := [][]any{
+		{"ann", sql.NullInt64{Int64: 31, Valid: true}, `["a","b"]`, sql.NullString{String: "cat", Valid: true}},
+		{"bob", sql.NullInt64{Int64: 25, Valid: true}, `[]`, sql.NullString{}},
+		{"cid", sql.NullInt64{}, `["c"]`, sql.NullString{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+func Test_DriverExec(t *testing.T) {
+	db := openTestSQL(t)
+
+	result, err := db.Exec("update people set age = age + 1 where age is not null")
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("RowsAffected() = %d, %v, want 2", n, err)
+	}
+
+	stmt, err := db.Prepare("select sum(age) from people")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer stmt.Close()
+
+	var sum float64
+	if err := stmt.QueryRow().Scan(&sum); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if sum != 58 {
+		t.Errorf("sum = %v, want 58", sum)
+	}
+
+	if _, err := db.Query("select age from people", 1); err == nil {
+		t.Errorf("Query() with an argument error = nil")
+	}
+	if _, err := db.Prepare("select from"); err == nil {
+		t.Errorf("Prepare() of a wrong query error = nil")
+	}
+}
+
+func Test_DriverArguments(t *testing.T) {
+	db := openTestSQL(t)
+
+	stmt, err := db.Prepare("update people set age = ? where name = ?")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer stmt.Close()
+	for _, args := range [][]any{{40, "ann"}, {nil, "bob"}} {
+		if _, err := stmt.Exec(args...); err != nil {
+			t.Fatalf("Exec(%v) error = %v", args, err)
+		}
+	}
+
+	var count int
+	since := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.QueryRow("select count(*) from people where age is null and $1 > cast('1999-12-31' as timestamp)", since).Scan(&count); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("people without age = %d, want 2", count)
+	}
+
+	if _, err := stmt.Exec(1); err == nil {
+		t.Errorf("Exec() with a missing argument error = nil")
+	}
+	if _, err := db.Exec("delete from people where name = ?", sql.Named("name", "ann")); err == nil {
+		t.Errorf("Exec() with a named argument error = nil")
+	}
+}
+
+func Test_DriverTx(t *testing.T) {
+	db := openTestSQL(t)
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("select count(*) from pets").Scan(&n); err != nil {
+			t.Fatalf("QueryRow() error = %v", err)
+		}
+		return n
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := tx.Exec("insert into pets (owner, pet) values (2, 'dog')"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("count after rollback = %d, want 1", n)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := tx.Exec("insert into pets (owner, pet) values (2, 'dog')"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("count after commit = %d, want 2", n)
+	}
+}
+
+func Test_DriverTxSessions(t *testing.T) {
+	db := openTestSQL(t)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := tx.Exec("delete from pets"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	// another connection neither sees the delete nor loses its insert to
+	// the rollback
+	inserted := make(chan error, 1)
+	go func() {
+		_, err := db.Exec("insert into pets (owner, pet) values (2, 'dog')")
+		inserted <- err
+	}()
+	select {
+	case err := <-inserted:
+		t.Fatalf("Exec() ran during the transaction, error = %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err := <-inserted; err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	var n int
+	if err := db.QueryRow("select count(*) from pets").Scan(&n); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("count = %d, want 2", n)
+	}
+}
+
+func Test_DriverConcurrent(t *testing.T) {
+	db := openTestSQL(t)
+	db.SetMaxOpenConns(4)
+
+	errs := make(chan error, 16)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%4 == 0 {
+				if _, err := db.Exec("update people set age = age + 1 where id = 1"); err != nil {
+					errs <- err
+				}
+				return
+			}
+
+			rows, err := db.Query("select p.name, pet from people p left join pets on pets.owner = p.id")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rows.Close()
+			for rows.Next() {
+			}
+			if err := rows.Err(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("error = %v", err)
+	}
+
+	var age int
+	if err := db.QueryRow("select age from people where id = 1").Scan(&age); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if age != 35 {
+		t.Errorf("age = %d, want 35", age)
+	}
+}
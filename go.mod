@@ -3,6 +3,7 @@ module github.com/kotsmile/jql
 go 1.22.8
 
 require (
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/sirupsen/logrus v1.9.3
 )
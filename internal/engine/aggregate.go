@@ -0,0 +1,524 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var ErrNotGrouped = errors.New("column must appear in the 'group by' clause or be used in an aggregate function")
+
+type aggregate struct {
+	returnType func(argument columnType) (columnType, error)
+	// start makes an accumulator for the values of a group
+	start func() accumulator
+}
+
+// accumulator computes an aggregate over the values added to it one at a
+// time, which are never null.
+type accumulator interface {
+	add(value any) error
+	result() any
+}
+
+var aggregates map[string]aggregate
+
+func init() {
+	numeric := func(name string) func(columnType) (columnType, error) {
+		return func(argument columnType) (columnType, error) {
+			if argument != NumberType && argument != NullType {
+				return "", fmt.Errorf("%w: '%s' expects number, got %s", ErrTypeMismatch, name, argument)
+			}
+			return NumberType, nil
+		}
+	}
+	same := func(argument columnType) (columnType, error) {
+		return argument, nil
+	}
+
+	aggregates = map[string]aggregate{
+		"count": {
+			returnType: func(columnType) (columnType, error) {
+				return NumberType, nil
+			},
+			start: func() accumulator { return &countAccumulator{} },
+		},
+		"sum": {
+			returnType: numeric("sum"),
+			start:      func() accumulator { return &sumAccumulator{} },
+		},
+		"avg": {
+			returnType: numeric("avg"),
+			start:      func() accumulator { return &sumAccumulator{average: true} },
+		},
+		"min": {
+			returnType: same,
+			start:      func() accumulator { return &extremeAccumulator{sign: -1} },
+		},
+		"max": {
+			returnType: same,
+			start:      func() accumulator { return &extremeAccumulator{sign: 1} },
+		},
+	}
+}
+
+type countAccumulator struct {
+	count int
+}
+
+func (a *countAccumulator) add(any) error {
+	a.count++
+	return nil
+}
+
+func (a *countAccumulator) result() any { return float64(a.count) }
+
+// sumAccumulator adds up numbers, averaging them when average is set. It
+// has no result without values.
+type sumAccumulator struct {
+	total   float64
+	count   int
+	average bool
+}
+
+func (a *sumAccumulator) add(value any) error {
+	number, ok := toNumber(value)
+	if !ok {
+		return fmt.Errorf("%w: cannot sum %s", ErrTypeMismatch, valueType(value))
+	}
+	a.total += number
+	a.count++
+	return nil
+}
+
+func (a *sumAccumulator) result() any {
+	switch {
+	case a.count == 0:
+		return nil
+	case a.average:
+		return a.total / float64(a.count)
+	}
+	return a.total
+}
+
+// extremeAccumulator keeps the smallest value, or the largest one when sign
+// is positive.
+type extremeAccumulator struct {
+	sign  int
+	value any
+}
+
+func (a *extremeAccumulator) add(value any) error {
+	if a.value == nil {
+		a.value = value
+		return nil
+	}
+
+	// values of mixed columns are ordered by type, as when sorting
+	if c := compareSort(value, a.value); c*a.sign > 0 {
+		a.value = value
+	}
+	return nil
+}
+
+func (a *extremeAccumulator) result() any { return a.value }
+
+func isAggregate(node *parser.AstNode) bool {
+	f, ok := node.Value().(parser.FunctionNode)
+	if !ok || isWindow(node) {
+		return false
+	}
+	_, ok = aggregates[f.Value()]
+	return ok
+}
+
+// containsAggregate reports whether an aggregate call appears anywhere in
+// the expression.
+func containsAggregate(node *parser.AstNode) bool {
+	if isAggregate(node) {
+		return true
+	}
+	if isSubquery(node) {
+		return false
+	}
+	for _, child := range node.Children() {
+		if containsAggregate(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateCall splits an aggregate call into its argument and whether it
+// only considers distinct values.
+func aggregateCall(node *parser.AstNode) (*parser.AstNode, bool, error) {
+	name := node.Value().Value()
+	arguments := node.Children()
+
+	distinct := false
+	if first, ok := util.At(arguments, 0); ok && first.Value().Value() == parser.DistinctKeyword.String() {
+		if _, ok := first.Value().(*parser.KeywordNode); ok {
+			distinct = true
+			arguments = arguments[1:]
+		}
+	}
+
+	argument, ok := util.At(arguments, 0)
+	if !ok || len(arguments) != 1 {
+		return nil, false, fmt.Errorf("%w: '%s' takes 1 argument, got %d", ErrWrongOperandNumber, name, len(arguments))
+	}
+	if containsAggregate(argument) {
+		return nil, false, fmt.Errorf("aggregate calls cannot be nested in '%s'", node.Text())
+	}
+	if argument.Value() == parser.ColumnNode("*") && (name != "count" || distinct) {
+		return nil, false, fmt.Errorf("'*' is only allowed in 'count(*)'")
+	}
+
+	return argument, distinct, nil
+}
+
+func aggregateType(node *parser.AstNode, s *scope) (columnType, error) {
+	argument, _, err := aggregateCall(node)
+	if err != nil {
+		return "", err
+	}
+
+	argumentType := NumberType
+	if argument.Value() != parser.ColumnNode("*") {
+		argumentType, err = expressionType(argument, s)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return aggregates[node.Value().Value()].returnType(argumentType)
+}
+
+// aggregateState accumulates the values of an aggregate call for a group
+// of rows, skipping nulls and, for distinct calls, values seen before.
+type aggregateState struct {
+	argument    *parser.AstNode
+	distinct    bool
+	seen        map[string]struct{}
+	accumulator accumulator
+}
+
+func newAggregateState(node *parser.AstNode) (*aggregateState, error) {
+	argument, distinct, err := aggregateCall(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aggregateState{
+		argument:    argument,
+		distinct:    distinct,
+		seen:        make(map[string]struct{}),
+		accumulator: aggregates[node.Value().Value()].start(),
+	}, nil
+}
+
+// add adds the value of the argument for the row of the scope.
+func (a *aggregateState) add(s *scope) error {
+	var value any = true
+	if a.argument.Value() != parser.ColumnNode("*") {
+		var err error
+		value, err = evalExpression(a.argument, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	if a.distinct {
+		key := valueKey(value)
+		if _, ok := a.seen[key]; ok {
+			return nil
+		}
+		a.seen[key] = struct{}{}
+	}
+
+	return a.accumulator.add(value)
+}
+
+func computeAggregate(node *parser.AstNode, s *scope, rows []Row) (any, error) {
+	state, err := newAggregateState(node)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if err := state.add(s.withRow(row)); err != nil {
+			return nil, err
+		}
+	}
+
+	return state.accumulator.result(), nil
+}
+
+// valueKey encodes values so that structurally equal ones, including
+// nested arrays and objects, share a key.
+func valueKey(values ...any) string {
+	normalized := make([]any, 0, len(values))
+	for _, value := range values {
+		if number, ok := toNumber(value); ok {
+			value = number
+		}
+		normalized = append(normalized, value)
+	}
+
+	// maps are encoded with sorted keys, which makes the encoding canonical
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return fmt.Sprintf("%#v", normalized)
+	}
+	return string(b)
+}
+
+// rewriteExpression copies an expression, replacing every subtree for which
+// replace returns a node. Subqueries are kept as they are.
+func rewriteExpression(node *parser.AstNode, replace func(*parser.AstNode) *parser.AstNode) *parser.AstNode {
+	if isSubquery(node) {
+		return node
+	}
+	if replacement := replace(node); replacement != nil {
+		return replacement
+	}
+
+	copied := parser.NewAstNode(node.Value())
+	for _, child := range node.Children() {
+		copied.AppendChild(rewriteExpression(child, replace))
+	}
+	return copied
+}
+
+// aggregateColumns rewrites select columns to refer to the values of the
+// grouping expressions and aggregate calls, stored under their text in the
+// rows made by aggregateOperator. It returns the calls along with the columns.
+func aggregateColumns(groupBy []*parser.AstNode, selected []selectColumn) ([]*parser.AstNode, []selectColumn) {
+	groupColumns := make(map[string]struct{})
+	for _, expression := range groupBy {
+		groupColumns[expression.Text()] = struct{}{}
+	}
+
+	var calls []*parser.AstNode
+	rewritten := make([]selectColumn, 0, len(selected))
+	for _, column := range selected {
+		expression := rewriteExpression(column.expression, func(node *parser.AstNode) *parser.AstNode {
+			if _, ok := groupColumns[node.Text()]; ok {
+				return parser.NewAstNode(parser.ColumnNode(node.Text()))
+			}
+			if !isAggregate(node) {
+				return nil
+			}
+
+			calls = append(calls, node)
+			return parser.NewAstNode(parser.ColumnNode(node.Text()))
+		})
+
+		rewritten = append(rewritten, selectColumn{
+			expression: expression,
+			name:       column.name,
+		})
+	}
+
+	return calls, rewritten
+}
+
+// groupedColumns checks the grouping expressions and aggregate calls of a
+// select and returns the columns of the rows made by aggregateOperator.
+func groupedColumns(
+	s *scope,
+	groupBy []*parser.AstNode,
+	calls []*parser.AstNode,
+	rewritten []selectColumn,
+) (columns, error) {
+	groupColumns := make(map[string]struct{})
+	grouped := make(columns)
+	for name, column := range s.columns {
+		grouped[name] = column
+	}
+
+	for _, expression := range groupBy {
+		if containsAggregate(expression) {
+			return nil, fmt.Errorf("aggregate calls are not allowed in 'group by'")
+		}
+
+		t, err := expressionType(expression, s)
+		if err != nil {
+			return nil, err
+		}
+		groupColumns[expression.Text()] = struct{}{}
+		grouped[expression.Text()] = columnDefinition{ColumnType: t}
+	}
+
+	for _, call := range calls {
+		t, err := aggregateType(call, s)
+		if err != nil {
+			return nil, err
+		}
+		groupColumns[call.Text()] = struct{}{}
+		grouped[call.Text()] = columnDefinition{ColumnType: t}
+	}
+
+	for _, column := range rewritten {
+		if err := checkGrouped(column.expression, groupColumns, s.columns); err != nil {
+			return nil, err
+		}
+	}
+
+	return grouped, nil
+}
+
+// aggregateOperator aggregates the rows of its input into one row per group.
+// Every group row holds the first row of its group, plus the values of the
+// grouping expressions and aggregate calls stored under their text, which is
+// how the columns rewritten by aggregateColumns refer to them. Only the first
+// row and the accumulators of every group are held while the input is read.
+type aggregateOperator struct {
+	node  *aggregateNode
+	input rowOperator
+	bound *scope
+	rows  []Row
+}
+
+// group is a group of rows being aggregated.
+type group struct {
+	row    Row
+	states []*aggregateState
+}
+
+func (o *aggregateOperator) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+	s := o.input.scope()
+
+	columns, err := groupedColumns(s, o.node.groupBy, o.node.calls, o.node.columns)
+	if err != nil {
+		return fmt.Errorf("table '%s': %w", o.input.name(), err)
+	}
+	o.bound = s.withColumns(columns)
+
+	var groups []*group
+	byKey := make(map[string]*group)
+	for i := 1; ; i++ {
+		row, ok, err := o.input.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		scoped := s.withRow(row)
+		values, err := evalExpressions(o.node.groupBy, scoped)
+		if err != nil {
+			return fmt.Errorf("table '%s': row %d: 'group by': %w", o.input.name(), i, err)
+		}
+
+		key := valueKey(values...)
+		g, ok := byKey[key]
+		if !ok {
+			g, err = o.newGroup(row, values)
+			if err != nil {
+				return fmt.Errorf("table '%s': %w", o.input.name(), err)
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+
+		for j, state := range g.states {
+			if err := state.add(scoped); err != nil {
+				return fmt.Errorf("table '%s': '%s': %w", o.input.name(), o.node.calls[j].Text(), err)
+			}
+		}
+	}
+
+	// without 'group by' all rows form a single group, even when empty
+	if len(o.node.groupBy) == 0 && len(groups) == 0 {
+		g, err := o.newGroup(nil, nil)
+		if err != nil {
+			return fmt.Errorf("table '%s': %w", o.input.name(), err)
+		}
+		groups = append(groups, g)
+	}
+
+	o.rows = make([]Row, 0, len(groups))
+	for _, g := range groups {
+		for j, state := range g.states {
+			g.row[o.node.calls[j].Text()] = state.accumulator.result()
+		}
+		o.rows = append(o.rows, g.row)
+	}
+	return nil
+}
+
+// newGroup starts a group with its first row and the values of its grouping
+// expressions.
+func (o *aggregateOperator) newGroup(first Row, values []any) (*group, error) {
+	row := make(Row, len(first)+len(values)+len(o.node.calls))
+	for name, value := range first {
+		row[name] = value
+	}
+	for i, value := range values {
+		row[o.node.groupBy[i].Text()] = value
+	}
+
+	g := &group{row: row}
+	for _, call := range o.node.calls {
+		state, err := newAggregateState(call)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", call.Text(), err)
+		}
+		g.states = append(g.states, state)
+	}
+	return g, nil
+}
+
+func (o *aggregateOperator) Next() (Row, bool, error) {
+	if len(o.rows) == 0 {
+		return nil, false, nil
+	}
+	row := o.rows[0]
+	o.rows = o.rows[1:]
+	return row, true, nil
+}
+
+func (o *aggregateOperator) Close() error {
+	o.rows = nil
+	return o.input.Close()
+}
+
+func (o *aggregateOperator) scope() *scope { return o.bound }
+func (o *aggregateOperator) name() string  { return o.input.name() }
+
+// checkGrouped rejects plain table columns that are used outside of
+// aggregate calls and are not grouped by.
+func checkGrouped(node *parser.AstNode, grouped map[string]struct{}, columns columns) error {
+	if column, ok := node.Value().(parser.ColumnNode); ok {
+		if _, ok := grouped[column.Value()]; ok {
+			return nil
+		}
+		if _, ok := columns[column.Value()]; ok {
+			return fmt.Errorf("%w: '%s'", ErrNotGrouped, column.Value())
+		}
+		return nil
+	}
+
+	if isSubquery(node) {
+		return nil
+	}
+
+	for _, child := range node.Children() {
+		if err := checkGrouped(child, grouped, columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+// dropCommand removes a table or a view.
+func (c *Engine) dropCommand(name string, view bool) error {
+	if view {
+		if _, ok := c.views[name]; !ok {
+			return fmt.Errorf("view '%s' not found", name)
+		}
+		delete(c.views, name)
+		return nil
+	}
+
+	if _, err := c.loadedTable(name); err != nil {
+		return err
+	}
+	delete(c.loadedTables, name)
+	return nil
+}
+
+// renameCommand gives a table a new name. A loaded table keeps its source
+// file.
+func (c *Engine) renameCommand(name, newName string) error {
+	table, err := c.loadedTable(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.loadedTables[newName]; ok {
+		return fmt.Errorf("%w: '%s'", ErrNameTaken, newName)
+	}
+	if _, ok := c.views[newName]; ok {
+		return fmt.Errorf("%w: '%s'", ErrNameTaken, newName)
+	}
+
+	delete(c.loadedTables, name)
+	c.loadedTables[newName] = table
+	return nil
+}
+
+// reloadCommand reads a loaded table again from its source file, returning
+// the new table and how its columns changed. Indexes are built again for
+// the columns that still exist. Like loadCommand, it reads the file without
+// holding the lock.
+func (c *Engine) reloadCommand(ctx context.Context, name string) (*Table, []string, error) {
+	if err := c.lock(ctx, false); err != nil {
+		return nil, nil, err
+	}
+	filename, err := c.sourceFile(name)
+	c.unlock(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reloaded, err := readTable(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.lock(ctx, true); err != nil {
+		return nil, nil, err
+	}
+	defer c.unlock(true)
+
+	// another statement may have replaced the table while the file was read
+	current, err := c.sourceFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current != filename {
+		return nil, nil, fmt.Errorf("table '%s' was replaced while its file was read", name)
+	}
+
+	table := c.loadedTables[name]
+	for _, index := range table.indexes {
+		if _, ok := reloaded.columns[index.column]; ok {
+			reloaded.indexes = append(reloaded.indexes, newHashIndex(index.name, index.column, reloaded.rows))
+		}
+	}
+
+	c.loadedTables[name] = reloaded
+	return reloaded, schemaChanges(table.columns, reloaded.columns), nil
+}
+
+// sourceFile returns the file a loaded table was loaded from.
+func (c *Engine) sourceFile(name string) (string, error) {
+	table, err := c.loadedTable(name)
+	if err != nil {
+		return "", err
+	}
+	if table.source == nil {
+		return "", fmt.Errorf("%w: '%s'", ErrNoSource, name)
+	}
+	return table.source.filename, nil
+}
+
+// schemaChanges describes added, removed and retyped columns, by column
+// name.
+func schemaChanges(before, after columns) []string {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		old, hadColumn := before[name]
+		current, hasColumn := after[name]
+		switch {
+		case !hadColumn:
+			changes = append(changes, fmt.Sprintf("+ column '%s' (%s)", name, current.ColumnType))
+		case !hasColumn:
+			changes = append(changes, fmt.Sprintf("- column '%s' (%s)", name, old.ColumnType))
+		case old.ColumnType != current.ColumnType:
+			changes = append(changes, fmt.Sprintf("~ column '%s' (%s -> %s)", name, old.ColumnType, current.ColumnType))
+		}
+	}
+	return changes
+}
+
+// describeCommand writes the columns of a table or a view with their types,
+// and the indexes of a table.
+func (c *Engine) describeCommand(ctx context.Context, w io.Writer, name string) error {
+	s := c.newScope(ctx)
+	_, view := s.catalog.views[name]
+	table, err := s.table(name)
+	if err != nil {
+		return err
+	}
+
+	if view {
+		fmt.Fprintf(w, "View '%s'\n", name)
+	} else {
+		fmt.Fprintf(w, "Table '%s' with %d rows\n", name, len(table.rows))
+	}
+
+	names := table.columnNames()
+	width := 0
+	for _, column := range names {
+		width = max(width, len(column))
+	}
+	for _, column := range names {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, column, table.columns[column].ColumnType)
+	}
+
+	if len(table.indexes) > 0 {
+		fmt.Fprintln(w, "Indexes:")
+		for _, index := range table.indexes {
+			fmt.Fprintf(w, "  %s (%s)\n", index.name, index.column)
+		}
+	}
+	return nil
+}
+
+// processSchemaCommand runs the 'drop', 'alter', 'reload' and 'describe'
+// commands.
+func (c *Engine) processSchemaCommand(ctx context.Context, w io.Writer, command string, query *parser.AstNode) error {
+	children := query.Children()
+
+	switch command {
+	case parser.DropKeyword.String():
+		kind, ok := util.At(children, 0)
+		if !ok {
+			return fmt.Errorf("'drop' command: missing 'table' or 'view' keyword")
+		}
+		nameNode, ok := util.At(children, 1)
+		if !ok {
+			return fmt.Errorf("'drop' command: missing name")
+		}
+		name := nameNode.Value().Value()
+		view := kind.Value().Value() == parser.ViewKeyword.String()
+
+		if err := c.dropCommand(name, view); err != nil {
+			return fmt.Errorf("failed to drop '%s': %w", name, err)
+		}
+		if view {
+			fmt.Fprintf(w, "Dropped view '%s'\n", name)
+		} else {
+			fmt.Fprintf(w, "Dropped table '%s'\n", name)
+		}
+	case parser.AlterKeyword.String():
+		name, err := tableName("alter", query)
+		if err != nil {
+			return err
+		}
+		renameNode, ok := util.At(children, 1)
+		if !ok {
+			return fmt.Errorf("'alter' command: missing 'rename to' keywords")
+		}
+		newName, err := tableName("alter", renameNode)
+		if err != nil {
+			return err
+		}
+
+		if err := c.renameCommand(name, newName); err != nil {
+			return fmt.Errorf("failed to rename table '%s': %w", name, err)
+		}
+		fmt.Fprintf(w, "Renamed table '%s' to '%s'\n", name, newName)
+	case parser.DescribeKeyword.String():
+		name, err := tableName("describe", query)
+		if err != nil {
+			return err
+		}
+
+		if err := c.describeCommand(ctx, w, name); err != nil {
+			return fmt.Errorf("failed to describe '%s': %w", name, err)
+		}
+	case parser.ReloadKeyword.String():
+		name, err := tableName("reload", query)
+		if err != nil {
+			return err
+		}
+
+		table, changes, err := c.reloadCommand(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to reload table '%s': %w", name, err)
+		}
+
+		fmt.Fprintf(w, "Reloaded table '%s' with %d rows\n", name, len(table.rows))
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "  schema unchanged")
+		} else {
+			fmt.Fprintf(w, "  %s\n", strings.Join(changes, "\n  "))
+		}
+	}
+
+	return nil
+}
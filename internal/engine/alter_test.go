@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_DropAndRename(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows(), "u": fixtureRows()})
+
+	for _, cmd := range []string{
+		"create view v as select name from t;",
+		"drop view v;",
+		"drop table u;",
+		"alter table t rename to people;",
+	} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	if _, ok := e.views["v"]; ok {
+		t.Errorf("view 'v' was not dropped")
+	}
+	for _, name := range []string{"t", "u"} {
+		if _, ok := e.loadedTables[name]; ok {
+			t.Errorf("table '%s' is still loaded", name)
+		}
+	}
+
+	got, err := runSelect(t, e, "select count(*) from people;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+	if want := [][]any{{float64(len(fixtureRows()))}}; !reflect.DeepEqual(got.rows, want) {
+		t.Errorf("selectQuery() rows = %v, want %v", got.rows, want)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     string
+		wantErr error
+	}{
+		{
+			name: "drop missing table",
+			cmd:  "drop table t;",
+		},
+		{
+			name: "drop missing view",
+			cmd:  "drop view people;",
+		},
+		{
+			name: "rename missing table",
+			cmd:  "alter table t rename to u;",
+		},
+		{
+			name:    "rename to taken name",
+			cmd:     "alter table people rename to people;",
+			wantErr: ErrNameTaken,
+		},
+		{
+			name:    "reload table without file",
+			cmd:     "reload people;",
+			wantErr: ErrNoSource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Process(context.Background(), parseQuery(t, tt.cmd))
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "t.json")
+	if err := os.WriteFile(filename, []byte(`[{"id": 1, "name": "ann", "age": 30}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	e := New(&out)
+	if err := e.LoadTable(context.Background(), filename, "t"); err != nil {
+		t.Fatalf("LoadTable() error = %v", err)
+	}
+	for _, cmd := range []string{"alter table t rename to people;", "create index on people (age);", "create index on people (name);"} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	data := `[{"id": "a", "name": "ann", "email": "a@b"}, {"id": "b", "name": "bob", "email": null}]`
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := e.Process(context.Background(), parseQuery(t, "reload people;")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := "Reloaded table 'people' with 2 rows\n" +
+		"  - column 'age' (number)\n" +
+		"  + column 'email' (string)\n" +
+		"  ~ column 'id' (number -> string)\n"
+	if out.String() != want {
+		t.Errorf("Process() output = %q, want %q", out.String(), want)
+	}
+
+	indexes := e.loadedTables["people"].indexes
+	if len(indexes) != 1 || indexes[0].column != "name" || len(indexes[0].positions) != 2 {
+		t.Errorf("indexes after reload = %v, want index on 'name'", indexes)
+	}
+
+	out.Reset()
+	if err := e.Process(context.Background(), parseQuery(t, "reload people;")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if want := "Reloaded table 'people' with 2 rows\n  schema unchanged\n"; out.String() != want {
+		t.Errorf("Process() output = %q, want %q", out.String(), want)
+	}
+}
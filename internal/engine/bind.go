@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var (
+	ErrArgumentCount      = errors.New("wrong number of arguments")
+	ErrArgumentType       = errors.New("unsupported argument type")
+	ErrUnboundPlaceholder = errors.New("placeholder without an argument")
+)
+
+// Bind returns a copy of a statement with its placeholders replaced by the
+// arguments at their positions, so that a parsed statement runs with many
+// arguments. Arguments are numbers of any kind, strings, byte slices,
+// booleans, time.Time values or nil.
+func Bind(query *parser.AstNode, args []any) (*parser.AstNode, error) {
+	if n := parser.Placeholders(query); n != len(args) {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrArgumentCount, n, len(args))
+	}
+	if len(args) == 0 {
+		return query, nil
+	}
+
+	values := make([]any, 0, len(args))
+	for i, arg := range args {
+		value, err := argumentValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument $%d: %w", i+1, err)
+		}
+		values = append(values, value)
+	}
+	return bindNode(query, values), nil
+}
+
+func bindNode(node *parser.AstNode, values []any) *parser.AstNode {
+	if placeholder, ok := node.Value().(parser.PlaceholderNode); ok {
+		return valueNode(values[placeholder-1])
+	}
+
+	copied := parser.NewAstNode(node.Value())
+	for _, child := range node.Children() {
+		copied.AppendChild(bindNode(child, values))
+	}
+	return copied
+}
+
+// argumentValue converts an argument to a value.
+func argumentValue(arg any) (any, error) {
+	switch v := arg.(type) {
+	case nil, float64, string, bool, time.Time:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+
+	v := reflect.ValueOf(arg)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrArgumentType, arg)
+}
+
+// valueNode is an expression making a value. Timestamps have no literals
+// and are cast from their text.
+func valueNode(value any) *parser.AstNode {
+	switch v := value.(type) {
+	case float64:
+		return parser.NewAstNode(parser.NewLiteral(parser.NumberLiteral, strconv.FormatFloat(v, 'f', -1, 64)))
+	case string:
+		return parser.NewAstNode(parser.NewLiteral(parser.StringLiteral, v))
+	case bool:
+		return parser.NewAstNode(parser.NewLiteral(parser.BooleanLiteral, strconv.FormatBool(v)))
+	case time.Time:
+		node := parser.NewAstNode(parser.CastFunction)
+		node.AppendChild(parser.NewAstNode(parser.NewLiteral(parser.StringLiteral, v.Format(time.RFC3339Nano))))
+		node.AppendChild(parser.NewAstNode(parser.StringNode(TimestampType)))
+		return node
+	}
+	return parser.NewAstNode(parser.NewLiteral(parser.NullLiteral, "null"))
+}
+
+// parameterTypes holds the types of placeholders inferred while a statement
+// with unbound placeholders is checked, by placeholder.
+type parameterTypes map[parser.PlaceholderNode]columnType
+
+type parameterTypesKey struct{}
+
+// infer records the type of a placeholder, keeping the first type found.
+// Nodes other than placeholders and unknown types are ignored.
+func (p parameterTypes) infer(node *parser.AstNode, t columnType) {
+	placeholder, ok := node.Value().(parser.PlaceholderNode)
+	if !ok || p == nil || !knownType(t) {
+		return
+	}
+	if _, ok := p[placeholder]; !ok {
+		p[placeholder] = t
+	}
+}
+
+// inferOperands records for the placeholders among the operands of an
+// operator the type of the first other operand of a known type, as in
+// 'name = $1'.
+func (p parameterTypes) inferOperands(operands []*parser.AstNode, types []columnType) {
+	for i, operand := range operands {
+		for j, t := range types {
+			if j != i && knownType(t) {
+				p.infer(operand, t)
+				break
+			}
+		}
+	}
+}
+
+// ParameterTypes infers the types of the placeholders of a statement from
+// where they are used: the operand an operator combines them with, the
+// column an 'insert' or 'update' writes them to, and numbers for 'limit'
+// and 'offset'. A placeholder used nowhere with a known type has the type
+// "". The statement is checked as it would run, with its placeholders null,
+// and its errors are returned.
+func (c *Engine) ParameterTypes(ctx context.Context, query *parser.AstNode) ([]string, error) {
+	types := make(parameterTypes)
+	ctx, cancel := c.withTimeout(context.WithValue(ctx, parameterTypesKey{}, types))
+	defer cancel()
+
+	if err := c.lock(ctx, false); err != nil {
+		return nil, err
+	}
+	defer c.unlock(false)
+
+	if err := c.checkStatement(ctx, query); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, parser.Placeholders(query))
+	for placeholder, t := range types {
+		if i := int(placeholder) - 1; i < len(result) {
+			result[i] = string(t)
+		}
+	}
+	return result, nil
+}
+
+// checkStatement types the expressions of a statement without changing any
+// table. Queries are opened and closed again.
+func (c *Engine) checkStatement(ctx context.Context, query *parser.AstNode) error {
+	switch query.Value().Value() {
+	case parser.InsertKeyword.String():
+		statement, err := newInsertStatement(query)
+		if err != nil {
+			return err
+		}
+		s := c.newScope(ctx)
+		table, err := s.loadedTable(statement.table)
+		if err != nil {
+			return err
+		}
+		if statement.query != nil {
+			return c.checkQuery(ctx, statement.query)
+		}
+
+		columns := statement.columns
+		if len(columns) == 0 {
+			columns = table.columnNames()
+		}
+		for _, expressions := range statement.values {
+			for i, expression := range expressions {
+				if i < len(columns) {
+					s.parameters.infer(expression, table.columns[columns[i]].ColumnType)
+				}
+				if _, err := expressionType(expression, s); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case parser.UpdateKeyword.String():
+		statement, err := newUpdateStatement(query)
+		if err != nil {
+			return err
+		}
+		table, err := c.newScope(ctx).loadedTable(statement.table)
+		if err != nil {
+			return err
+		}
+
+		s := c.newScope(ctx).bind(statement.table, table.columns)
+		for _, a := range statement.assignments {
+			s.parameters.infer(a.expression, table.columns[a.column].ColumnType)
+			if _, err := expressionType(a.expression, s); err != nil {
+				return fmt.Errorf("column '%s': %w", a.column, err)
+			}
+		}
+		if statement.where != nil {
+			return checkCondition(parser.WhereKeyword, statement.where, s)
+		}
+		return nil
+	case parser.DeleteKeyword.String():
+		statement, err := newDeleteStatement(query)
+		if err != nil {
+			return err
+		}
+		table, err := c.newScope(ctx).loadedTable(statement.table)
+		if err != nil {
+			return err
+		}
+
+		s := c.newScope(ctx).bind(statement.table, table.columns)
+		if statement.where != nil {
+			return checkCondition(parser.WhereKeyword, statement.where, s)
+		}
+		return nil
+	}
+
+	if !isSubquery(query) && query.Value().Value() != parser.WithKeyword.String() {
+		return nil
+	}
+	statement, err := newSelectStatement(query)
+	if err != nil {
+		return err
+	}
+	return c.checkQuery(ctx, statement)
+}
+
+// checkQuery opens the operators of a query and closes them again.
+func (c *Engine) checkQuery(ctx context.Context, statement *selectStatement) error {
+	op, err := c.open(ctx, statement)
+	if err != nil {
+		return err
+	}
+	return op.Close()
+}
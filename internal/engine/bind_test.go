@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Bind(t *testing.T) {
+	moment := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cmd      string
+		args     []any
+		wantRows [][]any
+		wantErr  error
+	}{
+		{
+			name:     "numbers of any kind",
+			cmd:      "select name from t where id = ? or id = ? or score = ? order by id;",
+			args:     []any{1, uint8(2), float32(30)},
+			wantRows: [][]any{{"ann"}, {"bob"}, {"cid"}},
+		},
+		{
+			name:     "reused position",
+			cmd:      "select id from t where id > $1 and score > $1 * 2;",
+			args:     []any{2},
+			wantRows: [][]any{{3.0}},
+		},
+		{
+			name:     "string with a quote",
+			cmd:      "select ? || name from t where id = 1;",
+			args:     []any{"it's "},
+			wantRows: [][]any{{"it's ann"}},
+		},
+		{
+			name:     "null and boolean",
+			cmd:      "select ? is null, ? and true from t where id = 1;",
+			args:     []any{nil, false},
+			wantRows: [][]any{{true, false}},
+		},
+		{
+			name:     "timestamp",
+			cmd:      "select $1 > cast('2024-01-01' as timestamp), $1 from t where id = 1;",
+			args:     []any{moment},
+			wantRows: [][]any{{true, moment}},
+		},
+		{
+			name:     "subquery",
+			cmd:      "select name from t where id in (select id from t where score >= ?);",
+			args:     []any{30},
+			wantRows: [][]any{{"cid"}},
+		},
+		{
+			name:    "too few arguments",
+			cmd:     "select name from t where id = ? or id = ?;",
+			args:    []any{1},
+			wantErr: ErrArgumentCount,
+		},
+		{
+			name:    "unsupported argument",
+			cmd:     "select name from t where id = ?;",
+			args:    []any{[]int{1}},
+			wantErr: ErrArgumentType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+			query, err := Bind(parseQuery(t, tt.cmd), tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Bind() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			cursor, err := e.Query(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			defer cursor.Close()
+
+			var got [][]any
+			for {
+				values, ok, err := cursor.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !ok {
+					break
+				}
+				got = append(got, values)
+			}
+			if !reflect.DeepEqual(got, tt.wantRows) {
+				t.Errorf("rows = %v, want %v", got, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_BindOnce(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	insert := parseQuery(t, "insert into t (id, name) values ($1, $2);")
+
+	// the parsed statement is bound again for every row
+	for i, name := range []string{"dan", "eve"} {
+		query, err := Bind(insert, []any{i + 4, name})
+		if err != nil {
+			t.Fatalf("Bind() error = %v", err)
+		}
+		if n, err := e.Exec(context.Background(), query); err != nil || n != 1 {
+			t.Fatalf("Exec() = %d, %v, want 1", n, err)
+		}
+	}
+
+	if _, err := e.Exec(context.Background(), insert); !errors.Is(err, ErrUnboundPlaceholder) {
+		t.Errorf("Exec() of an unbound statement error = %v, want %v", err, ErrUnboundPlaceholder)
+	}
+
+	result, err := runSelect(t, e, "select name from t where id > 3 order by id;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+	if want := [][]any{{"dan"}, {"eve"}}; !reflect.DeepEqual(result.rows, want) {
+		t.Errorf("rows = %v, want %v", result.rows, want)
+	}
+}
+
+func Test_ParameterTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "compared columns",
+			cmd:  "select id from t where name = $1 and score > $2 and $3 < id;",
+			want: []string{"string", "number", "number"},
+		},
+		{
+			name: "operators and subqueries",
+			cmd:  "select $1 + 1, name like $2 from t where id in (select id from t where score between $3 and $4);",
+			want: []string{"number", "string", "number", "number"},
+		},
+		{
+			name: "limit and offset",
+			cmd:  "select id from t order by id limit $1 offset $2;",
+			want: []string{"number", "number"},
+		},
+		{
+			name: "unknown types",
+			cmd:  "select $1, $2 is null from t;",
+			want: []string{"", ""},
+		},
+		{
+			name: "insert values",
+			cmd:  "insert into t (name, id) values ($1, $2), ('x', $3);",
+			want: []string{"string", "number", "number"},
+		},
+		{
+			name: "update",
+			cmd:  "update t set name = $1 where score = $2;",
+			want: []string{"string", "number"},
+		},
+		{
+			name: "delete",
+			cmd:  "delete from t where name = $1;",
+			want: []string{"string"},
+		},
+		{
+			name:    "missing table",
+			cmd:     "select id from missing where id = $1;",
+			wantErr: ErrTableNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+			got, err := e.ParameterTypes(context.Background(), parseQuery(t, tt.cmd))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParameterTypes() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParameterTypes() = %q, want %q", got, tt.want)
+			}
+
+			// the statement changed no rows
+			result, err := runSelect(t, e, "select count(*) from t;")
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if want := [][]any{{float64(len(fixtureRows()))}}; !reflect.DeepEqual(result.rows, want) {
+				t.Errorf("rows = %v, want %v", result.rows, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+func Test_ConcurrentStatements(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	if err := e.Process(context.Background(), parseQuery(t, "create view v as select id, name from t where score is not null;")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	const workers = 8
+	const rounds = 20
+
+	// statements are parsed up front, since only the test goroutine may
+	// fail the test
+	statements := make([][]*parser.AstNode, workers)
+	for i := range workers {
+		filename := filepath.Join(dir, fmt.Sprintf("w%d.json", i))
+		if err := os.WriteFile(filename, []byte(`[{"id": 1, "w": true}, {"id": 3, "w": false}]`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		name := fmt.Sprintf("w%d", i)
+		for _, cmd := range []string{
+			fmt.Sprintf("load '%s' as %s;", filename, name),
+			fmt.Sprintf("select t.name, %s.w from t join %s on t.id = %s.id;", name, name, name),
+			"select count(*) from v where id in (select id from t);",
+			fmt.Sprintf("update t set score = %d where id = 2;", i),
+			fmt.Sprintf("create index i%d on %s (id);", i, name),
+			"describe v;",
+			fmt.Sprintf("reload %s;", name),
+			fmt.Sprintf("drop table %s;", name),
+		} {
+			statements[i] = append(statements[i], parseQuery(t, cmd))
+		}
+	}
+
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				for _, statement := range statements[i] {
+					if err := e.Process(context.Background(), statement); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Process() error = %v", err)
+	}
+	if len(e.loadedTables) != 1 {
+		t.Errorf("tables after the drops = %d, want 1", len(e.loadedTables))
+	}
+}
+
+func Test_ConcurrentCursors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	// the rows are read one at a time, which a sort would read up front
+	query := parseQuery(t, "select id from t;")
+	del := parseQuery(t, "delete from t where id > 1;")
+
+	cursor, err := e.Query(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer cursor.Close()
+
+	next := func() ([]any, bool) {
+		t.Helper()
+		values, ok, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		return values, ok
+	}
+
+	values, ok := next()
+	if !ok {
+		t.Fatal("Next() = false, want a first row")
+	}
+	got := []any{values[0]}
+
+	// the delete runs between two reads of the cursor, which still reads
+	// the rows from before it
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var n int
+	var execErr error
+	go func() {
+		defer wg.Done()
+		n, execErr = e.Exec(context.Background(), del)
+	}()
+	wg.Wait()
+
+	if execErr != nil || n != 2 {
+		t.Errorf("Exec() = %d, %v, want 2", n, execErr)
+	}
+
+	for {
+		values, ok := next()
+		if !ok {
+			break
+		}
+		got = append(got, values[0])
+	}
+	if want := []any{1.0, 2.0, 3.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cursor rows = %v, want %v", got, want)
+	}
+
+	result, err := runSelect(t, e, "select count(*) from t;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+	if result.rows[0][0] != 1.0 {
+		t.Errorf("rows after the delete = %v, want 1", result.rows[0][0])
+	}
+}
+
+func Test_ConcurrentOutput(t *testing.T) {
+	// enough rows that the results are rendered in several writes
+	rows := make([]Row, 0, 2000)
+	for i := range cap(rows) {
+		rows = append(rows, Row{"id": float64(i), "name": fmt.Sprintf("n%d", i)})
+	}
+	e := newTestEngine(t, map[string][]Row{"t": rows})
+	query := parseQuery(t, "select * from t order by id;")
+
+	var out bytes.Buffer
+	e.writer = &out
+	if err := e.Process(context.Background(), query); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := out.String()
+	out.Reset()
+
+	const workers = 8
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- e.Process(context.Background(), query)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Process() error = %v", err)
+		}
+	}
+	// the results follow each other whole
+	if got := out.String(); got != strings.Repeat(want, workers) {
+		t.Errorf("output =\n%s\nwant %d times\n%s", got, workers, want)
+	}
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var (
+	ErrConversion      = errors.New("conversion failed")
+	ErrUnknownCastType = errors.New("unknown cast type")
+)
+
+// timestampLayouts are the string forms accepted as timestamps, tried in
+// order. Values without a zone are read as utc.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// castTypes are the types a value can be converted to.
+var castTypes = map[string]columnType{
+	string(NumberType):    NumberType,
+	string(StringType):    StringType,
+	string(BooleanType):   BooleanType,
+	string(TimestampType): TimestampType,
+}
+
+// canConvert reports whether values of a type can ever be converted to
+// another one. Nested values only convert to their json text.
+func canConvert(from, to columnType) bool {
+	switch from {
+	case NullType, MixedType, to, StringType:
+		return true
+	case ArrayType, ObjectType:
+		return to == StringType
+	case TimestampType:
+		return to != BooleanType
+	}
+	return to != TimestampType || from == NumberType
+}
+
+// convertValue converts a value to a type. Null converts to null, values
+// that cannot be converted return an ErrConversion naming the value.
+func convertValue(value any, to columnType) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	from := valueType(value)
+	fail := func() (any, error) {
+		return nil, fmt.Errorf("%w: cannot convert %s %s to %s", ErrConversion, from, quoteValue(value), to)
+	}
+	if from == to {
+		return value, nil
+	}
+	if !canConvert(from, to) {
+		return fail()
+	}
+
+	switch to {
+	case NumberType:
+		switch v := value.(type) {
+		case string:
+			number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+				return fail()
+			}
+			return number, nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case time.Time:
+			return float64(v.UnixNano()) / float64(time.Second), nil
+		}
+	case StringType:
+		switch v := value.(type) {
+		case []any, map[string]any:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return fail()
+			}
+			return string(b), nil
+		}
+		return formatValue(value), nil
+	case BooleanType:
+		switch v := value.(type) {
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "t", "yes", "y", "1":
+				return true, nil
+			case "false", "f", "no", "n", "0":
+				return false, nil
+			}
+			return fail()
+		default:
+			number, _ := toNumber(v)
+			return number != 0, nil
+		}
+	case TimestampType:
+		switch v := value.(type) {
+		case string:
+			for _, layout := range timestampLayouts {
+				if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+					return t.UTC(), nil
+				}
+			}
+			return fail()
+		default:
+			seconds, _ := toNumber(v)
+			whole, fraction := math.Modf(seconds)
+			return time.Unix(int64(whole), int64(fraction*float64(time.Second))).UTC(), nil
+		}
+	}
+
+	return fail()
+}
+
+// quoteValue renders a value for error messages, quoting strings so that
+// empty and blank ones remain visible.
+func quoteValue(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return formatValue(value)
+}
+
+// castCall splits 'cast(x as type)' into its operand and target type.
+func castCall(node *parser.AstNode) (*parser.AstNode, columnType, error) {
+	children := node.Children()
+	if len(children) != 2 {
+		return nil, "", fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, node.Value().Value())
+	}
+
+	name := children[1].Value().Value()
+	to, ok := castTypes[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: '%s'", ErrUnknownCastType, name)
+	}
+	return children[0], to, nil
+}
+
+func castType(node *parser.AstNode, s *scope) (columnType, error) {
+	operand, to, err := castCall(node)
+	if err != nil {
+		return "", err
+	}
+
+	from, err := expressionType(operand, s)
+	if err != nil {
+		return "", err
+	}
+	if !canConvert(from, to) {
+		return "", fmt.Errorf("%w: cannot cast %s to %s", ErrTypeMismatch, from, to)
+	}
+	return to, nil
+}
+
+// evalCast converts the operand of a cast. 'try_cast' is the lenient form,
+// which turns failed conversions into null.
+func evalCast(node *parser.AstNode, s *scope) (any, error) {
+	operand, to, err := castCall(node)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := evalExpression(operand, s)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := convertValue(value, to)
+	if errors.Is(err, ErrConversion) && node.Value() == parser.TryCastFunction {
+		return nil, nil
+	}
+	return converted, err
+}
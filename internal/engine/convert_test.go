@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ConvertValue(t *testing.T) {
+	moment := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value any
+		to    columnType
+		want  any
+	}{
+		{"null stays null", nil, NumberType, nil},
+		{"string to number", " 2.5 ", NumberType, 2.5},
+		{"boolean to number", true, NumberType, 1.0},
+		{"timestamp to number", moment, NumberType, float64(moment.Unix())},
+		{"number to string", 2.5, StringType, "2.5"},
+		{"object to string", map[string]any{"b": 1.0, "a": []any{true}}, StringType, `{"a":[true],"b":1}`},
+		{"timestamp to string", moment, StringType, "2024-03-01T12:30:00Z"},
+		{"string to boolean", "Yes", BooleanType, true},
+		{"number to boolean", 0.0, BooleanType, false},
+		{"date to timestamp", "2024-03-01", TimestampType, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"datetime to timestamp", "2024-03-01 12:30:00", TimestampType, moment},
+		{"rfc3339 with zone to timestamp", "2024-03-01T14:30:00+02:00", TimestampType, moment},
+		{"unix seconds to timestamp", float64(moment.Unix()), TimestampType, moment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertValue(tt.value, tt.to)
+			if err != nil {
+				t.Fatalf("convertValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ConvertValueErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		to    columnType
+		want  string
+	}{
+		{"not a number", "12abc", NumberType, `string "12abc" to number`},
+		{"not a boolean", "maybe", BooleanType, `string "maybe" to boolean`},
+		{"not a timestamp", "yesterday", TimestampType, `string "yesterday" to timestamp`},
+		{"array to number", []any{1.0}, NumberType, "array [1] to number"},
+		{"boolean to timestamp", true, TimestampType, "boolean true to timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convertValue(tt.value, tt.to)
+			if !errors.Is(err, ErrConversion) {
+				t.Fatalf("convertValue() error = %v, want %v", err, ErrConversion)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("convertValue() error = %v, want it to contain %s", err, tt.want)
+			}
+		})
+	}
+}
+
+var readings = []Row{
+	{"id": 1.0, "value": "12", "at": "2024-03-01", "flag": "yes"},
+	{"id": 2.0, "value": "n/a", "at": "2024-02-01 08:00:00", "flag": "no"},
+	{"id": 3.0, "value": nil, "at": "someday", "flag": nil},
+}
+
+func Test_SelectCaseAndCast(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": readings})
+
+	tests := []struct {
+		name        string
+		cmd         string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "searched case",
+			cmd:         "select case when value is null then 'missing' when value = 'n/a' then 'invalid' else value end from t;",
+			wantColumns: []resultColumn{{"case when value is null then 'missing' when value = 'n/a' then 'invalid' else value end", StringType}},
+			wantRows:    [][]any{{"12"}, {"invalid"}, {"missing"}},
+		},
+		{
+			name:        "simple case without else",
+			cmd:         "select case id when 1 then 'one' when 2 then null end as name from t;",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"one"}, {nil}, {nil}},
+		},
+		{
+			name:        "try_cast",
+			cmd:         "select try_cast(value as number) * 2 as double, try_cast(at as timestamp) as at from t;",
+			wantColumns: []resultColumn{{"double", NumberType}, {"at", TimestampType}},
+			wantRows: [][]any{
+				{24.0, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+				{nil, time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)},
+				{nil, nil},
+			},
+		},
+		{
+			name:        "timestamps compare and sort",
+			cmd:         "select id from t where cast(flag as boolean) or try_cast(at as timestamp) < cast('2024-02-15' as timestamp) order by try_cast(at as timestamp);",
+			wantColumns: []resultColumn{{"id", NumberType}},
+			wantRows:    [][]any{{2.0}, {1.0}},
+		},
+		{
+			name:        "cast to string",
+			cmd:         "select cast(id as string) || ':' || cast(id > 1 as string) from t where id < 3;",
+			wantColumns: []resultColumn{{"cast(id as string) || ':' || cast(id > 1 as string)", StringType}},
+			wantRows:    [][]any{{"1:false"}, {"2:true"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SelectCaseAndCastErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": readings})
+
+	tests := []struct {
+		name     string
+		cmd      string
+		want     error
+		contains string
+	}{
+		{
+			name:     "strict cast names the row and value",
+			cmd:      "select cast(value as number) from t;",
+			want:     ErrConversion,
+			contains: `row 2: column 'cast(value as number)': conversion failed: cannot convert string "n/a" to number`,
+		},
+		{
+			name: "cast of a boolean to timestamp",
+			cmd:  "select cast(true as timestamp) from t;",
+			want: ErrTypeMismatch,
+		},
+		{
+			name: "unknown cast type",
+			cmd:  "select cast(id as integer) from t;",
+			want: ErrUnknownCastType,
+		},
+		{
+			name: "case results of different types",
+			cmd:  "select case when id > 1 then 'big' else 0 end from t;",
+			want: ErrTypeMismatch,
+		},
+		{
+			name: "case condition that is not boolean",
+			cmd:  "select case when id then 1 end from t;",
+			want: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSelect(t, e, tt.cmd)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("selectQuery() error = %v, want %v", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("selectQuery() error = %v, want it to contain %s", err, tt.contains)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,9 +10,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/kotsmile/jql/internal/parser"
-	"github.com/kotsmile/jql/internal/tableui"
 	"github.com/kotsmile/jql/util"
 )
 
@@ -20,29 +23,65 @@ var (
 	ErrLoadAsExpected        = errors.New("'load' command: expected 'as' keyword")
 	ErrLoadExpectedTableName = errors.New("'load' command: expected table name")
 	ErrUnknownCommand        = errors.New("unknown command")
+	ErrTableNotFound         = errors.New("table not found")
 )
 
+// Engine runs statements against its tables. It is safe for use by several
+// goroutines: statements that only read run together, while statements that
+// change tables or settings run alone. Transactions belong to a Session.
 type Engine struct {
+	// mu guards the tables, views and transaction. It is held for a whole
+	// statement, and by cursors while they make a row.
+	mu           sync.RWMutex
 	loadedTables map[string]*Table
-	writer       io.Writer
+	// queries of the views, run every time a view is used
+	views  map[string]*parser.AstNode
+	writer io.Writer
+	// out is held by a statement from its first write to writer until it
+	// ends, so that the results of statements running together do not
+	// interleave
+	out sync.Mutex
+	// set between 'begin' and 'commit' or 'rollback'
+	transaction *transaction
+	// bound on the runtime of statements in nanoseconds, set by 'set
+	// timeout'
+	timeout atomic.Int64
 }
 
 func New(w io.Writer) *Engine {
 	return &Engine{
 		loadedTables: make(map[string]*Table),
+		views:        make(map[string]*parser.AstNode),
 		writer:       w,
 	}
 }
 
-func (e *Engine) GetTable(tablename string) (*Table, error) {
+func (e *Engine) loadedTable(tablename string) (*Table, error) {
 	table, ok := e.loadedTables[tablename]
 	if !ok {
-		return nil, fmt.Errorf("table '%s' not found", tablename)
+		return nil, fmt.Errorf("%w: '%s'", ErrTableNotFound, tablename)
 	}
 	return table, nil
 }
 
-func (e *Engine) Process(query *parser.AstNode) error {
+// Process runs a statement and writes its result. Statements stop with the
+// error of ctx once it is done, or when the timeout option runs out.
+func (e *Engine) Process(ctx context.Context, query *parser.AstNode) error {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	w := &output{engine: e}
+	defer w.release()
+
+	// loads and reloads read their file before taking the lock
+	if command := query.Value().Value(); command != parser.LoadKeyword.String() && command != parser.ReloadKeyword.String() {
+		exclusive := !readOnly(query)
+		if err := e.lock(ctx, exclusive); err != nil {
+			return err
+		}
+		defer e.unlock(exclusive)
+	}
+
 	switch value := query.Value().(type) {
 	case *parser.KeywordNode:
 		switch value.Value() {
@@ -57,8 +96,7 @@ func (e *Engine) Process(query *parser.AstNode) error {
 				return ErrLoadWrongTypeFilename
 			}
 
-			index := strings.Index(filename.Value(), ".json")
-			var tablename parser.StringNode = parser.StringNode(filepath.Base(filename.Value()[:index]))
+			var tablename parser.StringNode = parser.StringNode(TableName(filename.Value()))
 
 			asNode, ok := util.At(query.Children(), 1)
 			if ok {
@@ -81,46 +119,139 @@ func (e *Engine) Process(query *parser.AstNode) error {
 				}
 			}
 
-			if err := e.loadCommand(filename.Value(), tablename.Value()); err != nil {
+			if err := e.loadCommand(ctx, filename.Value(), tablename.Value()); err != nil {
 				return fmt.Errorf("failed to load table: %w", err)
 			}
 
-			fmt.Fprintf(e.writer, "Loaded table '%s'\n", tablename.Value())
+			fmt.Fprintf(w, "Loaded table '%s'\n", tablename.Value())
 		case parser.TablesKeyword.String():
-			for name := range e.loadedTables {
-				fmt.Fprintf(e.writer, "  - %s\n", name)
+			visible := e.visible(ctx)
+			for name := range visible.tables {
+				fmt.Fprintf(w, "  - %s\n", name)
 			}
-		case parser.SelectKeyword.String():
-			fromNode, ok := util.At(query.Children(), len(query.Children())-1)
-			if !ok {
-				return fmt.Errorf("'select' command: missing columns")
+			for name := range visible.views {
+				fmt.Fprintf(w, "  - %s (view)\n", name)
 			}
-			if _, ok := fromNode.Value().(*parser.KeywordNode); !ok {
-				return fmt.Errorf("'select' command: missing 'from' keyword")
+		case parser.BeginKeyword.String():
+			if err := e.beginCommand(ctx); err != nil {
+				return fmt.Errorf("failed to begin transaction: %w", err)
 			}
 
-			columns := make([]string, 0)
-			for _, c := range query.Children()[:len(query.Children())-1] {
-				c, ok := c.Value().(parser.StringNode)
-				if !ok {
-					return fmt.Errorf("'select' command: wrong type for column name")
-				}
-				columns = append(columns, c.Value())
+			fmt.Fprintln(w, "Began transaction")
+		case parser.CommitKeyword.String():
+			if err := e.commitCommand(); err != nil {
+				return fmt.Errorf("failed to commit transaction: %w", err)
 			}
 
-			tablenameNode, ok := util.At(fromNode.Children(), 0)
-			if !ok {
-				return fmt.Errorf("'select' command: wrong number of arguments for 'from' keyword")
+			fmt.Fprintln(w, "Committed transaction")
+		case parser.RollbackKeyword.String():
+			if err := e.rollbackCommand(); err != nil {
+				return fmt.Errorf("failed to roll back transaction: %w", err)
 			}
 
-			tablename, ok := tablenameNode.Value().(parser.StringNode)
-			if !ok {
-				return fmt.Errorf("'select' command: wrong type for table name")
+			fmt.Fprintln(w, "Rolled back transaction")
+		case parser.SelectKeyword.String(), parser.WithKeyword.String(),
+			parser.UnionKeyword.String(), parser.IntersectKeyword.String(), parser.ExceptKeyword.String():
+			statement, err := newSelectStatement(query)
+			if err != nil {
+				return err
 			}
-			if err := e.selectCommand(tablename.Value(), columns); err != nil {
+
+			if err := e.selectCommand(ctx, w, statement); err != nil {
 				return fmt.Errorf("failed to select columns: %w", err)
 			}
 
+		case parser.ExplainKeyword.String():
+			statement, ok := util.At(query.Children(), 0)
+			if !ok {
+				return fmt.Errorf("'explain' command: missing query")
+			}
+
+			plan, err := e.explainCommand(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to explain query: %w", err)
+			}
+
+			fmt.Fprint(w, plan)
+		case parser.SaveKeyword.String():
+			tablename, err := tableName("save", query)
+			if err != nil {
+				return err
+			}
+			_, backup := util.At(query.Children(), 1)
+
+			filename, err := e.saveCommand(tablename, backup)
+			if err != nil {
+				return fmt.Errorf("failed to save table: %w", err)
+			}
+
+			fmt.Fprintf(w, "Saved table '%s' to '%s'\n", tablename, filename)
+		case parser.CreateKeyword.String():
+			if kind, ok := util.At(query.Children(), 0); ok && kind.Value().Value() == parser.IndexKeyword.String() {
+				return e.processCreateIndex(w, query)
+			}
+
+			statement, err := newCreateStatement(query)
+			if err != nil {
+				return err
+			}
+
+			n, err := e.createQuery(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to create '%s': %w", statement.name, err)
+			}
+
+			if statement.view {
+				fmt.Fprintf(w, "Created view '%s'\n", statement.name)
+			} else {
+				fmt.Fprintf(w, "Created table '%s' with %d rows\n", statement.name, n)
+			}
+		case parser.SetKeyword.String():
+			description, err := e.setCommand(query)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(w, description)
+		case parser.DropKeyword.String(), parser.AlterKeyword.String(), parser.ReloadKeyword.String(),
+			parser.DescribeKeyword.String():
+			return e.processSchemaCommand(ctx, w, value.Value(), query)
+		case parser.InsertKeyword.String():
+			statement, err := newInsertStatement(query)
+			if err != nil {
+				return err
+			}
+
+			n, err := e.insertQuery(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to insert rows: %w", err)
+			}
+
+			fmt.Fprintf(w, "Inserted %d rows into '%s'\n", n, statement.table)
+		case parser.UpdateKeyword.String():
+			statement, err := newUpdateStatement(query)
+			if err != nil {
+				return err
+			}
+
+			n, err := e.updateQuery(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to update rows: %w", err)
+			}
+
+			fmt.Fprintf(w, "Updated %d rows in '%s'\n", n, statement.table)
+		case parser.DeleteKeyword.String():
+			statement, err := newDeleteStatement(query)
+			if err != nil {
+				return err
+			}
+
+			n, err := e.deleteQuery(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("failed to delete rows: %w", err)
+			}
+
+			fmt.Fprintf(w, "Deleted %d rows from '%s'\n", n, statement.table)
 		default:
 			return ErrUnknownCommand
 
@@ -133,83 +264,162 @@ func (e *Engine) Process(query *parser.AstNode) error {
 	return nil
 }
 
-func (c *Engine) LoadTable(filename string, tablename string) error {
-	return c.loadCommand(filename, tablename)
+// TableName names a table after its file, without the directory and the
+// extension.
+func TableName(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
 }
 
-func (c *Engine) loadCommand(filename string, tablename string) error {
-	_, ok := c.loadedTables[tablename]
-	if ok {
-		return fmt.Errorf("table '%s' already loaded", tablename)
+// LoadPath loads a json file as a table named after it, or every json file
+// of a directory.
+func (c *Engine) LoadPath(ctx context.Context, path string) error {
+	filenames := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to load '%s': %w", path, err)
+	} else if info.IsDir() {
+		if filenames, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return fmt.Errorf("failed to load '%s': %w", path, err)
+		}
 	}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filename, err)
+	for _, filename := range filenames {
+		if err := c.LoadTable(ctx, filename, TableName(filename)); err != nil {
+			return fmt.Errorf("failed to load '%s': %w", filename, err)
+		}
 	}
-	defer file.Close()
+	return nil
+}
 
-	var rows []Row
+func (c *Engine) LoadTable(ctx context.Context, filename string, tablename string) error {
+	return c.loadCommand(ctx, filename, tablename)
+}
 
-	if err := json.NewDecoder(file).Decode(&rows); err != nil {
-		return fmt.Errorf("failed to decode json: %w", err)
+// loadCommand reads a table from a json file without holding the lock, so
+// statements keep running while large files load.
+func (c *Engine) loadCommand(ctx context.Context, filename string, tablename string) error {
+	if err := c.lock(ctx, false); err != nil {
+		return err
+	}
+	err := c.checkLoadName(tablename)
+	c.unlock(false)
+	if err != nil {
+		return err
 	}
 
-	table, err := NewTable(rows)
+	table, err := readTable(ctx, filename)
 	if err != nil {
-		return fmt.Errorf("failed to create table: %w", err)
+		return err
+	}
+
+	if err := c.lock(ctx, true); err != nil {
+		return err
 	}
+	defer c.unlock(true)
 
+	// another statement may have taken the name while the file was read
+	if err := c.checkLoadName(tablename); err != nil {
+		return err
+	}
 	c.loadedTables[tablename] = table
 
 	return nil
 }
 
-func (c *Engine) selectCommand(tablename string, columns []string) error {
-	table, ok := c.loadedTables[tablename]
-	if !ok {
-		return fmt.Errorf("table '%s' not found", tablename)
+func (c *Engine) checkLoadName(tablename string) error {
+	if _, ok := c.loadedTables[tablename]; ok {
+		return fmt.Errorf("table '%s' already loaded", tablename)
 	}
+	if _, ok := c.views[tablename]; ok {
+		return fmt.Errorf("%w: '%s'", ErrNameTaken, tablename)
+	}
+	return nil
+}
 
-	allColumns := make([]string, 0)
-	for column := range table.columns {
-		allColumns = append(allColumns, column)
+// readOnly reports whether a statement only reads tables.
+func readOnly(query *parser.AstNode) bool {
+	switch query.Value().Value() {
+	case parser.TablesKeyword.String(), parser.SelectKeyword.String(), parser.WithKeyword.String(),
+		parser.UnionKeyword.String(), parser.IntersectKeyword.String(), parser.ExceptKeyword.String(),
+		parser.ExplainKeyword.String(), parser.DescribeKeyword.String():
+		return true
 	}
+	return false
+}
 
-	unpackedColumns := make([]string, 0)
-	for _, column := range columns {
-		if column == "*" {
-			unpackedColumns = append(unpackedColumns, allColumns...)
-		} else {
-			unpackedColumns = append(unpackedColumns, column)
-		}
+// readTable reads a table from a json file, recording the file as the
+// source of the table.
+func readTable(ctx context.Context, filename string) (*Table, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
 	}
 
-	for _, column := range unpackedColumns {
-		if _, ok := table.columns[column]; !ok {
-			return fmt.Errorf("column '%s' not found in table '%s'", column, tablename)
-		}
+	rows, numbers, err := decodeRows(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
 	}
 
-	var cs []string
-	var rs []tableui.Row
+	table, err := NewTable(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table: %w", err)
+	}
 
-	for _, column := range unpackedColumns {
-		cs = append(cs, column)
+	table.source, err = newSource(filename, data, numbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
 	}
 
-	for _, row := range table.rows {
-		r := make(tableui.Row, 0)
-		for _, column := range unpackedColumns {
-			r = append(r, fmt.Sprintf("%v", row[column]))
+	return table, nil
+}
+
+// decodeRows decodes a json array of rows one row at a time, stopping when
+// ctx is canceled, and returns the text of the numbers that reading them
+// changed. A null array has no rows.
+func decodeRows(ctx context.Context, data []byte) ([]Row, numbers, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	start, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if start == nil {
+		return nil, nil, nil
+	}
+	if start != json.Delim('[') {
+		return nil, nil, fmt.Errorf("expected an array of rows, got %v", start)
+	}
+
+	var rows []Row
+	numbers := make(numbers)
+	for decoder.More() {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		var row Row
+		if err := decoder.Decode(&row); err != nil {
+			return nil, nil, err
 		}
-		rs = append(rs, r)
+		if _, err := numbers.value(map[string]any(row)); err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
 	}
+	numbers.prune()
+	return rows, numbers, nil
+}
 
-	tui := tableui.New(cs, rs)
-	if err := tui.Render(c.writer); err != nil {
-		return fmt.Errorf("failed to render table: %w", err)
+// selectCommand prints the rows of a query while they are read.
+func (c *Engine) selectCommand(ctx context.Context, w io.Writer, statement *selectStatement) error {
+	op, err := c.open(ctx, statement)
+	if err != nil {
+		return err
 	}
+	defer op.Close()
 
-	return nil
+	return render(w, op)
 }
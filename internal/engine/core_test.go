@@ -0,0 +1,23 @@
+package engine
+
+import "testing"
+
+func Test_TableName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "people.json", want: "people"},
+		{filename: "./data/people.json", want: "people"},
+		{filename: "nojson", want: "nojson"},
+		{filename: "dir/archive.tar.json", want: "archive.tar"},
+		{filename: "data.json/people", want: "people"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := TableName(tt.filename); got != tt.want {
+				t.Errorf("TableName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
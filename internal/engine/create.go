@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var ErrNameTaken = errors.New("name already used by a table or view")
+
+// createStatement defines a table from the result of a query, or a view
+// that runs the query every time it is used.
+type createStatement struct {
+	view  bool
+	name  string
+	query *parser.AstNode
+}
+
+func newCreateStatement(node *parser.AstNode) (*createStatement, error) {
+	kind, ok := util.At(node.Children(), 0)
+	if !ok {
+		return nil, fmt.Errorf("'create' command: missing 'table' or 'view' keyword")
+	}
+
+	nameNode, ok := util.At(node.Children(), 1)
+	if !ok {
+		return nil, fmt.Errorf("'create' command: missing name")
+	}
+	name, ok := nameNode.Value().(parser.StringNode)
+	if !ok {
+		return nil, fmt.Errorf("'create' command: wrong type for name")
+	}
+
+	query, ok := util.At(node.Children(), 2)
+	if !ok {
+		return nil, fmt.Errorf("'create' command: missing query")
+	}
+
+	return &createStatement{
+		view:  kind.Value().Value() == parser.ViewKeyword.String(),
+		name:  name.Value(),
+		query: query,
+	}, nil
+}
+
+// createQuery runs a create statement. Views are checked by running their
+// query once, tables return their number of rows.
+func (c *Engine) createQuery(ctx context.Context, statement *createStatement) (int, error) {
+	if _, ok := c.loadedTables[statement.name]; ok {
+		return 0, fmt.Errorf("%w: '%s'", ErrNameTaken, statement.name)
+	}
+	if _, ok := c.views[statement.name]; ok {
+		return 0, fmt.Errorf("%w: '%s'", ErrNameTaken, statement.name)
+	}
+
+	query, err := newSelectStatement(statement.query)
+	if err != nil {
+		return 0, err
+	}
+	result, err := c.selectQuery(query, c.newScope(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	if statement.view {
+		c.views[statement.name] = statement.query
+		return 0, nil
+	}
+
+	table := newResultTable(result)
+	c.loadedTables[statement.name] = table
+	return len(table.rows), nil
+}
+
+// viewTable runs the query of a view.
+func (s *scope) viewTable(name string, query *parser.AstNode) (*Table, error) {
+	plan, inner, err := s.viewPlan(name, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.engine.run(plan, inner)
+	if err != nil {
+		return nil, fmt.Errorf("view '%s': %w", name, err)
+	}
+	return newResultTable(result), nil
+}
+
+// viewPlan plans the query of a view along with the scope it runs in. The
+// names of the views being run are kept in the scope to reject views that
+// end up using themselves.
+func (s *scope) viewPlan(name string, query *parser.AstNode) (planNode, *scope, error) {
+	if slices.Contains(s.views, name) {
+		return nil, nil, fmt.Errorf("view '%s' refers to itself", name)
+	}
+
+	statement, err := newSelectStatement(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inner := s.engine.newScope(s.ctx)
+	inner.views = append(slices.Clone(s.views), name)
+	plan, err := s.engine.planQuery(statement, inner)
+	if err != nil {
+		return nil, nil, fmt.Errorf("view '%s': %w", name, err)
+	}
+	return plan, inner, nil
+}
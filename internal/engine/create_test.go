@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_CreateTableAndView(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+	for _, cmd := range []string{
+		"create table scored as select id, score from t where score is not null;",
+		"create table none as select name from t where id > 10;",
+		"create view top as select name, score from t where score > 20;",
+		"create view names as with n as (select name from top) select count(*) as n from n;",
+	} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		cmds        []string
+		query       string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "table keeps the column order of its query",
+			query:       "select * from scored;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"score", NumberType}},
+			wantRows:    [][]any{{1.0, 10.0}, {3.0, 30.0}},
+		},
+		{
+			name:        "empty table",
+			query:       "select * from none;",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{},
+		},
+		{
+			name:        "view",
+			query:       "select * from top;",
+			wantColumns: []resultColumn{{"name", StringType}, {"score", NumberType}},
+			wantRows:    [][]any{{"cid", 30.0}},
+		},
+		{
+			name:        "view is evaluated on each use",
+			cmds:        []string{"update t set score = 50 where id = 1;", "insert into none values ('eve');"},
+			query:       "select t.name, (select n from names) from top t order by 1;",
+			wantColumns: []resultColumn{{"name", StringType}, {"(select n from names)", NumberType}},
+			wantRows:    [][]any{{"ann", 2.0}, {"cid", 2.0}},
+		},
+		{
+			name:        "table is not",
+			query:       "select * from scored;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"score", NumberType}},
+			wantRows:    [][]any{{1.0, 10.0}, {3.0, 30.0}},
+		},
+		{
+			name:        "created tables can be changed",
+			query:       "select * from none;",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"eve"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, cmd := range tt.cmds {
+				if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+					t.Fatalf("Process(%s) error = %v", cmd, err)
+				}
+			}
+
+			got, err := runSelect(t, e, tt.query)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_CreateErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	if err := e.Process(context.Background(), parseQuery(t, "create view v as select * from t;")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cmd  string
+		want error
+	}{
+		{
+			name: "table name taken",
+			cmd:  "create table t as select * from t;",
+			want: ErrNameTaken,
+		},
+		{
+			name: "view name taken",
+			cmd:  "create table v as select * from t;",
+			want: ErrNameTaken,
+		},
+		{
+			name: "query is checked",
+			cmd:  "create view w as select name + 1 from t;",
+			want: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := e.Process(context.Background(), parseQuery(t, tt.cmd)); !errors.Is(err, tt.want) {
+				t.Errorf("Process() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, ok := e.views["w"]; ok {
+		t.Errorf("view 'w' was created from a failing query")
+	}
+}
@@ -0,0 +1,729 @@
+package engine
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var (
+	ErrDivisionByZero     = errors.New("division by zero")
+	ErrTypeMismatch       = errors.New("type mismatch")
+	ErrUnknownExpression  = errors.New("unknown expression")
+	ErrWrongOperandNumber = errors.New("wrong number of operands")
+	ErrUnknownFunction    = errors.New("unknown function")
+)
+
+func valueType(value any) columnType {
+	switch value.(type) {
+	case float64, int:
+		return NumberType
+	case string:
+		return StringType
+	case bool:
+		return BooleanType
+	case []any:
+		return ArrayType
+	case map[string]any:
+		return ObjectType
+	case time.Time:
+		return TimestampType
+	}
+	return NullType
+}
+
+// expressionType infers the type an expression produces for rows of the
+// scope, reporting operands that can never fit an operator.
+func expressionType(node *parser.AstNode, s *scope) (columnType, error) {
+	switch value := node.Value().(type) {
+	case *parser.LiteralNode:
+		switch value.LiteralType() {
+		case parser.NumberLiteral:
+			return NumberType, nil
+		case parser.StringLiteral:
+			return StringType, nil
+		case parser.BooleanLiteral:
+			return BooleanType, nil
+		}
+		return NullType, nil
+	case parser.PlaceholderNode:
+		if s.parameters != nil {
+			return NullType, nil
+		}
+		return "", fmt.Errorf("%w: %s", ErrUnboundPlaceholder, value.Value())
+	case parser.ColumnNode:
+		target, key, err := s.resolve(value.Value())
+		if err != nil {
+			return "", err
+		}
+		return target.columns[key].ColumnType, nil
+	case *parser.KeywordNode:
+		if value.Value() == parser.CaseKeyword.String() {
+			return caseType(node, s)
+		}
+		if !isSubquery(node) {
+			break
+		}
+
+		result, err := s.subqueryColumn(node)
+		if err != nil {
+			return "", err
+		}
+		return result.columns[0].ColumnType, nil
+	case parser.FunctionNode:
+		if value == parser.CastFunction || value == parser.TryCastFunction {
+			return castType(node, s)
+		}
+
+		f, ok := functions[value.Value()]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownFunction, value.Value())
+		}
+
+		arguments := make([]columnType, 0, len(node.Children()))
+		for _, child := range node.Children() {
+			t, err := expressionType(child, s)
+			if err != nil {
+				return "", err
+			}
+			arguments = append(arguments, t)
+		}
+
+		return f.check(value.Value(), arguments)
+	case *parser.OperatorNode:
+		if value.OperatorType() == parser.ExistsOperator {
+			subquery, ok := util.At(node.Children(), 0)
+			if !ok || !isSubquery(subquery) {
+				return "", fmt.Errorf("%w: 'exists' expects a subquery", ErrUnknownExpression)
+			}
+			if _, err := s.subquery(subquery); err != nil {
+				return "", err
+			}
+			return BooleanType, nil
+		}
+
+		operands := make([]columnType, 0, len(node.Children()))
+		for _, child := range node.Children() {
+			t, err := expressionType(child, s)
+			if err != nil {
+				return "", err
+			}
+			operands = append(operands, t)
+		}
+		s.parameters.inferOperands(node.Children(), operands)
+
+		return operatorType(value.OperatorType(), operands)
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownExpression, node.Text())
+}
+
+func evalExpression(node *parser.AstNode, s *scope) (any, error) {
+	switch value := node.Value().(type) {
+	case *parser.LiteralNode:
+		return literalValue(value)
+	case parser.PlaceholderNode:
+		if s.parameters != nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnboundPlaceholder, value.Value())
+	case parser.ColumnNode:
+		target, key, err := s.resolve(value.Value())
+		if err != nil {
+			return nil, err
+		}
+		return target.row[key], nil
+	case *parser.KeywordNode:
+		if value.Value() == parser.CaseKeyword.String() {
+			return evalCase(node, s)
+		}
+		if isSubquery(node) {
+			return s.scalarSubquery(node)
+		}
+	case parser.FunctionNode:
+		if value == parser.CastFunction || value == parser.TryCastFunction {
+			return evalCast(node, s)
+		}
+
+		f, ok := functions[value.Value()]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFunction, value.Value())
+		}
+
+		arguments, err := evalExpressions(node.Children(), s)
+		if err != nil {
+			return nil, err
+		}
+
+		types := make([]columnType, 0, len(arguments))
+		for _, argument := range arguments {
+			types = append(types, valueType(argument))
+		}
+		if _, err := f.check(value.Value(), types); err != nil {
+			return nil, err
+		}
+
+		return f.call(arguments)
+	case *parser.OperatorNode:
+		switch value.OperatorType() {
+		case parser.AndOperator, parser.OrOperator:
+			return evalLogical(value.OperatorType(), node.Children(), s)
+		case parser.ExistsOperator:
+			subquery, ok := util.At(node.Children(), 0)
+			if !ok {
+				return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, value.OperatorType())
+			}
+
+			result, err := s.subquery(subquery)
+			if err != nil {
+				return nil, err
+			}
+			return len(result.rows) > 0, nil
+		case parser.InOperator:
+			if list, ok := util.At(node.Children(), 1); ok && isSubquery(list) {
+				operand, err := evalExpression(node.Children()[0], s)
+				if err != nil {
+					return nil, err
+				}
+
+				result, err := s.subqueryColumn(list)
+				if err != nil {
+					return nil, err
+				}
+
+				operands := []any{operand}
+				for _, row := range result.rows {
+					operands = append(operands, row[0])
+				}
+				return evalIn(operands)
+			}
+		}
+
+		operands, err := evalExpressions(node.Children(), s)
+		if err != nil {
+			return nil, err
+		}
+		return applyOperator(value.OperatorType(), operands)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownExpression, node.Text())
+}
+
+func evalExpressions(nodes []*parser.AstNode, s *scope) ([]any, error) {
+	values := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		v, err := evalExpression(node, s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// evalLogical evaluates 'and' and 'or' with three-valued logic, where null
+// stands for an unknown truth value.
+func evalLogical(operator parser.OperatorType, nodes []*parser.AstNode, s *scope) (any, error) {
+	if len(nodes) != 2 {
+		return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+	}
+
+	// the value that decides the result on its own
+	decisive := operator == parser.OrOperator
+
+	unknown := false
+	for _, node := range nodes {
+		v, err := evalExpression(node, s)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			unknown = true
+			continue
+		}
+
+		b, ok := v.(bool)
+		if !ok {
+			// a value of a mixed column that is not a boolean
+			unknown = true
+			continue
+		}
+		if b == decisive {
+			return decisive, nil
+		}
+	}
+
+	if unknown {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+// caseBranches splits a 'case' expression into the operand of a simple case,
+// which is nil for a searched one, its 'when' nodes and the 'else' result.
+func caseBranches(node *parser.AstNode) (*parser.AstNode, []*parser.AstNode, *parser.AstNode) {
+	var operand, otherwise *parser.AstNode
+	var branches []*parser.AstNode
+	for _, child := range node.Children() {
+		switch child.Value().Value() {
+		case parser.WhenKeyword.String():
+			branches = append(branches, child)
+		case parser.ElseKeyword.String():
+			otherwise = child.Children()[0]
+		default:
+			operand = child
+		}
+	}
+	return operand, branches, otherwise
+}
+
+// caseType checks the conditions of a 'case' expression and that all of
+// its results share a type, which null results fit.
+func caseType(node *parser.AstNode, s *scope) (columnType, error) {
+	operand, branches, otherwise := caseBranches(node)
+
+	operandType := BooleanType
+	if operand != nil {
+		var err error
+		operandType, err = expressionType(operand, s)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	results := make([]*parser.AstNode, 0, len(branches)+1)
+	for _, branch := range branches {
+		condition, result := branch.Children()[0], branch.Children()[1]
+
+		conditionType, err := expressionType(condition, s)
+		if err != nil {
+			return "", err
+		}
+		if operand != nil {
+			if _, err := operatorType(parser.EqualOperator, []columnType{operandType, conditionType}); err != nil {
+				return "", fmt.Errorf("'case' operand: %w", err)
+			}
+		} else if conditionType != BooleanType && knownType(conditionType) {
+			return "", fmt.Errorf("%w: 'when' condition must be boolean, got %s", ErrTypeMismatch, conditionType)
+		}
+
+		results = append(results, result)
+	}
+	if otherwise != nil {
+		results = append(results, otherwise)
+	}
+
+	resultType := NullType
+	for _, result := range results {
+		t, err := expressionType(result, s)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case resultType == NullType:
+			resultType = t
+		case t == NullType || t == resultType:
+		case resultType == MixedType || t == MixedType:
+			resultType = MixedType
+		default:
+			return "", fmt.Errorf("%w: 'case' results are %s and %s", ErrTypeMismatch, resultType, t)
+		}
+	}
+
+	return resultType, nil
+}
+
+// evalCase returns the result of the first branch whose condition holds, or
+// for a simple case whose value equals the operand. Without a match the
+// 'else' result is used, or null.
+func evalCase(node *parser.AstNode, s *scope) (any, error) {
+	operand, branches, otherwise := caseBranches(node)
+
+	var value any
+	if operand != nil {
+		var err error
+		value, err = evalExpression(operand, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, branch := range branches {
+		condition, err := evalExpression(branch.Children()[0], s)
+		if err != nil {
+			return nil, err
+		}
+
+		if operand != nil {
+			condition, err = applyOperator(parser.EqualOperator, []any{value, condition})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ok, err := isTrue(condition)
+		if err != nil {
+			return nil, fmt.Errorf("'when' condition: %w", err)
+		}
+		if ok {
+			return evalExpression(branch.Children()[1], s)
+		}
+	}
+
+	if otherwise != nil {
+		return evalExpression(otherwise, s)
+	}
+	return nil, nil
+}
+
+// isTrue reports whether a predicate holds, treating null as false.
+func isTrue(value any) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: expected boolean, got %s", ErrTypeMismatch, valueType(value))
+	}
+	return b, nil
+}
+
+func literalValue(literal *parser.LiteralNode) (any, error) {
+	switch literal.LiteralType() {
+	case parser.NumberLiteral:
+		number, err := strconv.ParseFloat(literal.Value(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s': %w", literal.Value(), err)
+		}
+		return number, nil
+	case parser.StringLiteral:
+		return literal.Value(), nil
+	case parser.BooleanLiteral:
+		return literal.Value() == "true", nil
+	}
+	return nil, nil
+}
+
+// applyOperator applies an operator to values. Values of types the
+// operator does not take, which only come from mixed columns once the
+// expression passed operatorType, make the result null.
+func applyOperator(operator parser.OperatorType, operands []any) (any, error) {
+	switch operator {
+	case parser.IsNullOperator, parser.IsNotNullOperator:
+		operand, ok := util.At(operands, 0)
+		if !ok || len(operands) != 1 {
+			return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+		}
+		return (operand == nil) == (operator == parser.IsNullOperator), nil
+	case parser.InOperator:
+		return evalIn(operands)
+	}
+
+	for _, operand := range operands {
+		if operand == nil {
+			return nil, nil
+		}
+	}
+
+	switch operator {
+	case parser.NegateOperator:
+		operand, ok := util.At(operands, 0)
+		if !ok || len(operands) != 1 {
+			return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+		}
+		number, ok := toNumber(operand)
+		if !ok {
+			return nil, nil
+		}
+		return -number, nil
+	case parser.NotOperator:
+		operand, ok := util.At(operands, 0)
+		if !ok || len(operands) != 1 {
+			return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+		}
+		b, ok := operand.(bool)
+		if !ok {
+			return nil, nil
+		}
+		return !b, nil
+	case parser.BetweenOperator:
+		if len(operands) != 3 {
+			return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+		}
+		low, err := compareValues(operator, operands[0], operands[1])
+		if err != nil {
+			return nil, nil
+		}
+		high, err := compareValues(operator, operands[0], operands[2])
+		if err != nil {
+			return nil, nil
+		}
+		return low >= 0 && high <= 0, nil
+	}
+
+	if len(operands) != 2 {
+		return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+	}
+	left, right := operands[0], operands[1]
+
+	switch operator {
+	case parser.ConcatOperator:
+		l, lok := concatOperand(left)
+		r, rok := concatOperand(right)
+		if !lok || !rok {
+			return nil, nil
+		}
+		return l + r, nil
+	case parser.EqualOperator:
+		return equalValues(left, right), nil
+	case parser.NotEqualOperator:
+		return !equalValues(left, right), nil
+	case parser.LessOperator, parser.LessOrEqualOperator, parser.GreaterOperator, parser.GreaterOrEqualOperator:
+		c, err := compareValues(operator, left, right)
+		if err != nil {
+			return nil, nil
+		}
+		switch operator {
+		case parser.LessOperator:
+			return c < 0, nil
+		case parser.LessOrEqualOperator:
+			return c <= 0, nil
+		case parser.GreaterOperator:
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case parser.LikeOperator, parser.ILikeOperator, parser.MatchOperator:
+		s, sok := left.(string)
+		pattern, pok := right.(string)
+		if !sok || !pok {
+			return nil, nil
+		}
+
+		var re *regexp.Regexp
+		var err error
+		switch operator {
+		case parser.LikeOperator:
+			re, err = likePattern(pattern, false)
+		case parser.ILikeOperator:
+			re, err = likePattern(pattern, true)
+		default:
+			re, err = regexPattern(pattern, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, nil
+	}
+
+	switch operator {
+	case parser.AddOperator:
+		return l + r, nil
+	case parser.SubtractOperator:
+		return l - r, nil
+	case parser.MultiplyOperator:
+		return l * r, nil
+	case parser.DivideOperator:
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l / r, nil
+	case parser.ModuloOperator:
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return math.Mod(l, r), nil
+	}
+
+	return nil, fmt.Errorf("%w: operator '%s'", ErrUnknownExpression, operator)
+}
+
+// evalIn follows sql semantics: a match is true, otherwise a null on either
+// side makes the result unknown.
+func evalIn(operands []any) (any, error) {
+	value, ok := util.At(operands, 0)
+	if !ok {
+		return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, parser.InOperator)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	unknown := false
+	for _, item := range operands[1:] {
+		if item == nil {
+			unknown = true
+			continue
+		}
+		if equalValues(value, item) {
+			return true, nil
+		}
+	}
+
+	if unknown {
+		return nil, nil
+	}
+	return false, nil
+}
+
+// equalValues compares values structurally, so arrays and objects are equal
+// when their contents are.
+func equalValues(a, b any) bool {
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an == bn
+	}
+
+	switch av := a.(type) {
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalValues(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !equalValues(value, other) {
+				return false
+			}
+		}
+		return true
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	}
+
+	return a == b
+}
+
+func compareValues(operator parser.OperatorType, a, b any) (int, error) {
+	switch av := a.(type) {
+	case float64, int:
+		an, _ := toNumber(av)
+		if bn, ok := toNumber(b); ok {
+			return cmp.Compare(an, bn), nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, nil
+			} else if bv {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv), nil
+		}
+	}
+
+	return 0, operatorTypeError(operator, valueType(a), valueType(b))
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func concatOperand(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64, int, bool, time.Time:
+		return formatValue(v), true
+	}
+	return "", false
+}
+
+func operatorTypeError(operator parser.OperatorType, operands ...columnType) error {
+	if len(operands) == 1 {
+		return fmt.Errorf("%w: cannot apply '%s' to %s", ErrTypeMismatch, operator, operands[0])
+	}
+	return fmt.Errorf("%w: cannot apply '%s' to %s and %s", ErrTypeMismatch, operator, operands[0], operands[1])
+}
+
+// operatorType is the static counterpart of applyOperator.
+func operatorType(operator parser.OperatorType, operands []columnType) (columnType, error) {
+	known := knownType
+
+	switch operator {
+	case parser.IsNullOperator, parser.IsNotNullOperator:
+		return BooleanType, nil
+	case parser.AndOperator, parser.OrOperator, parser.NotOperator:
+		for _, t := range operands {
+			if known(t) && t != BooleanType {
+				return "", operatorTypeError(operator, operands...)
+			}
+		}
+		return BooleanType, nil
+	case parser.ConcatOperator:
+		for _, t := range operands {
+			if t == ArrayType || t == ObjectType {
+				return "", operatorTypeError(operator, operands...)
+			}
+		}
+		return StringType, nil
+	case parser.LikeOperator, parser.ILikeOperator, parser.MatchOperator:
+		for _, t := range operands {
+			if known(t) && t != StringType {
+				return "", operatorTypeError(operator, operands...)
+			}
+		}
+		return BooleanType, nil
+	case parser.EqualOperator, parser.NotEqualOperator, parser.InOperator,
+		parser.LessOperator, parser.LessOrEqualOperator,
+		parser.GreaterOperator, parser.GreaterOrEqualOperator,
+		parser.BetweenOperator:
+		first, ok := util.At(operands, 0)
+		if !ok {
+			return "", fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, operator)
+		}
+		for _, t := range operands[1:] {
+			if known(first) && known(t) && t != first {
+				return "", operatorTypeError(operator, first, t)
+			}
+		}
+		return BooleanType, nil
+	}
+
+	for _, t := range operands {
+		if known(t) && t != NumberType {
+			return "", operatorTypeError(operator, operands...)
+		}
+	}
+	return NumberType, nil
+}
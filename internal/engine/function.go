@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+)
+
+type function struct {
+	arguments  []columnType
+	optional   int
+	returnType columnType
+	call       func(arguments []any) (any, error)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"regexp_like": {
+			arguments:  []columnType{StringType, StringType, StringType},
+			optional:   1,
+			returnType: BooleanType,
+			call: func(arguments []any) (any, error) {
+				for _, argument := range arguments {
+					if argument == nil {
+						return nil, nil
+					}
+				}
+
+				// values of mixed columns that are not strings never match
+				s, sok := arguments[0].(string)
+				pattern, pok := arguments[1].(string)
+				flags, fok := "", true
+				if len(arguments) > 2 {
+					flags, fok = arguments[2].(string)
+				}
+				if !sok || !pok || !fok {
+					return nil, nil
+				}
+
+				re, err := regexPattern(pattern, flags)
+				if err != nil {
+					return nil, err
+				}
+				return re.MatchString(s), nil
+			},
+		},
+	}
+}
+
+// check validates argument count and types of a call and returns its type.
+func (f function) check(name string, arguments []columnType) (columnType, error) {
+	if len(arguments) > len(f.arguments) || len(arguments) < len(f.arguments)-f.optional {
+		return "", fmt.Errorf("%w: '%s' takes %d arguments, got %d", ErrWrongOperandNumber, name, len(f.arguments), len(arguments))
+	}
+
+	for i, t := range arguments {
+		if knownType(t) && t != f.arguments[i] {
+			return "", fmt.Errorf("%w: argument %d of '%s' must be %s, got %s", ErrTypeMismatch, i+1, name, f.arguments[i], t)
+		}
+	}
+
+	return f.returnType, nil
+}
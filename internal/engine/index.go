@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var ErrIndexExists = errors.New("index already exists")
+
+// hashIndex maps the values of a column to the positions of the rows
+// holding them. It is kept up to date as rows are added and changed, and
+// rebuilt when rows are deleted, which moves the rows after them.
+type hashIndex struct {
+	name   string
+	column string
+	// positions by the key of the value, in ascending order
+	positions map[string][]int
+	// rows with values without a key, which any lookup must check
+	rest []int
+}
+
+func newHashIndex(name, column string, rows []Row) *hashIndex {
+	index := &hashIndex{
+		name:      name,
+		column:    column,
+		positions: make(map[string][]int),
+	}
+	for i, row := range rows {
+		value := row[column]
+		if value == nil {
+			continue
+		}
+
+		key, ok := indexKey(value)
+		if !ok {
+			index.rest = append(index.rest, i)
+			continue
+		}
+		index.positions[key] = append(index.positions[key], i)
+	}
+	return index
+}
+
+// add adds the row at position, which is after all the rows of the index.
+func (i *hashIndex) add(position int, value any) {
+	if value == nil {
+		return
+	}
+
+	key, ok := indexKey(value)
+	if !ok {
+		i.rest = append(i.rest, position)
+		return
+	}
+	i.positions[key] = append(i.positions[key], position)
+}
+
+// move moves the row at position from the key of old to the key of value.
+// Position lists are replaced rather than changed, since lookups hand them
+// out.
+func (i *hashIndex) move(position int, old, value any) {
+	oldKey, oldOK := indexKey(old)
+	key, ok := indexKey(value)
+	if (old == nil) == (value == nil) && oldOK == ok && oldKey == key {
+		return
+	}
+
+	if old != nil {
+		if oldOK {
+			i.positions[oldKey] = remove(i.positions[oldKey], position)
+			if len(i.positions[oldKey]) == 0 {
+				delete(i.positions, oldKey)
+			}
+		} else {
+			i.rest = remove(i.rest, position)
+		}
+	}
+	if value != nil {
+		if ok {
+			i.positions[key] = insert(i.positions[key], position)
+		} else {
+			i.rest = insert(i.rest, position)
+		}
+	}
+}
+
+// clone copies an index, so that changing the copy leaves it intact.
+func (i *hashIndex) clone() *hashIndex {
+	copied := *i
+	copied.positions = make(map[string][]int, len(i.positions))
+	for key, positions := range i.positions {
+		copied.positions[key] = slices.Clip(positions)
+	}
+	copied.rest = slices.Clip(i.rest)
+	return &copied
+}
+
+// remove returns a copy of ascending positions without position.
+func remove(positions []int, position int) []int {
+	j, ok := slices.BinarySearch(positions, position)
+	if !ok {
+		return positions
+	}
+	return slices.Delete(slices.Clone(positions), j, j+1)
+}
+
+// insert returns a copy of ascending positions with position.
+func insert(positions []int, position int) []int {
+	j, _ := slices.BinarySearch(positions, position)
+	return slices.Insert(slices.Clip(positions), j, position)
+}
+
+// indexKey is the key of a value in a hash index. Values that are equal
+// without sharing their encoding, like timestamps in different zones, have
+// no key.
+func indexKey(value any) (string, bool) {
+	if _, ok := value.(time.Time); ok {
+		return "", false
+	}
+	return valueKey(value), true
+}
+
+// lookup returns the ascending positions of the rows that may hold value,
+// or false when the index cannot tell them apart from the others.
+func (i *hashIndex) lookup(value any) ([]int, bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	key, ok := indexKey(value)
+	if !ok {
+		return nil, false
+	}
+
+	positions := i.positions[key]
+	if len(i.rest) == 0 {
+		return positions, true
+	}
+
+	merged := append(slices.Clone(positions), i.rest...)
+	slices.Sort(merged)
+	return merged, true
+}
+
+// index finds the index of a column.
+func (t *Table) index(column string) (*hashIndex, bool) {
+	for _, index := range t.indexes {
+		if index.column == column {
+			return index, true
+		}
+	}
+	return nil, false
+}
+
+// rebuildIndexes builds the indexes of a table again for its current rows,
+// dropping indexes of columns that no longer exist.
+func (t *Table) rebuildIndexes() {
+	if len(t.indexes) == 0 {
+		return
+	}
+
+	// a new slice keeps the indexes of copies of the table intact
+	indexes := make([]*hashIndex, 0, len(t.indexes))
+	for _, index := range t.indexes {
+		if _, ok := t.columns[index.column]; ok {
+			indexes = append(indexes, newHashIndex(index.name, index.column, t.rows))
+		}
+	}
+	t.indexes = indexes
+}
+
+// createIndexCommand builds an index on a column of a table. The index is
+// named after them unless a name is given.
+func (c *Engine) createIndexCommand(name, tablename, column string) (string, error) {
+	table, err := c.writableTable(tablename)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := table.columns[column]; !ok {
+		return "", fmt.Errorf("column '%s' not found", column)
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_idx", tablename, column)
+	}
+	for _, index := range table.indexes {
+		if index.name == name || index.column == column {
+			return "", fmt.Errorf("%w: '%s' on column '%s'", ErrIndexExists, index.name, index.column)
+		}
+	}
+
+	indexes := slices.Clone(table.indexes)
+	table.indexes = append(indexes, newHashIndex(name, column, table.rows))
+	return name, nil
+}
+
+// processCreateIndex runs a 'create index' command.
+func (c *Engine) processCreateIndex(w io.Writer, query *parser.AstNode) error {
+	children := query.Children()
+
+	name := ""
+	if indexNode, ok := util.At(children, 0); ok {
+		if nameNode, ok := util.At(indexNode.Children(), 0); ok {
+			name = nameNode.Value().Value()
+		}
+	}
+	tableNode, ok := util.At(children, 1)
+	if !ok {
+		return fmt.Errorf("'create' command: missing table name")
+	}
+	columnNode, ok := util.At(children, 2)
+	if !ok {
+		return fmt.Errorf("'create' command: missing column name for index")
+	}
+	tablename, column := tableNode.Value().Value(), columnNode.Value().Value()
+
+	name, err := c.createIndexCommand(name, tablename, column)
+	if err != nil {
+		return fmt.Errorf("failed to create index on '%s': %w", tablename, err)
+	}
+
+	fmt.Fprintf(w, "Created index '%s' on '%s' (%s)\n", name, tablename, column)
+	return nil
+}
+
+// conjuncts splits a condition into the conditions joined by 'and'.
+func conjuncts(condition *parser.AstNode) []*parser.AstNode {
+	operator, ok := condition.Value().(*parser.OperatorNode)
+	if !ok || operator.OperatorType() != parser.AndOperator {
+		return []*parser.AstNode{condition}
+	}
+
+	var result []*parser.AstNode
+	for _, child := range condition.Children() {
+		result = append(result, conjuncts(child)...)
+	}
+	return result
+}
+
+// equality matches a comparison of a column with an expression using '=',
+// in either order.
+func equality(node *parser.AstNode) (string, *parser.AstNode, bool) {
+	operator, ok := node.Value().(*parser.OperatorNode)
+	if !ok || operator.OperatorType() != parser.EqualOperator {
+		return "", nil, false
+	}
+
+	left, _ := util.At(node.Children(), 0)
+	right, _ := util.At(node.Children(), 1)
+	if left == nil || right == nil {
+		return "", nil, false
+	}
+
+	if column, ok := left.Value().(parser.ColumnNode); ok {
+		return column.Value(), right, true
+	}
+	if column, ok := right.Value().(parser.ColumnNode); ok {
+		return column.Value(), left, true
+	}
+	return "", nil, false
+}
+
+// containsColumn reports whether an expression refers to a column or runs a
+// subquery, so that its value depends on the row.
+func containsColumn(node *parser.AstNode) bool {
+	if _, ok := node.Value().(parser.ColumnNode); ok {
+		return true
+	}
+	if isSubquery(node) {
+		return true
+	}
+	for _, child := range node.Children() {
+		if containsColumn(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_IndexedSelect(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+	for _, cmd := range []string{
+		"create index on t (id);",
+		"create index by_name on t (name);",
+		"insert into t (id, name) values (4, 'dan'), (2, 'bea');",
+		"update t set id = 5 where name = 'ann';",
+		"delete from t where name = 'cid';",
+	} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		cmd       string
+		wantRows  [][]any
+		wantIndex string
+	}{
+		{
+			name:      "equality",
+			cmd:       "select name from t where id = 2;",
+			wantRows:  [][]any{{"bob"}, {"bea"}},
+			wantIndex: "t_id_idx",
+		},
+		{
+			name:      "constant expression on the left",
+			cmd:       "select name from t where 1 + 4 = id;",
+			wantRows:  [][]any{{"ann"}},
+			wantIndex: "t_id_idx",
+		},
+		{
+			name:      "smallest match among conjuncts",
+			cmd:       "select id from t where id = 2 and name = 'bea' and score is null;",
+			wantRows:  [][]any{{2.0}},
+			wantIndex: "by_name",
+		},
+		{
+			name:      "no match",
+			cmd:       "select id from t where name = 'cid';",
+			wantRows:  [][]any{},
+			wantIndex: "by_name",
+		},
+		{
+			name:      "comparison with null",
+			cmd:       "select id from t where id = null;",
+			wantRows:  [][]any{},
+			wantIndex: "t_id_idx",
+		},
+		{
+			name:     "disjunction is not indexed",
+			cmd:      "select id from t where id = 2 or id = 4 order by id;",
+			wantRows: [][]any{{2.0}, {2.0}, {4.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+
+			plan, err := e.explainCommand(context.Background(), parseQuery(t, tt.cmd))
+			if err != nil {
+				t.Fatalf("explainCommand() error = %v", err)
+			}
+			if indexed := strings.Contains(plan, "IndexScan t using "+tt.wantIndex+" "); indexed != (tt.wantIndex != "") {
+				t.Errorf("explainCommand() = %q, want index %q", plan, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func Test_CreateIndexErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	if err := e.Process(context.Background(), parseQuery(t, "create index ids on t (id);")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     string
+		wantErr error
+	}{
+		{
+			name:    "same column",
+			cmd:     "create index on t (id);",
+			wantErr: ErrIndexExists,
+		},
+		{
+			name:    "same name",
+			cmd:     "create index ids on t (name);",
+			wantErr: ErrIndexExists,
+		},
+		{
+			name: "missing column",
+			cmd:  "create index on t (age);",
+		},
+		{
+			name: "missing table",
+			cmd:  "create index on u (id);",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Process(context.Background(), parseQuery(t, tt.cmd))
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_IndexUpdates(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+	for _, cmd := range []string{
+		"create index on t (id);",
+		"create index on t (score);",
+		"insert into t (id, name, score) values (4, 'dan', 10), (2, 'bea', null);",
+		"update t set score = 40 where id = 1;",
+		"update t set score = null where id = 3;",
+		"update t set id = 1, score = 10 where name = 'bob';",
+		"begin;",
+		"insert into t (id, score) values (5, 40);",
+		"update t set id = 6 where id = 1;",
+		"rollback;",
+		"begin;",
+		"update t set score = 50 where id = 4;",
+		"commit;",
+	} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+
+		// the indexes kept up to date match indexes built again
+		table := e.loadedTables["t"]
+		for _, index := range table.indexes {
+			want := newHashIndex(index.name, index.column, table.rows)
+			if !maps.EqualFunc(index.positions, want.positions, slices.Equal) || !slices.Equal(index.rest, want.rest) {
+				t.Fatalf("after %s index %s = %v, want %v", cmd, index.name, index.positions, want.positions)
+			}
+		}
+	}
+}
+
+func Test_IndexRollback(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	for _, cmd := range []string{"begin;", "create index on t (id);", "rollback;"} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	if indexes := e.loadedTables["t"].indexes; len(indexes) != 0 {
+		t.Errorf("indexes = %d, want 0", len(indexes))
+	}
+}
+
+func Test_Describe(t *testing.T) {
+	var out bytes.Buffer
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	e.writer = &out
+
+	for _, cmd := range []string{"create index on t (id);", "create view v as select name, score * 2 as double from t;"} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "table",
+			cmd:  "describe t;",
+			want: "Table 't' with 3 rows\n  id     number\n  name   string\n  score  number\nIndexes:\n  t_id_idx (id)\n",
+		},
+		{
+			name: "view",
+			cmd:  "describe v;",
+			want: "View 'v'\n  name    string\n  double  number\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			if err := e.Process(context.Background(), parseQuery(t, tt.cmd)); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Process() output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var ErrAmbiguousColumn = errors.New("ambiguous column")
+
+// joinOperator joins the rows of the right side of a join, which it holds,
+// to the rows of its left side, read one at a time. With an index of the
+// right rows, as hash joins have, only the rows holding the value of the
+// probe expression are checked for a left row. Otherwise every pair of rows
+// is checked.
+type joinOperator struct {
+	node  *joinNode
+	left  rowOperator
+	right rowOperator
+	outer *scope
+	bound *scope
+
+	rows  []Row
+	index *hashIndex
+
+	// the left row being joined, the right rows left to check for it and
+	// whether one of them matched
+	current Row
+	active  bool
+	pending []Row
+	matched bool
+	count   int
+}
+
+func (o *joinOperator) Open() error {
+	if err := o.left.Open(); err != nil {
+		return err
+	}
+	if err := o.right.Open(); err != nil {
+		return err
+	}
+
+	left, right := o.left.scope().columns, o.right.scope().columns
+	joined := make(columns, len(left)+len(right))
+	for key, column := range left {
+		joined[key] = column
+	}
+	for key, column := range right {
+		joined[key] = column
+	}
+
+	o.bound = o.outer.bind("", joined)
+	o.bound.ambiguous = o.node.ambiguous
+	if err := checkCondition(parser.OnKeyword, o.node.on, o.bound); err != nil {
+		return fmt.Errorf("join of '%s': %w", o.right.name(), err)
+	}
+
+	rows, err := readAll[Row](o.right)
+	if err != nil {
+		return err
+	}
+	o.rows = rows
+
+	if hash := o.node.hash; hash != nil {
+		if source, ok := o.node.right.(*sourceNode); ok && hash.index != "" {
+			if table, err := o.outer.table(source.from); err == nil {
+				o.index, _ = table.index(hash.column)
+			}
+		}
+		if o.index == nil {
+			o.index = newHashIndex("", hash.key, o.rows)
+		}
+	}
+	return nil
+}
+
+func (o *joinOperator) Next() (Row, bool, error) {
+	for {
+		if !o.active {
+			row, ok, err := o.left.Next()
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			o.count++
+			o.current, o.active, o.matched = row, true, false
+
+			o.pending, err = o.candidates(row)
+			if err != nil {
+				return nil, false, fmt.Errorf("join of '%s': row %d: 'on' condition: %w", o.right.name(), o.count, err)
+			}
+		}
+
+		for len(o.pending) > 0 {
+			row := o.combine(o.pending[0])
+			o.pending = o.pending[1:]
+
+			ok, err := holds(parser.OnKeyword, o.node.on, o.bound.withRow(row))
+			if err != nil {
+				return nil, false, fmt.Errorf("join of '%s': row %d: %w", o.right.name(), o.count, err)
+			}
+			if ok {
+				o.matched = true
+				return row, true, nil
+			}
+		}
+
+		o.active = false
+		if !o.matched && o.node.leftJoin {
+			return o.combine(nil), true, nil
+		}
+	}
+}
+
+// candidates finds the right rows that may match a left row.
+func (o *joinOperator) candidates(left Row) ([]Row, error) {
+	if o.index == nil {
+		return o.rows, nil
+	}
+
+	value, err := evalExpression(o.node.hash.probe, o.bound.withRow(left))
+	if err != nil {
+		return nil, err
+	}
+	positions, ok := o.index.lookup(value)
+	if !ok {
+		return o.rows, nil
+	}
+
+	rows := make([]Row, 0, len(positions))
+	for _, i := range positions {
+		rows = append(rows, o.rows[i])
+	}
+	return rows, nil
+}
+
+// combine adds a right row to the current left row, nil adding nulls as
+// left joins do for rows without a match.
+func (o *joinOperator) combine(right Row) Row {
+	columns := o.right.scope().columns
+	combined := make(Row, len(o.current)+len(columns))
+	for key, value := range o.current {
+		combined[key] = value
+	}
+	for key := range columns {
+		combined[key] = right[key]
+	}
+	return combined
+}
+
+func (o *joinOperator) Close() error {
+	o.rows, o.index, o.pending = nil, nil, nil
+	return errors.Join(o.left.Close(), o.right.Close())
+}
+
+func (o *joinOperator) scope() *scope { return o.bound }
+func (o *joinOperator) name() string  { return o.left.name() }
+
+// refersTo reports whether an expression runs a subquery or uses a column
+// for which own holds.
+func refersTo(node *parser.AstNode, own func(string) bool) bool {
+	if column, ok := node.Value().(parser.ColumnNode); ok {
+		return own(column.Value())
+	}
+	if isSubquery(node) {
+		return true
+	}
+	for _, child := range node.Children() {
+		if refersTo(child, own) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var baskets = []Row{
+	{"id": 10.0, "person": 1.0, "item": "pen"},
+	{"id": 11.0, "person": 1.0, "item": "ink"},
+	{"id": 12.0, "person": 3.0, "item": "cap"},
+	{"id": 13.0, "person": 7.0, "item": "box"},
+	{"id": 14.0, "person": nil, "item": "bag"},
+}
+
+func Test_SelectJoin(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         string
+		index       bool
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "inner join",
+			cmd:         "select p.name, item from people p join baskets o on o.person = p.id order by o.id;",
+			wantColumns: []resultColumn{{"name", StringType}, {"item", StringType}},
+			wantRows:    [][]any{{"ann", "pen"}, {"ann", "ink"}, {"cid", "cap"}},
+		},
+		{
+			name:        "inner join using an index",
+			cmd:         "select p.name, item from people p inner join baskets o on p.id = o.person order by o.id;",
+			index:       true,
+			wantColumns: []resultColumn{{"name", StringType}, {"item", StringType}},
+			wantRows:    [][]any{{"ann", "pen"}, {"ann", "ink"}, {"cid", "cap"}},
+		},
+		{
+			name:        "left join",
+			cmd:         "select name, count(o.id) as n from people left join baskets o on person = people.id group by name order by name;",
+			wantColumns: []resultColumn{{"name", StringType}, {"n", NumberType}},
+			wantRows:    [][]any{{"ann", 2.0}, {"bob", 0.0}, {"cid", 1.0}},
+		},
+		{
+			name:        "left outer join with extra condition",
+			cmd:         "select o.item, p.name from baskets o left outer join people p on p.id = o.person and p.team = 'red' order by o.id;",
+			index:       true,
+			wantColumns: []resultColumn{{"item", StringType}, {"name", StringType}},
+			wantRows:    [][]any{{"pen", "ann"}, {"ink", "ann"}, {"cap", "cid"}, {"box", nil}, {"bag", nil}},
+		},
+		{
+			name:        "join without equality",
+			cmd:         "select p.name, o.id from people p join baskets o on o.id < 11 + p.id and p.team = 'blue';",
+			wantColumns: []resultColumn{{"name", StringType}, {"id", NumberType}},
+			wantRows:    [][]any{{"bob", 10.0}, {"bob", 11.0}, {"bob", 12.0}},
+		},
+		{
+			name:        "star over joined sources",
+			cmd:         "select * from people p join (select person, item from baskets where id = 12) o on o.person = p.id;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"name", StringType}, {"team", StringType}, {"person", NumberType}, {"item", StringType}},
+			wantRows:    [][]any{{3.0, "cid", "red", 3.0, "cap"}},
+		},
+		{
+			name:        "three sources",
+			cmd:         "select a.name, b.name from people a join baskets o on o.person = a.id join people b on b.id = o.person - 2 order by o.id;",
+			wantColumns: []resultColumn{{"name", StringType}, {"name", StringType}},
+			wantRows:    [][]any{{"cid", "ann"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"people": members, "baskets": baskets})
+			if tt.index {
+				for _, cmd := range []string{"create index on baskets (person);", "create index on people (id);"} {
+					if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+						t.Fatalf("Process(%s) error = %v", cmd, err)
+					}
+				}
+			}
+
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SelectJoinErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"people": members, "baskets": baskets})
+
+	tests := []struct {
+		name    string
+		cmd     string
+		wantErr error
+	}{
+		{
+			name:    "ambiguous column",
+			cmd:     "select id from people p join baskets o on o.person = p.id;",
+			wantErr: ErrAmbiguousColumn,
+		},
+		{
+			name:    "condition that is not boolean",
+			cmd:     "select item from people p join baskets o on p.id;",
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "column of a later source",
+			cmd:  "select item from people p join baskets o on o.person = x.id join people x on x.id = p.id;",
+		},
+		{
+			name: "same name twice",
+			cmd:  "select item from baskets join baskets on id = id;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSelect(t, e, tt.cmd)
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("selectQuery() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+type insertStatement struct {
+	table   string
+	columns []string
+	values  [][]*parser.AstNode
+	query   *selectStatement
+}
+
+type assignment struct {
+	column     string
+	expression *parser.AstNode
+}
+
+type updateStatement struct {
+	table       string
+	assignments []assignment
+	where       *parser.AstNode
+}
+
+type deleteStatement struct {
+	table string
+	where *parser.AstNode
+}
+
+// tableName reads the table name a command starts with.
+func tableName(command string, node *parser.AstNode) (string, error) {
+	tablenameNode, ok := util.At(node.Children(), 0)
+	if !ok {
+		return "", fmt.Errorf("'%s' command: missing table name", command)
+	}
+
+	tablename, ok := tablenameNode.Value().(parser.StringNode)
+	if !ok {
+		return "", fmt.Errorf("'%s' command: wrong type for table name", command)
+	}
+	return tablename.Value(), nil
+}
+
+func newInsertStatement(node *parser.AstNode) (*insertStatement, error) {
+	table, err := tableName("insert", node)
+	if err != nil {
+		return nil, err
+	}
+	statement := &insertStatement{table: table}
+
+	for _, child := range node.Children()[1:] {
+		switch value := child.Value().(type) {
+		case parser.ColumnNode:
+			if slices.Contains(statement.columns, value.Value()) {
+				return nil, fmt.Errorf("'insert' command: column '%s' listed twice", value.Value())
+			}
+			statement.columns = append(statement.columns, value.Value())
+		case *parser.KeywordNode:
+			if value.Value() == parser.ValuesKeyword.String() {
+				statement.values = append(statement.values, child.Children())
+				continue
+			}
+
+			query, err := newSelectStatement(child)
+			if err != nil {
+				return nil, err
+			}
+			statement.query = query
+		}
+	}
+
+	if statement.values == nil && statement.query == nil {
+		return nil, fmt.Errorf("'insert' command: missing 'values' or 'select'")
+	}
+
+	return statement, nil
+}
+
+func newUpdateStatement(node *parser.AstNode) (*updateStatement, error) {
+	table, err := tableName("update", node)
+	if err != nil {
+		return nil, err
+	}
+	statement := &updateStatement{table: table}
+
+	for _, child := range node.Children()[1:] {
+		switch child.Value().Value() {
+		case parser.SetKeyword.String():
+			for _, assignmentNode := range child.Children() {
+				column, ok := util.At(assignmentNode.Children(), 0)
+				expression, eok := util.At(assignmentNode.Children(), 1)
+				if !ok || !eok {
+					return nil, fmt.Errorf("'update' command: wrong assignment '%s'", assignmentNode.Text())
+				}
+				statement.assignments = append(statement.assignments, assignment{
+					column:     column.Value().Value(),
+					expression: expression,
+				})
+			}
+		case parser.WhereKeyword.String():
+			statement.where, _ = util.At(child.Children(), 0)
+		}
+	}
+
+	if len(statement.assignments) == 0 {
+		return nil, fmt.Errorf("'update' command: missing assignment")
+	}
+
+	return statement, nil
+}
+
+func newDeleteStatement(node *parser.AstNode) (*deleteStatement, error) {
+	table, err := tableName("delete", node)
+	if err != nil {
+		return nil, err
+	}
+	statement := &deleteStatement{table: table}
+
+	if whereNode, ok := util.At(node.Children(), 1); ok {
+		statement.where, _ = util.At(whereNode.Children(), 0)
+	}
+
+	return statement, nil
+}
+
+// insertQuery adds rows to a table and returns how many were added. Only
+// the listed columns, or all columns without a list, are set in the new
+// rows.
+func (c *Engine) insertQuery(ctx context.Context, statement *insertStatement) (int, error) {
+	table, err := c.writableTable(statement.table)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := statement.columns
+	if len(columns) == 0 {
+		columns = table.columnNames()
+	}
+
+	var values [][]any
+	if statement.query != nil {
+		result, err := c.selectQuery(statement.query, c.newScope(ctx))
+		if err != nil {
+			return 0, err
+		}
+		if len(result.columns) != len(columns) {
+			return 0, fmt.Errorf("%w: inserting %d columns into %d", ErrColumnCountMismatch, len(result.columns), len(columns))
+		}
+		values = result.rows
+	} else {
+		s := c.newScope(ctx)
+		for i, expressions := range statement.values {
+			if len(expressions) != len(columns) {
+				return 0, fmt.Errorf("%w: row %d has %d values for %d columns", ErrColumnCountMismatch, i+1, len(expressions), len(columns))
+			}
+
+			row, err := evalExpressions(expressions, s)
+			if err != nil {
+				return 0, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			values = append(values, row)
+		}
+	}
+
+	rows := make([]Row, 0, len(values))
+	for i, v := range values {
+		row := make(Row, len(columns))
+		for j, column := range columns {
+			if err := table.checkValue(column, v[j]); err != nil {
+				return 0, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			row[column] = v[j]
+		}
+		rows = append(rows, row)
+	}
+
+	if err := table.addRows(rows); err != nil {
+		return 0, err
+	}
+	return len(values), nil
+}
+
+// updateQuery changes the rows matching the condition and returns how many
+// were changed. All assignments see the values from before the update.
+func (c *Engine) updateQuery(ctx context.Context, statement *updateStatement) (int, error) {
+	table, err := c.writableTable(statement.table)
+	if err != nil {
+		return 0, err
+	}
+
+	s := c.newScope(ctx).bind(statement.table, table.columns)
+	if statement.where != nil {
+		if err := checkCondition(parser.WhereKeyword, statement.where, s); err != nil {
+			return 0, err
+		}
+	}
+	for _, a := range statement.assignments {
+		t, err := expressionType(a.expression, s)
+		if err != nil {
+			return 0, fmt.Errorf("column '%s': %w", a.column, err)
+		}
+		if column, ok := table.columns[a.column]; ok && !compatibleTypes(column.ColumnType, t) {
+			return 0, fmt.Errorf("%w: column '%s' is %s, got %s", ErrTypeMismatch, a.column, column.ColumnType, t)
+		}
+	}
+
+	var changed []int
+	rows := slices.Clone(table.rows)
+	for i, row := range table.rows {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		rowScope := s.withRow(row)
+		if statement.where != nil {
+			ok, err := holds(parser.WhereKeyword, statement.where, rowScope)
+			if err != nil {
+				return 0, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		updated := make(Row, len(row))
+		for name, value := range row {
+			updated[name] = value
+		}
+		for _, a := range statement.assignments {
+			value, err := evalExpression(a.expression, rowScope)
+			if err != nil {
+				return 0, fmt.Errorf("row %d: column '%s': %w", i+1, a.column, err)
+			}
+			if err := table.checkValue(a.column, value); err != nil {
+				return 0, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			updated[a.column] = value
+		}
+
+		rows[i] = updated
+		changed = append(changed, i)
+	}
+
+	if err := table.updateRows(rows, changed); err != nil {
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// deleteQuery removes the rows matching the condition, or all rows without
+// one, and returns how many were removed.
+func (c *Engine) deleteQuery(ctx context.Context, statement *deleteStatement) (int, error) {
+	table, err := c.writableTable(statement.table)
+	if err != nil {
+		return 0, err
+	}
+
+	s := c.newScope(ctx).bind(statement.table, table.columns)
+	if statement.where != nil {
+		if err := checkCondition(parser.WhereKeyword, statement.where, s); err != nil {
+			return 0, err
+		}
+	}
+
+	rows := make([]Row, 0, len(table.rows))
+	for i, row := range table.rows {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		ok := true
+		if statement.where != nil {
+			ok, err = holds(parser.WhereKeyword, statement.where, s.withRow(row))
+			if err != nil {
+				return 0, fmt.Errorf("row %d: %w", i+1, err)
+			}
+		}
+		if !ok {
+			rows = append(rows, row)
+		}
+	}
+
+	deleted := len(table.rows) - len(rows)
+	table.setRows(rows)
+	return deleted, nil
+}
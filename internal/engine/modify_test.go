@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func fixtureRows() []Row {
+	return []Row{
+		{"id": 1.0, "name": "ann", "score": 10.0},
+		{"id": 2.0, "name": "bob", "score": nil},
+		{"id": 3.0, "name": "cid", "score": 30.0},
+	}
+}
+
+func Test_Modify(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmds        []string
+		query       string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "insert values with column list",
+			cmds:        []string{"insert into t (name, id) values ('dan', 4), ('eve', 2 + 3);"},
+			query:       "select * from t where id > 3;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"name", StringType}, {"score", NumberType}},
+			wantRows:    [][]any{{4.0, "dan", nil}, {5.0, "eve", nil}},
+		},
+		{
+			name:        "insert values without column list",
+			cmds:        []string{"insert into t values (4, 'dan', 1 + 1);"},
+			query:       "select id, name, score from t where id = 4;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"name", StringType}, {"score", NumberType}},
+			wantRows:    [][]any{{4.0, "dan", 2.0}},
+		},
+		{
+			name:        "insert select adds new columns",
+			cmds:        []string{"insert into t (id, name, tag) select id + 10, name, 'copy' from t where score is not null;"},
+			query:       "select id, tag from t order by id;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"tag", StringType}},
+			wantRows:    [][]any{{1.0, nil}, {2.0, nil}, {3.0, nil}, {11.0, "copy"}, {13.0, "copy"}},
+		},
+		{
+			name:        "update sees values from before the update",
+			cmds:        []string{"update t set id = id * 10, score = id where score is null;"},
+			query:       "select id, score from t order by id;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"score", NumberType}},
+			wantRows:    [][]any{{1.0, 10.0}, {3.0, 30.0}, {20.0, 2.0}},
+		},
+		{
+			name:        "update keeps the types of columns set to null",
+			cmds:        []string{"update t set score = null;", "update t set score = 5 where id = 2;"},
+			query:       "select score from t where score is not null;",
+			wantColumns: []resultColumn{{"score", NumberType}},
+			wantRows:    [][]any{{5.0}},
+		},
+		{
+			name:        "update sets the type of null columns",
+			cmds:        []string{"insert into t (id, tag) values (4, null);", "update t set tag = 'new' where id = 4;"},
+			query:       "select tag from t where id = 4;",
+			wantColumns: []resultColumn{{"tag", StringType}},
+			wantRows:    [][]any{{"new"}},
+		},
+		{
+			name:        "delete",
+			cmds:        []string{"delete from t where score > 20 or name = 'bob';"},
+			query:       "select name from t;",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"ann"}},
+		},
+		{
+			name:        "delete everything keeps the columns",
+			cmds:        []string{"delete from t;", "insert into t (name) values ('eve');"},
+			query:       "select * from t;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"name", StringType}, {"score", NumberType}},
+			wantRows:    [][]any{{nil, "eve", nil}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+			for _, cmd := range tt.cmds {
+				if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+			}
+
+			got, err := runSelect(t, e, tt.query)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_ModifyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want error
+	}{
+		{
+			name: "insert of a wrong type",
+			cmd:  "insert into t (id) values ('four');",
+			want: ErrTypeMismatch,
+		},
+		{
+			name: "insert with too few values",
+			cmd:  "insert into t values (4, 'dan');",
+			want: ErrColumnCountMismatch,
+		},
+		{
+			name: "insert select with too many columns",
+			cmd:  "insert into t (id) select id, name from t;",
+			want: ErrColumnCountMismatch,
+		},
+		{
+			name: "update to a wrong type",
+			cmd:  "update t set name = 1 where id = 1;",
+			want: ErrTypeMismatch,
+		},
+		{
+			name: "delete with a condition that is not boolean",
+			cmd:  "delete from t where name;",
+			want: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+			err := e.Process(context.Background(), parseQuery(t, tt.cmd))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Process() error = %v, want %v", err, tt.want)
+			}
+
+			got, err := runSelect(t, e, "select * from t;")
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if len(got.rows) != 3 {
+				t.Errorf("failed command changed the table to %d rows", len(got.rows))
+			}
+		})
+	}
+}
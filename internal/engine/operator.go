@@ -0,0 +1,588 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var ErrInvalidLimit = errors.New("row count must be a non-negative whole number")
+
+// operator runs a node of a plan in the iterator model: Open prepares it,
+// every call to Next returns one row until it reports that no rows are
+// left, and Close releases what it holds. Operators pull rows from their
+// inputs as they are asked for them, so only the ones that need all of
+// their input, like sorting, hold more than a row at a time.
+type operator[T any] interface {
+	Open() error
+	Next() (T, bool, error)
+	Close() error
+}
+
+// rowOperator makes rows of the sources of a select, keyed by column.
+type rowOperator interface {
+	operator[Row]
+	// scope for expressions over the rows, known once the operator is open
+	scope() *scope
+	// name of the first source, for errors
+	name() string
+}
+
+// resultOperator makes rows of a result, with a value per column.
+type resultOperator interface {
+	operator[[]any]
+	// columns of the result, known once the operator is open. Rows hold
+	// the hidden columns used for sorting after them.
+	columns() []resultColumn
+}
+
+// run runs a plan in the scope s and collects its result.
+func (c *Engine) run(node planNode, s *scope) (*resultSet, error) {
+	op, err := c.resultOperator(node, s)
+	if err != nil {
+		return nil, err
+	}
+	defer op.Close()
+
+	if err := op.Open(); err != nil {
+		return nil, err
+	}
+	rows, err := readAll[[]any](op)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = make([][]any, 0)
+	}
+	return &resultSet{columns: op.columns(), rows: rows}, nil
+}
+
+// resultOperator builds the operators running a plan in the scope s.
+func (c *Engine) resultOperator(node planNode, s *scope) (resultOperator, error) {
+	switch n := node.(type) {
+	case *withNode:
+		body, err := c.resultOperator(n.body, s)
+		if err != nil {
+			return nil, err
+		}
+		return &withOperator{engine: c, node: n, outer: s, body: body}, nil
+	case *setNode:
+		left, err := c.resultOperator(n.left, s.nested())
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.resultOperator(n.right, s.nested())
+		if err != nil {
+			return nil, err
+		}
+		return &setOperator{node: n, left: left, right: right}, nil
+	case *limitNode:
+		input, err := c.resultOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &limitOperator{node: n, input: input, outer: s}, nil
+	case *distinctNode:
+		input, err := c.resultOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &distinctOperator{input: input}, nil
+	case *sortNode:
+		input, err := c.resultOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &sortOperator{node: n, input: input, keep: -1}, nil
+	case *projectNode:
+		input, err := c.rowOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &projectOperator{node: n, input: input}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected plan node '%s'", node.label())
+}
+
+// rowOperator builds the operators making the rows of the sources of a
+// select in the scope s.
+func (c *Engine) rowOperator(node planNode, s *scope) (rowOperator, error) {
+	switch n := node.(type) {
+	case *sourceNode:
+		return &scanOperator{engine: c, node: n, outer: s}, nil
+	case *filterNode:
+		input, err := c.rowOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &filterOperator{node: n, input: input}, nil
+	case *joinNode:
+		left, err := c.rowOperator(n.left, s)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.rowOperator(n.right, s)
+		if err != nil {
+			return nil, err
+		}
+		return &joinOperator{node: n, left: left, right: right, outer: s}, nil
+	case *aggregateNode:
+		input, err := c.rowOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &aggregateOperator{node: n, input: input}, nil
+	case *windowNode:
+		input, err := c.rowOperator(n.input, s)
+		if err != nil {
+			return nil, err
+		}
+		return &windowOperator{node: n, input: input}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected plan node '%s'", node.label())
+}
+
+// readAll reads the rows an operator has left.
+func readAll[T any](op operator[T]) ([]T, error) {
+	var rows []T
+	for {
+		row, ok, err := op.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return rows, nil
+		}
+		rows = append(rows, row)
+	}
+}
+
+// scanOperator reads the rows of a source. Subqueries in 'from' and views
+// run their own operators, whose rows are read one at a time. Rows of
+// joined sources are copied under the keys of their columns.
+type scanOperator struct {
+	engine *Engine
+	node   *sourceNode
+	outer  *scope
+	bound  *scope
+
+	// rows of a table left to read
+	rows []Row
+	// operator of a subquery or a view, the names of its columns and how
+	// its errors are introduced
+	query   resultOperator
+	names   []string
+	context string
+}
+
+func (o *scanOperator) Open() error {
+	n := o.node
+
+	var sourceColumns columns
+	if n.query != nil || n.kind == viewSource {
+		plan, s := n.query, o.outer.nested()
+		o.context = "subquery in 'from'"
+		if n.query == nil {
+			var err error
+			plan, s, err = o.outer.viewPlan(n.from, o.outer.catalog.views[n.from])
+			if err != nil {
+				return err
+			}
+			o.context = fmt.Sprintf("view '%s'", n.from)
+		}
+
+		query, err := o.engine.resultOperator(plan, s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.context, err)
+		}
+		o.query = query
+		if err := query.Open(); err != nil {
+			return fmt.Errorf("%s: %w", o.context, err)
+		}
+
+		// later columns of the same name win, as in tables made from results
+		sourceColumns = make(columns)
+		for _, column := range query.columns() {
+			sourceColumns[column.Name] = columnDefinition{ColumnType: column.ColumnType}
+			o.names = append(o.names, column.Name)
+		}
+	} else {
+		table, err := o.outer.table(n.from)
+		if err != nil {
+			return err
+		}
+		sourceColumns = table.columns
+
+		o.rows = table.rows
+		if n.index != nil {
+			o.rows, err = n.index.rows(table, o.outer)
+			if err != nil {
+				return fmt.Errorf("table '%s': %w", n.name(), err)
+			}
+		}
+	}
+
+	keyed := make(columns, len(n.keys))
+	for _, key := range n.keys {
+		keyed[key.key] = sourceColumns[key.column]
+	}
+	if n.joined {
+		o.bound = o.outer.bind("", keyed)
+	} else {
+		o.bound = o.outer.bind(n.name(), keyed)
+	}
+	return nil
+}
+
+func (o *scanOperator) Next() (Row, bool, error) {
+	// every row of a statement is read by a scan, which makes it the place
+	// to stop canceled statements
+	if err := o.outer.ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var row Row
+	if o.query != nil {
+		values, ok, err := o.query.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", o.context, err)
+		}
+		if !ok {
+			return nil, false, nil
+		}
+
+		row = make(Row, len(o.names))
+		for i, name := range o.names {
+			row[name] = values[i]
+		}
+	} else {
+		if len(o.rows) == 0 {
+			return nil, false, nil
+		}
+		row, o.rows = o.rows[0], o.rows[1:]
+	}
+
+	if !o.node.joined {
+		return row, true, nil
+	}
+	keyed := make(Row, len(o.node.keys))
+	for _, key := range o.node.keys {
+		keyed[key.key] = row[key.column]
+	}
+	return keyed, true, nil
+}
+
+func (o *scanOperator) Close() error {
+	o.rows = nil
+	if o.query != nil {
+		return o.query.Close()
+	}
+	return nil
+}
+
+func (o *scanOperator) scope() *scope { return o.bound }
+func (o *scanOperator) name() string  { return o.node.name() }
+
+// rows finds the rows of a table the lookup may match, or all of them when
+// the index cannot tell them apart.
+func (l *indexLookup) rows(table *Table, s *scope) ([]Row, error) {
+	index, ok := table.index(l.column)
+	if !ok {
+		return table.rows, nil
+	}
+
+	value, err := evalExpression(l.value, s)
+	if err != nil {
+		return nil, err
+	}
+	positions, ok := index.lookup(value)
+	if !ok {
+		return table.rows, nil
+	}
+
+	rows := make([]Row, 0, len(positions))
+	for _, i := range positions {
+		rows = append(rows, table.rows[i])
+	}
+	return rows, nil
+}
+
+type filterOperator struct {
+	node  *filterNode
+	input rowOperator
+	// rows read so far, for errors
+	count int
+}
+
+func (o *filterOperator) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+	if err := checkCondition(parser.WhereKeyword, o.node.condition, o.input.scope()); err != nil {
+		return fmt.Errorf("table '%s': %w", o.input.name(), err)
+	}
+	return nil
+}
+
+func (o *filterOperator) Next() (Row, bool, error) {
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		o.count++
+
+		ok, err = holds(parser.WhereKeyword, o.node.condition, o.input.scope().withRow(row))
+		if err != nil {
+			return nil, false, fmt.Errorf("row %d: %w", o.count, err)
+		}
+		if ok {
+			return row, true, nil
+		}
+	}
+}
+
+func (o *filterOperator) Close() error  { return o.input.Close() }
+func (o *filterOperator) scope() *scope { return o.input.scope() }
+func (o *filterOperator) name() string  { return o.input.name() }
+
+// projectOperator computes the select columns for the rows of its input.
+type projectOperator struct {
+	node   *projectNode
+	input  rowOperator
+	result []resultColumn
+	count  int
+}
+
+func (o *projectOperator) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+
+	o.result = make([]resultColumn, 0, o.node.visible)
+	for _, column := range o.node.columns[:o.node.visible] {
+		columnType, err := expressionType(column.expression, o.input.scope())
+		if err != nil {
+			return fmt.Errorf("table '%s': %w", o.input.name(), err)
+		}
+		o.result = append(o.result, resultColumn{
+			Name:       column.name,
+			ColumnType: columnType,
+		})
+	}
+	return nil
+}
+
+func (o *projectOperator) Next() ([]any, bool, error) {
+	row, ok, err := o.input.Next()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	o.count++
+
+	s := o.input.scope().withRow(row)
+	values := make([]any, 0, len(o.node.columns))
+	for _, column := range o.node.columns {
+		value, err := evalExpression(column.expression, s)
+		if err != nil {
+			return nil, false, fmt.Errorf("row %d: column '%s': %w", o.count, column.name, err)
+		}
+		values = append(values, value)
+	}
+	return values, true, nil
+}
+
+func (o *projectOperator) Close() error            { return o.input.Close() }
+func (o *projectOperator) columns() []resultColumn { return o.result }
+
+// sortOperator sorts the rows of its input, which it reads in full, and
+// drops their hidden columns. When a limit only needs the first keep rows,
+// it holds no more than twice as many at a time.
+type sortOperator struct {
+	node  *sortNode
+	input resultOperator
+	// rows needed, or -1 for all of them
+	keep int
+	rows [][]any
+}
+
+func (o *sortOperator) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		// rows with equal values stay in the order they were read, so
+		// dropping the last ones of a stable sort keeps the right rows
+		o.rows = append(o.rows, row)
+		if o.keep >= 0 && len(o.rows) > 2*o.keep {
+			sortRows(o.rows, o.node.indexes, o.node.descending)
+			o.rows = o.rows[:o.keep]
+		}
+	}
+
+	sortRows(o.rows, o.node.indexes, o.node.descending)
+	if o.keep >= 0 && len(o.rows) > o.keep {
+		o.rows = o.rows[:o.keep]
+	}
+	return nil
+}
+
+func (o *sortOperator) Next() ([]any, bool, error) {
+	if len(o.rows) == 0 {
+		return nil, false, nil
+	}
+	row := o.rows[0]
+	o.rows = o.rows[1:]
+	return row[:len(o.input.columns())], true, nil
+}
+
+func (o *sortOperator) Close() error {
+	o.rows = nil
+	return o.input.Close()
+}
+
+func (o *sortOperator) columns() []resultColumn { return o.input.columns() }
+
+// limitOperator passes on rows of its input until it has enough, without
+// reading further rows.
+type limitOperator struct {
+	node  *limitNode
+	input resultOperator
+	outer *scope
+	// rows left to skip and to pass on
+	skip  int
+	count int
+}
+
+func (o *limitOperator) Open() error {
+	count, err := limitValue(o.node.count, o.outer)
+	if err != nil {
+		return fmt.Errorf("'limit': %w", err)
+	}
+	o.count = count
+	if o.node.offset != nil {
+		o.skip, err = limitValue(o.node.offset, o.outer)
+		if err != nil {
+			return fmt.Errorf("'offset': %w", err)
+		}
+	}
+
+	if sort, ok := o.input.(*sortOperator); ok {
+		sort.keep = o.skip + o.count
+	}
+	return o.input.Open()
+}
+
+func (o *limitOperator) Next() ([]any, bool, error) {
+	for ; o.skip > 0; o.skip-- {
+		if _, ok, err := o.input.Next(); err != nil || !ok {
+			return nil, false, err
+		}
+	}
+	if o.count == 0 {
+		return nil, false, nil
+	}
+
+	row, ok, err := o.input.Next()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	o.count--
+	return row, true, nil
+}
+
+func (o *limitOperator) Close() error            { return o.input.Close() }
+func (o *limitOperator) columns() []resultColumn { return o.input.columns() }
+
+// limitValue evaluates the row count of 'limit' or 'offset'.
+func limitValue(expression *parser.AstNode, s *scope) (int, error) {
+	// an unbound placeholder is a number, and no row count while it is
+	// checked
+	if _, ok := expression.Value().(parser.PlaceholderNode); ok && s.parameters != nil {
+		s.parameters.infer(expression, NumberType)
+		return 0, nil
+	}
+
+	value, err := evalExpression(expression, s)
+	if err != nil {
+		return 0, err
+	}
+
+	number, ok := value.(float64)
+	if !ok || number < 0 || number != math.Trunc(number) || number > math.MaxInt32 {
+		return 0, fmt.Errorf("%w, got %s", ErrInvalidLimit, formatValue(value))
+	}
+	return int(number), nil
+}
+
+// distinctOperator drops rows equal to a row passed on before, holding the
+// keys of the rows it passed on.
+type distinctOperator struct {
+	input resultOperator
+	seen  map[string]struct{}
+}
+
+func (o *distinctOperator) Open() error {
+	o.seen = make(map[string]struct{})
+	return o.input.Open()
+}
+
+func (o *distinctOperator) Next() ([]any, bool, error) {
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+
+		key := valueKey(row...)
+		if _, ok := o.seen[key]; ok {
+			continue
+		}
+		o.seen[key] = struct{}{}
+		return row, true, nil
+	}
+}
+
+func (o *distinctOperator) Close() error {
+	o.seen = nil
+	return o.input.Close()
+}
+
+func (o *distinctOperator) columns() []resultColumn { return o.input.columns() }
+
+// withOperator materializes the tables defined by 'with' before running
+// the statement that uses them.
+type withOperator struct {
+	engine *Engine
+	node   *withNode
+	outer  *scope
+	body   resultOperator
+}
+
+func (o *withOperator) Open() error {
+	for _, table := range o.node.tables {
+		result, err := o.engine.run(table.plan, o.outer.nested())
+		if err != nil {
+			return fmt.Errorf("'with' table '%s': %w", table.name, err)
+		}
+		o.outer.tables[table.name] = newResultTable(result)
+	}
+	return o.body.Open()
+}
+
+func (o *withOperator) Next() ([]any, bool, error) { return o.body.Next() }
+func (o *withOperator) Close() error               { return o.body.Close() }
+func (o *withOperator) columns() []resultColumn    { return o.body.columns() }
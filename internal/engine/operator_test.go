@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Limit(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"people": members, "baskets": baskets})
+
+	tests := []struct {
+		name     string
+		cmd      string
+		wantRows [][]any
+		wantErr  error
+	}{
+		{
+			name:     "first rows",
+			cmd:      "select name from people limit 2;",
+			wantRows: [][]any{{"ann"}, {"bob"}},
+		},
+		{
+			name:     "sorted with offset",
+			cmd:      "select name from people order by id desc limit 1 + 1 offset 1;",
+			wantRows: [][]any{{"bob"}, {"ann"}},
+		},
+		{
+			name:     "offset past the last row",
+			cmd:      "select name from people limit 5 offset 10;",
+			wantRows: [][]any{},
+		},
+		{
+			name:     "no rows",
+			cmd:      "select name from people limit 0;",
+			wantRows: [][]any{},
+		},
+		{
+			name:     "rows after the limit are not computed",
+			cmd:      "select 6 / (3 - id) as x from people limit 2;",
+			wantRows: [][]any{{3.0}, {6.0}},
+		},
+		{
+			name:     "set operation",
+			cmd:      "select team from people union select item from baskets order by 1 limit 2;",
+			wantRows: [][]any{{"bag"}, {"blue"}},
+		},
+		{
+			name:     "subquery in 'from'",
+			cmd:      "select count(*) from (select id from people order by id limit 2) s;",
+			wantRows: [][]any{{2.0}},
+		},
+		{
+			name:     "scalar subquery",
+			cmd:      "select name, (select item from baskets b where b.person = p.id order by b.id limit 1) as first from people p order by id;",
+			wantRows: [][]any{{"ann", "pen"}, {"bob", nil}, {"cid", "cap"}},
+		},
+		{
+			name:    "negative count",
+			cmd:     "select name from people limit -1;",
+			wantErr: ErrInvalidLimit,
+		},
+		{
+			name:    "fractional offset",
+			cmd:     "select name from people limit 1 offset 0.5;",
+			wantErr: ErrInvalidLimit,
+		},
+		{
+			name:    "string count",
+			cmd:     "select name from people limit 'a';",
+			wantErr: ErrInvalidLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("selectQuery() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SortWithLimit(t *testing.T) {
+	// values repeat, so the rows kept for equal values show whether the
+	// rows dropped while reading were the right ones
+	var rows []Row
+	for i := 0; i < 100; i++ {
+		rows = append(rows, Row{"id": float64(i), "bucket": float64(i % 7)})
+	}
+	e := newTestEngine(t, map[string][]Row{"numbers": rows})
+
+	limited, err := runSelect(t, e, "select id from numbers order by bucket desc limit 10 offset 5;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+	all, err := runSelect(t, e, "select id from numbers order by bucket desc;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(limited.rows, all.rows[5:15]) {
+		t.Errorf("selectQuery() rows = %v, want %v", limited.rows, all.rows[5:15])
+	}
+}
+
+func Test_RenderPages(t *testing.T) {
+	var rows []Row
+	for i := 0; i < 2*pageSize+1; i++ {
+		rows = append(rows, Row{"id": float64(i)})
+	}
+	e := newTestEngine(t, map[string][]Row{"numbers": rows})
+
+	tests := []struct {
+		name      string
+		cmd       string
+		wantPages int
+	}{
+		{
+			name:      "empty result",
+			cmd:       "select id from numbers where id < 0;",
+			wantPages: 1,
+		},
+		{
+			name:      "single page",
+			cmd:       "select id from numbers limit 10;",
+			wantPages: 1,
+		},
+		{
+			name:      "several pages",
+			cmd:       "select id from numbers;",
+			wantPages: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			e.writer = &out
+			if err := e.Process(context.Background(), parseQuery(t, tt.cmd)); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if got := strings.Count(out.String(), "id|\n"); got != tt.wantPages {
+				t.Errorf("Process() printed %d pages, want %d", got, tt.wantPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+// optimize rewrites a plan before it runs. Conditions of 'where' on a
+// single joined source move below the join, sources only read the columns
+// the statement uses, and indexes find rows compared with a constant and
+// the rows of joined sources. Tables are those seen in s.
+func (c *Engine) optimize(node planNode, s *scope) {
+	switch n := node.(type) {
+	case *projectNode:
+		pushDown(n)
+		pruneColumns(n)
+	case *filterNode:
+		if source, ok := n.input.(*sourceNode); ok {
+			c.useIndex(n, source, s)
+		}
+	case *joinNode:
+		c.useHashJoin(n, s)
+	}
+
+	for _, input := range node.inputs() {
+		c.optimize(input, s)
+	}
+}
+
+// foldConstants replaces the parts of an expression that do not depend on
+// rows with their value, turning 'a > 60 * 60' into 'a > 3600'. Parts whose
+// value fails, has no literal or changes the type of the part are kept.
+func foldConstants(node *parser.AstNode, s *scope) *parser.AstNode {
+	if isSubquery(node) {
+		return node
+	}
+
+	switch node.Value().(type) {
+	case *parser.OperatorNode, parser.FunctionNode, *parser.KeywordNode:
+		if !containsColumn(node) && !containsAggregate(node) && !containsWindow(node) {
+			if literal, ok := foldedLiteral(node, s); ok {
+				return literal
+			}
+		}
+	}
+
+	children := node.Children()
+	folded := make([]*parser.AstNode, 0, len(children))
+	changed := false
+	for _, child := range children {
+		f := foldConstants(child, s)
+		folded = append(folded, f)
+		changed = changed || f != child
+	}
+	if !changed {
+		return node
+	}
+
+	copied := parser.NewAstNode(node.Value())
+	for _, child := range folded {
+		copied.AppendChild(child)
+	}
+	return copied
+}
+
+// foldedLiteral evaluates a constant expression into a literal.
+func foldedLiteral(node *parser.AstNode, s *scope) (*parser.AstNode, bool) {
+	if keyword, ok := node.Value().(*parser.KeywordNode); ok && keyword.Value() != parser.CaseKeyword.String() {
+		return nil, false
+	}
+
+	t, err := expressionType(node, s)
+	if err != nil {
+		return nil, false
+	}
+	value, err := evalExpression(node, s)
+	if err != nil || valueType(value) != t {
+		return nil, false
+	}
+
+	var literal *parser.LiteralNode
+	switch v := value.(type) {
+	case nil:
+		literal = parser.NewLiteral(parser.NullLiteral, "null")
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		literal = parser.NewLiteral(parser.NumberLiteral, strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		literal = parser.NewLiteral(parser.StringLiteral, v)
+	case bool:
+		literal = parser.NewLiteral(parser.BooleanLiteral, strconv.FormatBool(v))
+	default:
+		return nil, false
+	}
+	return parser.NewAstNode(literal), true
+}
+
+func isLiteral(node *parser.AstNode) bool {
+	_, ok := node.Value().(*parser.LiteralNode)
+	return ok
+}
+
+// pushDown moves the conditions of 'where' joined by 'and' that only use
+// the columns of one joined source onto that source, so that its rows are
+// filtered before the join. Sources whose rows a left join fills with
+// nulls keep their conditions above the join.
+func pushDown(project *projectNode) {
+	parent, node := planNode(project), project.input
+	for {
+		switch n := node.(type) {
+		case *windowNode:
+			parent, node = n, n.input
+			continue
+		case *aggregateNode:
+			parent, node = n, n.input
+			continue
+		}
+		break
+	}
+
+	filter, ok := node.(*filterNode)
+	if !ok {
+		return
+	}
+	join, ok := filter.input.(*joinNode)
+	if !ok {
+		return
+	}
+
+	// the places holding the joined sources, which only take conditions
+	// when preserved is set
+	var slots []*planNode
+	var preserved []bool
+	for current := join; ; {
+		slots = append([]*planNode{&current.right}, slots...)
+		preserved = append([]bool{!current.leftJoin}, preserved...)
+
+		left, ok := current.left.(*joinNode)
+		if !ok {
+			slots = append([]*planNode{&current.left}, slots...)
+			preserved = append([]bool{true}, preserved...)
+			break
+		}
+		current = left
+	}
+
+	pushed := make([][]*parser.AstNode, len(slots))
+	var rest []*parser.AstNode
+	for _, conjunct := range conjuncts(filter.condition) {
+		target := -1
+		if !containsSubquery(conjunct) && !containsAggregate(conjunct) && !containsWindow(conjunct) {
+			target = conditionSource(conjunct, slots)
+		}
+		if target == -1 || !preserved[target] {
+			rest = append(rest, conjunct)
+			continue
+		}
+		pushed[target] = append(pushed[target], conjunct)
+	}
+
+	for i, conditions := range pushed {
+		if len(conditions) > 0 {
+			*slots[i] = &filterNode{input: *slots[i], condition: conjunction(conditions)}
+		}
+	}
+
+	if len(rest) > 0 {
+		filter.condition = conjunction(rest)
+		return
+	}
+	switch p := parent.(type) {
+	case *projectNode:
+		p.input = join
+	case *windowNode:
+		p.input = join
+	case *aggregateNode:
+		p.input = join
+	}
+}
+
+// conditionSource finds the only source of a join whose columns a condition
+// uses, or -1.
+func conditionSource(condition *parser.AstNode, slots []*planNode) int {
+	refs := make(map[string]struct{})
+	addColumns(refs, condition)
+
+	target := -1
+	for ref := range refs {
+		owner := -1
+		for i, slot := range slots {
+			if source, ok := (*slot).(*sourceNode); ok {
+				if _, ok := source.column(ref); ok {
+					owner = i
+					break
+				}
+			}
+		}
+		if owner == -1 || target != -1 && owner != target {
+			return -1
+		}
+		target = owner
+	}
+	return target
+}
+
+// conjunction joins conditions with 'and'.
+func conjunction(conditions []*parser.AstNode) *parser.AstNode {
+	result := conditions[0]
+	for _, condition := range conditions[1:] {
+		and := parser.NewAstNode(parser.NewOperator(parser.AndOperator))
+		and.AppendChild(result)
+		and.AppendChild(condition)
+		result = and
+	}
+	return result
+}
+
+// pruneColumns makes the sources of a select read only the columns its
+// expressions use, including the ones of its subqueries. When a source is
+// a subquery, the columns it does not read are not computed either.
+func pruneColumns(project *projectNode) {
+	refs := make(map[string]struct{})
+	var sources []*sourceNode
+
+	var walk func(node planNode)
+	walk = func(node planNode) {
+		switch n := node.(type) {
+		case *sourceNode:
+			sources = append(sources, n)
+			return
+		case *filterNode:
+			addColumns(refs, n.condition)
+		case *joinNode:
+			addColumns(refs, n.on)
+		case *aggregateNode:
+			for _, expression := range n.groupBy {
+				addColumns(refs, expression)
+			}
+			for _, call := range n.calls {
+				addColumns(refs, call)
+			}
+			for _, column := range n.columns {
+				addColumns(refs, column.expression)
+			}
+		case *windowNode:
+			for _, call := range n.calls {
+				addColumns(refs, call)
+			}
+		case *projectNode:
+			for _, column := range n.columns {
+				addColumns(refs, column.expression)
+			}
+		}
+
+		for _, input := range node.inputs() {
+			walk(input)
+		}
+	}
+	walk(project)
+
+	for _, source := range sources {
+		keys := make([]sourceKey, 0, len(source.keys))
+		for _, key := range source.keys {
+			_, used := refs[key.key]
+			_, qualified := refs[source.name()+"."+key.column]
+			if used || !source.joined && qualified {
+				keys = append(keys, key)
+			}
+		}
+		source.keys = keys
+
+		query, ok := source.query.(*projectNode)
+		if !ok {
+			continue
+		}
+		read := source.read()
+		var columns []selectColumn
+		for _, column := range query.columns {
+			if slices.Contains(read, column.name) {
+				columns = append(columns, column)
+			}
+		}
+		query.columns = columns
+		query.visible = len(columns)
+	}
+}
+
+// useIndex makes a source of a loaded table find its rows with an index,
+// when the filter above it compares an indexed column with a constant. The
+// filter still checks the whole condition.
+func (c *Engine) useIndex(filter *filterNode, source *sourceNode, s *scope) {
+	if source.kind != loadedSource || source.query != nil {
+		return
+	}
+	table, err := s.loadedTable(source.from)
+	if err != nil {
+		return
+	}
+
+	// the constants are known, so the index matching the fewest rows is used
+	best := -1
+	for _, conjunct := range conjuncts(filter.condition) {
+		name, other, ok := equality(conjunct)
+		if !ok || containsColumn(other) {
+			continue
+		}
+		column, ok := source.column(name)
+		if !ok {
+			continue
+		}
+		index, ok := table.index(column)
+		if !ok {
+			continue
+		}
+
+		value, err := evalExpression(other, c.newScope(context.Background()))
+		if err != nil {
+			continue
+		}
+		positions, ok := index.lookup(value)
+		if !ok || best != -1 && len(positions) >= best {
+			continue
+		}
+
+		best = len(positions)
+		source.index = &indexLookup{
+			name:      index.name,
+			column:    column,
+			value:     other,
+			condition: conjunct,
+		}
+	}
+}
+
+// useHashJoin makes a join a hash join when its condition compares a
+// column of the joined source with '=' to an expression over the rows
+// before it. An index of the column is reused when the source is a loaded
+// table read as a whole.
+func (c *Engine) useHashJoin(join *joinNode, s *scope) {
+	right := join.right
+	if filter, ok := right.(*filterNode); ok {
+		right = filter.input
+	}
+	source, ok := right.(*sourceNode)
+	if !ok {
+		return
+	}
+	own := func(name string) bool {
+		_, ok := source.column(name)
+		return ok
+	}
+
+	for _, conjunct := range conjuncts(join.on) {
+		operator, ok := conjunct.Value().(*parser.OperatorNode)
+		if !ok || operator.OperatorType() != parser.EqualOperator || len(conjunct.Children()) != 2 {
+			continue
+		}
+
+		for side, node := range conjunct.Children() {
+			name, ok := node.Value().(parser.ColumnNode)
+			if !ok || !own(name.Value()) {
+				continue
+			}
+			probe := conjunct.Children()[1-side]
+			if refersTo(probe, own) {
+				continue
+			}
+
+			column, _ := source.column(name.Value())
+			join.hash = &hashKey{key: name.Value(), probe: probe, column: column}
+			if join.right != right || source.kind != loadedSource || source.query != nil || source.index != nil {
+				return
+			}
+			if table, err := s.loadedTable(source.from); err == nil {
+				if index, ok := table.index(column); ok {
+					join.hash.index = index.name
+				}
+			}
+			return
+		}
+	}
+}
+
+// addColumns adds the columns an expression refers to, including the ones
+// of its subqueries.
+func addColumns(refs map[string]struct{}, node *parser.AstNode) {
+	if column, ok := node.Value().(parser.ColumnNode); ok && column.Value() != "*" {
+		refs[column.Value()] = struct{}{}
+	}
+	for _, child := range node.Children() {
+		addColumns(refs, child)
+	}
+}
+
+func containsSubquery(node *parser.AstNode) bool {
+	if isSubquery(node) {
+		return true
+	}
+	for _, child := range node.Children() {
+		if containsSubquery(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var (
+	ErrUnknownOption  = errors.New("unknown option")
+	ErrInvalidTimeout = errors.New("timeout must be a non-negative number of seconds or a duration like '1m30s'")
+)
+
+// TimeoutOption bounds how long a statement may run. It is off when zero.
+const TimeoutOption = "timeout"
+
+// setCommand runs a 'set' command and describes the new setting.
+func (c *Engine) setCommand(query *parser.AstNode) (string, error) {
+	option, ok := util.At(query.Children(), 0)
+	if !ok {
+		return "", parser.ErrMissingOptionSetCommand
+	}
+	valueNode, ok := util.At(query.Children(), 1)
+	if !ok {
+		return "", parser.ErrMissingExpression
+	}
+
+	value, err := evalExpression(valueNode, c.newScope(context.Background()))
+	if err != nil {
+		return "", fmt.Errorf("'set' command: %w", err)
+	}
+
+	switch option.Value().Value() {
+	case TimeoutOption:
+		timeout, err := ParseTimeout(value)
+		if err != nil {
+			return "", err
+		}
+
+		c.timeout.Store(int64(timeout))
+		if timeout == 0 {
+			return "Disabled timeout", nil
+		}
+		return fmt.Sprintf("Set timeout to %s", timeout), nil
+	}
+
+	return "", fmt.Errorf("%w: '%s'", ErrUnknownOption, option.Value().Value())
+}
+
+// ParseTimeout reads a timeout given as a number of seconds or as a string
+// holding a Go duration, like "1m30s".
+func ParseTimeout(value any) (time.Duration, error) {
+	var timeout time.Duration
+	switch v := value.(type) {
+	case float64:
+		timeout = time.Duration(v * float64(time.Second))
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("%w, got '%s'", ErrInvalidTimeout, v)
+		}
+		timeout = d
+	default:
+		return 0, fmt.Errorf("%w, got %v", ErrInvalidTimeout, value)
+	}
+
+	if timeout < 0 {
+		return 0, fmt.Errorf("%w, got %s", ErrInvalidTimeout, timeout)
+	}
+	return timeout, nil
+}
+
+// withTimeout bounds ctx by the timeout option, if it is set.
+func (c *Engine) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(c.timeout.Load())
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
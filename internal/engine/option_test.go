@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     string
+		want    time.Duration
+		wantErr error
+	}{
+		{
+			name: "seconds",
+			cmd:  "set timeout 1.5;",
+			want: 1500 * time.Millisecond,
+		},
+		{
+			name: "duration",
+			cmd:  "set timeout '1m30s';",
+			want: 90 * time.Second,
+		},
+		{
+			name: "disabled",
+			cmd:  "set timeout 0;",
+		},
+		{
+			name:    "negative",
+			cmd:     "set timeout -1;",
+			wantErr: ErrInvalidTimeout,
+		},
+		{
+			name:    "wrong duration",
+			cmd:     "set timeout 'soon';",
+			wantErr: ErrInvalidTimeout,
+		},
+		{
+			name:    "boolean",
+			cmd:     "set timeout true;",
+			wantErr: ErrInvalidTimeout,
+		},
+		{
+			name:    "unknown option",
+			cmd:     "set speed 1;",
+			wantErr: ErrUnknownOption,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, nil)
+			e.timeout.Store(int64(time.Hour))
+
+			err := e.Process(context.Background(), parseQuery(t, tt.cmd))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && time.Duration(e.timeout.Load()) != tt.want {
+				t.Errorf("timeout = %s, want %s", time.Duration(e.timeout.Load()), tt.want)
+			}
+		})
+	}
+}
+
+func Test_Cancel(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queries := []string{
+		"select id from t;",
+		"select id from t where id in (select id from t);",
+		"update t set score = 0;",
+		"delete from t;",
+		"create table u as select id from t;",
+	}
+	for _, query := range queries {
+		if err := e.Process(canceled, parseQuery(t, query)); !errors.Is(err, context.Canceled) {
+			t.Errorf("Process(%s) error = %v, want %v", query, err, context.Canceled)
+		}
+	}
+
+	got, err := runSelect(t, e, "select count(*) from t where score = 0;")
+	if err != nil {
+		t.Fatalf("selectQuery() error = %v", err)
+	}
+	if got.rows[0][0] != 0.0 {
+		t.Errorf("canceled update changed %v rows", got.rows[0][0])
+	}
+	if _, err := e.loadedTable("u"); err == nil {
+		t.Errorf("canceled create made table 'u'")
+	}
+}
+
+func Test_CursorTimeout(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	if err := e.Process(context.Background(), parseQuery(t, "set timeout '1ms';")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	cursor, err := e.Query(context.Background(), parseQuery(t, "select id from t;"))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer cursor.Close()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := cursor.Next(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func Test_LoadCanceled(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "t.json")
+	data := "[" + strings.Repeat(`{"id": 1},`, 10) + `{"id": 2}]`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(io.Discard)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := e.LoadTable(canceled, filename, "t"); !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadTable() error = %v, want %v", err, context.Canceled)
+	}
+	if err := e.LoadTable(context.Background(), filename, "t"); err != nil {
+		t.Fatalf("LoadTable() error = %v", err)
+	}
+	if table, _ := e.loadedTable("t"); len(table.rows) != 11 {
+		t.Errorf("LoadTable() rows = %d, want 11", len(table.rows))
+	}
+}
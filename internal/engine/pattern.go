@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxPatterns bounds the compiled patterns kept, since patterns can come
+// from rows and from the queries of clients
+const maxPatterns = 128
+
+// patternCache keeps the most recently used compiled patterns, keyed by
+// their source, since a query usually applies the same pattern to every
+// row.
+type patternCache struct {
+	mu sync.Mutex
+	// elements hold the patterns, most recently used first
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+var patterns = &patternCache{order: list.New(), elements: make(map[string]*list.Element)}
+
+func (c *patternCache) get(pattern string) (*regexp.Regexp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.elements[pattern]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*regexp.Regexp), true
+}
+
+func (c *patternCache) add(re *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pattern := re.String()
+	if element, ok := c.elements[pattern]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.elements[pattern] = c.order.PushFront(re)
+	if c.order.Len() > maxPatterns {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elements, oldest.Value.(*regexp.Regexp).String())
+	}
+}
+
+// likePattern translates a 'like' pattern, where '%' matches any sequence,
+// '_' any single character and '\' escapes the next one, into a regexp.
+func likePattern(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+
+	if caseInsensitive {
+		sb.WriteString("(?is)^")
+	} else {
+		sb.WriteString("(?s)^")
+	}
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("invalid 'like' pattern '%s': trailing escape", pattern)
+	}
+	sb.WriteString("$")
+
+	return compilePattern(sb.String())
+}
+
+// regexPattern compiles a regular expression, applying flags such as 'i'
+// for case insensitive matching.
+func regexPattern(pattern string, flags string) (*regexp.Regexp, error) {
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 's', 'm':
+		default:
+			return nil, fmt.Errorf("invalid regular expression flag '%c'", flag)
+		}
+	}
+
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	return compilePattern(pattern)
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.get(pattern); ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %w", pattern, err)
+	}
+	patterns.add(re)
+
+	return re, nil
+}
@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_PatternCache(t *testing.T) {
+	first, err := likePattern("a%", false)
+	if err != nil {
+		t.Fatalf("likePattern() error = %v", err)
+	}
+
+	for i := range 2 * maxPatterns {
+		if _, err := likePattern(fmt.Sprintf("p%d", i), false); err != nil {
+			t.Fatalf("likePattern() error = %v", err)
+		}
+		// the first pattern stays, since it is used again
+		if _, err := likePattern("a%", false); err != nil {
+			t.Fatalf("likePattern() error = %v", err)
+		}
+	}
+
+	if n := patterns.order.Len(); n != maxPatterns || len(patterns.elements) != maxPatterns {
+		t.Errorf("cached patterns = %d, want %d", n, maxPatterns)
+	}
+	if re, ok := patterns.get(first.String()); !ok || re != first {
+		t.Errorf("get(%s) = %v, %t, want the first compiled pattern", first, re, ok)
+	}
+	if _, ok := patterns.get("(?s)^p0$"); ok {
+		t.Errorf("get((?s)^p0$) found an evicted pattern")
+	}
+}
@@ -0,0 +1,763 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+// planNode is an operator of a query plan. Statements are planned into a
+// tree of nodes, which is optimized before the engine runs it.
+type planNode interface {
+	// label describes the node for 'explain'
+	label() string
+	inputs() []planNode
+}
+
+// sourceKind tells where the rows of a named source come from.
+type sourceKind int
+
+const (
+	loadedSource sourceKind = iota
+	viewSource
+	withSource
+)
+
+// sourceKey is a column of a source, stored under key in the rows read
+// from it.
+type sourceKey struct {
+	key    string
+	column string
+}
+
+// sourceNode reads the rows of a table, a view, a table defined by 'with'
+// or a subquery in 'from'.
+type sourceNode struct {
+	tableSource
+	kind sourceKind
+	// plan of the subquery in 'from'
+	query planNode
+	// columns of the source, in the order '*' expands to
+	all []string
+	// columns read, which joined sources store under keys like 'u.id'
+	keys   []sourceKey
+	joined bool
+	// set when an index finds the rows
+	index *indexLookup
+}
+
+// indexLookup finds the rows of a source with an index, for the condition
+// comparing the indexed column with a constant.
+type indexLookup struct {
+	name      string
+	column    string
+	value     *parser.AstNode
+	condition *parser.AstNode
+}
+
+type filterNode struct {
+	input     planNode
+	condition *parser.AstNode
+}
+
+// joinNode joins the rows of a source to the rows of the sources before it.
+type joinNode struct {
+	left     planNode
+	right    planNode
+	leftJoin bool
+	on       *parser.AstNode
+	// plain names shared by several of the joined sources
+	ambiguous map[string]struct{}
+	// set when the rows of right are found by a hash join
+	hash *hashKey
+}
+
+// hashKey is the column of the right side of a hash join and the
+// expression over the left rows looked up in it.
+type hashKey struct {
+	key   string
+	probe *parser.AstNode
+	// index of a loaded table reused for the join, if any
+	index  string
+	column string
+}
+
+type aggregateNode struct {
+	input   planNode
+	groupBy []*parser.AstNode
+	calls   []*parser.AstNode
+	// select columns rewritten by aggregateColumns, to check grouping
+	columns []selectColumn
+}
+
+type windowNode struct {
+	input planNode
+	calls []*parser.AstNode
+}
+
+// projectNode computes the select columns. Columns after the visible ones
+// are only used for sorting.
+type projectNode struct {
+	input   planNode
+	columns []selectColumn
+	visible int
+}
+
+// sortNode sorts a result by the columns at the given indexes and drops
+// its hidden columns.
+type sortNode struct {
+	input      planNode
+	indexes    []int
+	descending []bool
+	names      []string
+}
+
+// limitNode passes on count rows of its input after skipping offset rows.
+// Both are constant expressions, and offset may be nil.
+type limitNode struct {
+	input  planNode
+	count  *parser.AstNode
+	offset *parser.AstNode
+}
+
+type distinctNode struct {
+	input planNode
+}
+
+type setNode struct {
+	operator string
+	all      bool
+	left     planNode
+	right    planNode
+}
+
+// withNode materializes the tables defined by 'with' before running the
+// statement that uses them.
+type withNode struct {
+	tables []*withTable
+	body   planNode
+}
+
+type withTable struct {
+	name string
+	plan planNode
+}
+
+func (n *sourceNode) label() string {
+	var sb strings.Builder
+	switch {
+	case n.index != nil:
+		fmt.Fprintf(&sb, "IndexScan %s using %s (%s)", n.from, n.index.name, n.index.condition.Text())
+	case n.query != nil:
+		sb.WriteString("Subquery")
+	case n.kind == viewSource:
+		sb.WriteString("Scan view " + n.from)
+	case n.kind == withSource:
+		sb.WriteString("Scan with " + n.from)
+	default:
+		sb.WriteString("Scan " + n.from)
+	}
+
+	if n.alias != "" {
+		sb.WriteString(" as " + n.alias)
+	}
+	if read := n.read(); len(read) < len(n.all) {
+		sb.WriteString(" (" + strings.Join(read, ", ") + ")")
+	}
+	return sb.String()
+}
+
+func (n *sourceNode) inputs() []planNode {
+	if n.query == nil {
+		return nil
+	}
+	return []planNode{n.query}
+}
+
+// read lists the columns of the source that are read, in their order.
+func (n *sourceNode) read() []string {
+	var read []string
+	for _, column := range n.all {
+		if slices.ContainsFunc(n.keys, func(key sourceKey) bool { return key.column == column }) {
+			read = append(read, column)
+		}
+	}
+	return read
+}
+
+// column finds the column of the source a column of an expression refers to.
+func (n *sourceNode) column(name string) (string, bool) {
+	for _, key := range n.keys {
+		if key.key == name {
+			return key.column, true
+		}
+	}
+
+	qualifier, column, ok := strings.Cut(name, ".")
+	if !n.joined && ok && qualifier == n.name() && slices.Contains(n.all, column) {
+		return column, true
+	}
+	return "", false
+}
+
+// star lists the keys '*' expands to, with the plain names of their columns.
+func (n *sourceNode) star() []sourceKey {
+	keys := make([]sourceKey, 0, len(n.all))
+	for _, column := range n.all {
+		key := column
+		if n.joined {
+			key = n.name() + "." + column
+		}
+		keys = append(keys, sourceKey{key: key, column: column})
+	}
+	return keys
+}
+
+func (n *filterNode) label() string      { return "Filter " + n.condition.Text() }
+func (n *filterNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *joinNode) label() string {
+	kind := "NestedLoop"
+	if n.hash != nil {
+		kind = "Hash"
+	}
+	if n.leftJoin {
+		kind += "Left"
+	}
+
+	label := kind + "Join on " + n.on.Text()
+	if n.hash != nil && n.hash.index != "" {
+		label += " using " + n.hash.index
+	}
+	return label
+}
+
+func (n *joinNode) inputs() []planNode { return []planNode{n.left, n.right} }
+
+func (n *aggregateNode) label() string {
+	label := "Aggregate"
+	if len(n.groupBy) > 0 {
+		label += " by " + texts(n.groupBy)
+	}
+	if len(n.calls) > 0 {
+		label += ": " + texts(n.calls)
+	}
+	return label
+}
+
+func (n *aggregateNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *windowNode) label() string      { return "Window: " + texts(n.calls) }
+func (n *windowNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *projectNode) label() string {
+	columns := make([]string, 0, n.visible)
+	for _, column := range n.columns[:n.visible] {
+		text := column.expression.Text()
+		// columns named after themselves, qualified or not, need no alias
+		if text != column.name && !strings.HasSuffix(text, "."+column.name) {
+			text += " as " + column.name
+		}
+		columns = append(columns, text)
+	}
+	return "Project " + strings.Join(columns, ", ")
+}
+
+func (n *projectNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *sortNode) label() string {
+	items := make([]string, 0, len(n.names))
+	for i, name := range n.names {
+		if n.descending[i] {
+			name += " desc"
+		}
+		items = append(items, name)
+	}
+	return "Sort " + strings.Join(items, ", ")
+}
+
+func (n *sortNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *limitNode) label() string {
+	label := "Limit " + n.count.Text()
+	if n.offset != nil {
+		label += " offset " + n.offset.Text()
+	}
+	return label
+}
+
+func (n *limitNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *distinctNode) label() string      { return "Distinct" }
+func (n *distinctNode) inputs() []planNode { return []planNode{n.input} }
+
+func (n *setNode) label() string {
+	label := strings.ToUpper(n.operator[:1]) + n.operator[1:]
+	if n.all {
+		label += " all"
+	}
+	return label
+}
+
+func (n *setNode) inputs() []planNode { return []planNode{n.left, n.right} }
+
+func (n *withNode) label() string { return "With" }
+
+func (n *withNode) inputs() []planNode {
+	inputs := make([]planNode, 0, len(n.tables)+1)
+	for _, table := range n.tables {
+		inputs = append(inputs, table)
+	}
+	return append(inputs, n.body)
+}
+
+func (n *withTable) label() string      { return "Table " + n.name }
+func (n *withTable) inputs() []planNode { return []planNode{n.plan} }
+
+func texts(nodes []*parser.AstNode) string {
+	result := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, node.Text())
+	}
+	return strings.Join(result, ", ")
+}
+
+// explain draws a plan as a tree, one node per line.
+func explain(plan planNode) string {
+	return util.Tree(plan, planNode.label, planNode.inputs)
+}
+
+// outputNames lists the names of the result columns of a plan.
+func outputNames(node planNode) []string {
+	switch n := node.(type) {
+	case *projectNode:
+		names := make([]string, 0, n.visible)
+		for _, column := range n.columns[:n.visible] {
+			names = append(names, column.name)
+		}
+		return names
+	case *sortNode:
+		return outputNames(n.input)
+	case *distinctNode:
+		return outputNames(n.input)
+	case *limitNode:
+		return outputNames(n.input)
+	case *setNode:
+		return outputNames(n.left)
+	case *withNode:
+		return outputNames(n.body)
+	}
+	return nil
+}
+
+// uniqueNames drops repeated names, as tables made from results do.
+func uniqueNames(names []string) []string {
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		if !slices.Contains(unique, name) {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// planQuery plans a statement run in the scope s and optimizes the plan.
+func (c *Engine) planQuery(statement *selectStatement, s *scope) (planNode, error) {
+	plan, err := newPlanner(s).plan(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	c.optimize(plan, s)
+	return plan, nil
+}
+
+// planner builds the plan of a statement. Plans are built before any row
+// is read, so the planner only knows the names of columns, not their types.
+type planner struct {
+	engine *Engine
+	// scope the statement runs in, with the tables of enclosing statements
+	scope *scope
+	// columns of the tables defined by 'with'
+	tables map[string][]string
+	outer  *planner
+	// views being planned, innermost last
+	views []string
+}
+
+func newPlanner(s *scope) *planner {
+	return &planner{
+		engine: s.engine,
+		scope:  s,
+		tables: make(map[string][]string),
+		views:  s.views,
+	}
+}
+
+// nested creates the planner of a query enclosed by p.
+func (p *planner) nested() *planner {
+	return &planner{
+		engine: p.engine,
+		scope:  p.scope,
+		tables: make(map[string][]string),
+		outer:  p,
+		views:  p.views,
+	}
+}
+
+// columns finds the columns of a named source and where its rows come from,
+// looking up tables in the same order as scope.table.
+func (p *planner) columns(name string) ([]string, sourceKind, error) {
+	for current := p; current != nil; current = current.outer {
+		if columns, ok := current.tables[name]; ok {
+			return columns, withSource, nil
+		}
+	}
+	for current := p.scope; current != nil; current = current.outer {
+		if table, ok := current.tables[name]; ok {
+			return table.columnNames(), withSource, nil
+		}
+	}
+
+	if query, ok := p.scope.catalog.views[name]; ok {
+		if slices.Contains(p.views, name) {
+			return nil, 0, fmt.Errorf("view '%s' refers to itself", name)
+		}
+
+		statement, err := newSelectStatement(query)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		inner := newPlanner(p.engine.newScope(p.scope.ctx))
+		inner.views = append(slices.Clone(p.views), name)
+		plan, err := inner.plan(statement)
+		if err != nil {
+			return nil, 0, fmt.Errorf("view '%s': %w", name, err)
+		}
+		return uniqueNames(outputNames(plan)), viewSource, nil
+	}
+
+	table, err := p.scope.loadedTable(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return table.columnNames(), loadedSource, nil
+}
+
+func (p *planner) plan(statement *selectStatement) (planNode, error) {
+	if len(statement.with) == 0 {
+		return p.planBody(statement)
+	}
+
+	with := &withNode{}
+	for _, cte := range statement.with {
+		plan, err := p.nested().plan(cte.statement)
+		if err != nil {
+			return nil, fmt.Errorf("'with' table '%s': %w", cte.name, err)
+		}
+
+		p.tables[cte.name] = uniqueNames(outputNames(plan))
+		with.tables = append(with.tables, &withTable{name: cte.name, plan: plan})
+	}
+
+	body, err := p.planBody(statement)
+	if err != nil {
+		return nil, err
+	}
+	with.body = body
+	return with, nil
+}
+
+func (p *planner) planBody(statement *selectStatement) (planNode, error) {
+	if statement.set != nil {
+		return p.planSet(statement)
+	}
+	return p.planSelect(statement)
+}
+
+func (p *planner) planSet(statement *selectStatement) (planNode, error) {
+	set := statement.set
+
+	left, err := p.nested().plan(set.left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.nested().plan(set.right)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &setNode{operator: set.operator, all: set.all, left: left, right: right}
+	if len(statement.orderBy) == 0 {
+		return p.planLimit(statement, node), nil
+	}
+
+	names := outputNames(left)
+	selected := make([]selectColumn, 0, len(names))
+	for _, name := range names {
+		selected = append(selected, selectColumn{
+			expression: parser.NewAstNode(parser.ColumnNode(name)),
+			name:       name,
+		})
+	}
+
+	sort := &sortNode{input: node}
+	for _, item := range statement.orderBy {
+		index, err := outputColumn(item.expression, selected)
+		if err != nil {
+			return nil, err
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("'order by' of '%s' must name an output column, got '%s'", set.operator, item.expression.Text())
+		}
+
+		sort.indexes = append(sort.indexes, index)
+		sort.descending = append(sort.descending, item.descending)
+		sort.names = append(sort.names, names[index])
+	}
+	return p.planLimit(statement, sort), nil
+}
+
+func (p *planner) planSelect(statement *selectStatement) (planNode, error) {
+	input, sources, err := p.planSources(statement)
+	if err != nil {
+		return nil, err
+	}
+	constants := p.engine.newScope(p.scope.ctx)
+
+	selected := make([]selectColumn, 0, len(statement.columns))
+	for _, column := range statement.columns {
+		if column.expression.Value() == parser.ColumnNode("*") {
+			for _, source := range sources {
+				for _, key := range source.star() {
+					selected = append(selected, selectColumn{
+						expression: parser.NewAstNode(parser.ColumnNode(key.key)),
+						name:       key.column,
+					})
+				}
+			}
+			continue
+		}
+
+		// qualified columns are named without their qualifier
+		if node, ok := column.expression.Value().(parser.ColumnNode); ok && column.name == node.Value() {
+			for _, source := range sources {
+				if name, ok := source.column(node.Value()); ok {
+					column.name = name
+					break
+				}
+			}
+		}
+
+		column.expression = foldConstants(column.expression, constants)
+		selected = append(selected, column)
+	}
+
+	if statement.where != nil {
+		// conditions folded into true always hold
+		var conditions []*parser.AstNode
+		for _, condition := range conjuncts(foldConstants(statement.where, constants)) {
+			if literal, ok := condition.Value().(*parser.LiteralNode); !ok ||
+				literal.LiteralType() != parser.BooleanLiteral || literal.Value() != "true" {
+				conditions = append(conditions, condition)
+			}
+		}
+		if len(conditions) > 0 {
+			input = &filterNode{input: input, condition: conjunction(conditions)}
+		}
+	}
+
+	groupBy := make([]*parser.AstNode, 0, len(statement.groupBy))
+	for _, expression := range statement.groupBy {
+		groupBy = append(groupBy, foldConstants(expression, constants))
+	}
+
+	// order by items that are not output columns are computed as hidden
+	// columns after the visible ones
+	visible := len(selected)
+	sort := &sortNode{}
+	for _, item := range statement.orderBy {
+		// items folded into a literal would turn into positions
+		expression := item.expression
+		if folded := foldConstants(expression, constants); !isLiteral(folded) {
+			expression = folded
+		}
+
+		index, err := outputColumn(expression, selected[:visible])
+		if err != nil {
+			return nil, err
+		}
+		if index == -1 {
+			index = len(selected)
+			selected = append(selected, selectColumn{
+				expression: expression,
+				name:       expression.Text(),
+			})
+		}
+
+		sort.indexes = append(sort.indexes, index)
+		sort.descending = append(sort.descending, item.descending)
+		sort.names = append(sort.names, selected[index].name)
+	}
+
+	aggregated := len(groupBy) > 0
+	windowed := false
+	for _, column := range selected {
+		aggregated = aggregated || containsAggregate(column.expression)
+		windowed = windowed || containsWindow(column.expression)
+	}
+	if aggregated {
+		calls, columns := aggregateColumns(groupBy, selected)
+		input = &aggregateNode{input: input, groupBy: groupBy, calls: calls, columns: columns}
+		selected = columns
+	}
+	if windowed {
+		calls, columns := windowColumns(selected)
+		input = &windowNode{input: input, calls: calls}
+		selected = columns
+	}
+
+	var result planNode = &projectNode{input: input, columns: selected, visible: visible}
+	if len(sort.indexes) > 0 {
+		sort.input = result
+		result = sort
+	}
+	if statement.distinct {
+		result = &distinctNode{input: result}
+	}
+	return p.planLimit(statement, result), nil
+}
+
+// planLimit adds the 'limit' of a statement on top of its plan.
+func (p *planner) planLimit(statement *selectStatement, input planNode) planNode {
+	if statement.limit == nil {
+		return input
+	}
+
+	constants := p.engine.newScope(p.scope.ctx)
+	limit := &limitNode{input: input, count: foldConstants(statement.limit, constants)}
+	if statement.offset != nil {
+		limit.offset = foldConstants(statement.offset, constants)
+	}
+	return limit
+}
+
+// planSources plans the sources of a statement and the joins between them.
+// Columns of joined sources are keyed by their source, as in 'u.id', and
+// also by their plain name when no other source has a column of that name.
+func (p *planner) planSources(statement *selectStatement) (planNode, []*sourceNode, error) {
+	if len(statement.joins) == 0 {
+		source, err := p.source(statement.tableSource)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, column := range source.all {
+			source.keys = append(source.keys, sourceKey{key: column, column: column})
+		}
+		return source, []*sourceNode{source}, nil
+	}
+
+	tableSources := []tableSource{statement.tableSource}
+	for _, join := range statement.joins {
+		tableSources = append(tableSources, join.tableSource)
+	}
+
+	sources := make([]*sourceNode, 0, len(tableSources))
+	seen := make(map[string]struct{})
+	counts := make(map[string]int)
+	for _, t := range tableSources {
+		name := t.name()
+		if name == "" {
+			return nil, nil, fmt.Errorf("joined subquery in 'from' must have an alias")
+		}
+		if _, ok := seen[name]; ok {
+			return nil, nil, fmt.Errorf("table name '%s' specified more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		source, err := p.source(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, column := range source.all {
+			counts[column]++
+		}
+		sources = append(sources, source)
+	}
+
+	for _, source := range sources {
+		source.joined = true
+		for _, column := range source.all {
+			source.keys = append(source.keys, sourceKey{key: source.name() + "." + column, column: column})
+			if counts[column] == 1 {
+				source.keys = append(source.keys, sourceKey{key: column, column: column})
+			}
+		}
+	}
+
+	ambiguous := make(map[string]struct{})
+	addAmbiguous := func(source *sourceNode) {
+		for _, column := range source.all {
+			if counts[column] > 1 {
+				ambiguous[column] = struct{}{}
+			}
+		}
+	}
+
+	constants := p.engine.newScope(p.scope.ctx)
+	addAmbiguous(sources[0])
+	var input planNode = sources[0]
+	for i, join := range statement.joins {
+		addAmbiguous(sources[i+1])
+		input = &joinNode{
+			left:      input,
+			right:     sources[i+1],
+			leftJoin:  join.left,
+			on:        foldConstants(join.on, constants),
+			ambiguous: maps.Clone(ambiguous),
+		}
+	}
+
+	return input, sources, nil
+}
+
+// source plans the rows of a table or of a subquery in 'from'.
+func (p *planner) source(t tableSource) (*sourceNode, error) {
+	node := &sourceNode{tableSource: t}
+	if t.fromQuery != nil {
+		plan, err := p.nested().plan(t.fromQuery)
+		if err != nil {
+			return nil, fmt.Errorf("subquery in 'from': %w", err)
+		}
+		node.query = plan
+		node.all = uniqueNames(outputNames(plan))
+		return node, nil
+	}
+
+	columns, kind, err := p.columns(t.from)
+	if err != nil {
+		return nil, err
+	}
+	node.all, node.kind = columns, kind
+	return node, nil
+}
+
+// explainCommand plans a query without running it and draws its plan.
+func (c *Engine) explainCommand(ctx context.Context, query *parser.AstNode) (string, error) {
+	statement, err := newSelectStatement(query)
+	if err != nil {
+		return "", err
+	}
+
+	plan, err := c.planQuery(statement, c.newScope(ctx))
+	if err != nil {
+		return "", err
+	}
+	return explain(plan), nil
+}
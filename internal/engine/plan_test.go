@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Explain(t *testing.T) {
+	var out bytes.Buffer
+	e := newTestEngine(t, map[string][]Row{"people": members, "baskets": baskets})
+	e.writer = &out
+	if err := e.Process(context.Background(), parseQuery(t, "create index on people (id);")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "index scan with folded constants",
+			cmd:  "explain select name from people where id = 1 + 1 and 2 > 1;",
+			want: "Project name\n" +
+				"└── Filter id = 2\n" +
+				"    └── IndexScan people using people_id_idx (id = 2) (id, name)\n",
+		},
+		{
+			name: "conditions pushed below a hash join",
+			cmd: "explain select p.name, count(*) from people p join baskets o on o.person = p.id " +
+				"where p.team = 'red' and o.id > 10 + 1 group by p.name order by 2 desc;",
+			want: "Sort count(*) desc\n" +
+				"└── Project p.name, count(*)\n" +
+				"    └── Aggregate by p.name: count(*)\n" +
+				"        └── HashJoin on o.person = p.id\n" +
+				"            ├── Filter p.team = 'red'\n" +
+				"            │   └── Scan people as p\n" +
+				"            └── Filter o.id > 11\n" +
+				"                └── Scan baskets as o (id, person)\n",
+		},
+		{
+			name: "left join keeps conditions on its nullable side",
+			cmd:  "explain select o.item, p.name from baskets o left join people p on p.id = o.person where p.team = 'red' and o.item <> 'box';",
+			want: "Project o.item, p.name\n" +
+				"└── Filter p.team = 'red'\n" +
+				"    └── HashLeftJoin on p.id = o.person using people_id_idx\n" +
+				"        ├── Filter o.item <> 'box'\n" +
+				"        │   └── Scan baskets as o (item, person)\n" +
+				"        └── Scan people as p\n",
+		},
+		{
+			name: "nested loop join",
+			cmd:  "explain select p.name, o.id from people p join baskets o on o.id < 11 + p.id;",
+			want: "Project p.name, o.id\n" +
+				"└── NestedLoopJoin on o.id < 11 + p.id\n" +
+				"    ├── Scan people as p (id, name)\n" +
+				"    └── Scan baskets as o (id)\n",
+		},
+		{
+			name: "unused columns of a subquery",
+			cmd:  "explain select x from (select name as x, id * 2 as y from people) s;",
+			want: "Project x\n" +
+				"└── Subquery as s (x)\n" +
+				"    └── Project name as x\n" +
+				"        └── Scan people (name)\n",
+		},
+		{
+			name: "with and set operation",
+			cmd:  "explain with r as (select * from people where team = 'red') select name from r union select item from baskets order by 1;",
+			want: "With\n" +
+				"├── Table r\n" +
+				"│   └── Project id, name, team\n" +
+				"│       └── Filter team = 'red'\n" +
+				"│           └── Scan people\n" +
+				"└── Sort name\n" +
+				"    └── Union\n" +
+				"        ├── Project name\n" +
+				"        │   └── Scan with r (name)\n" +
+				"        └── Project item\n" +
+				"            └── Scan baskets (item)\n",
+		},
+		{
+			name: "limit over a sort",
+			cmd:  "explain select name from people order by id limit 1 + 1 offset 1;",
+			want: "Limit 2 offset 1\n" +
+				"└── Sort id\n" +
+				"    └── Project name\n" +
+				"        └── Scan people (id, name)\n",
+		},
+		{
+			name: "distinct and window",
+			cmd:  "explain select distinct team, row_number() over (order by id) as n from people;",
+			want: "Distinct\n" +
+				"└── Project team, row_number() over (order by id) as n\n" +
+				"    └── Window: row_number() over (order by id)\n" +
+				"        └── Scan people (id, team)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			if err := e.Process(context.Background(), parseQuery(t, tt.cmd)); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Process() output =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_OptimizedSelect(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"people": members, "baskets": baskets})
+	if err := e.Process(context.Background(), parseQuery(t, "create index on people (id);")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		cmd      string
+		wantRows [][]any
+	}{
+		{
+			name:     "condition on the nullable side of a left join",
+			cmd:      "select o.item, p.name from baskets o left join people p on p.id = o.person where p.name is null order by o.id;",
+			wantRows: [][]any{{"box", nil}, {"bag", nil}},
+		},
+		{
+			name:     "condition on both sides",
+			cmd:      "select o.item from baskets o join people p on p.id = o.person where p.id + o.id = 12 or o.id = 12 order by o.id;",
+			wantRows: [][]any{{"ink"}, {"cap"}},
+		},
+		{
+			name:     "folded condition that never holds",
+			cmd:      "select name from people where 1 > 2;",
+			wantRows: [][]any{},
+		},
+		{
+			name:     "ordered by a constant expression",
+			cmd:      "select name from people order by 1 + 1, name desc;",
+			wantRows: [][]any{{"cid"}, {"bob"}, {"ann"}},
+		},
+		{
+			name:     "grouped by a folded expression",
+			cmd:      "select id * (1 + 1) as double, count(*) from people group by id * (1 + 1) order by 1;",
+			wantRows: [][]any{{2.0, 1.0}, {4.0, 1.0}, {6.0, 1.0}},
+		},
+		{
+			name:     "counted rows of a subquery without read columns",
+			cmd:      "select count(*) from (select name, id from people where team = 'red');",
+			wantRows: [][]any{{2.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_FoldConstants(t *testing.T) {
+	e := New(nil)
+
+	tests := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "arithmetic",
+			cmd:  "select a > 60 * 60 from t;",
+			want: "a > 3600",
+		},
+		{
+			name: "strings and functions",
+			cmd:  "select regexp_like(a, 'x' || 'y') from t;",
+			want: "regexp_like(a, 'xy')",
+		},
+		{
+			name: "division by zero is kept",
+			cmd:  "select a + 1 / 0 from t;",
+			want: "a + 1 / 0",
+		},
+		{
+			name: "timestamps are kept",
+			cmd:  "select cast('2024-01-02' as timestamp) from t;",
+			want: "cast('2024-01-02' as timestamp)",
+		},
+		{
+			name: "typed null is kept",
+			cmd:  "select cast(null as number) from t;",
+			want: "cast(null as number)",
+		},
+		{
+			name: "aggregate arguments",
+			cmd:  "select sum(a * (2 + 3)) from t;",
+			want: "sum(a * 5)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, err := newSelectStatement(parseQuery(t, tt.cmd))
+			if err != nil {
+				t.Fatalf("newSelectStatement() error = %v", err)
+			}
+
+			got := foldConstants(statement.columns[0].expression, e.newScope(context.Background()))
+			if got.Text() != tt.want {
+				t.Errorf("foldConstants() = %s, want %s", got.Text(), tt.want)
+			}
+		})
+	}
+}
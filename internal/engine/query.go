@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var ErrNotQuery = errors.New("not a 'select' or 'with' statement")
+
+// Column describes a column of the result of a query.
+type Column struct {
+	Name string
+	// Type is one of the column types, like "number" or "timestamp". It is
+	// "null" when the query can only make nulls for the column.
+	Type string
+}
+
+// Cursor reads the rows of a query while they are computed. Values are
+// float64, string, bool, time.Time, []any, map[string]any or nil. Other
+// statements may run between the rows; the tables the query already reads
+// stay as they were when it started.
+type Cursor struct {
+	engine  *Engine
+	op      resultOperator
+	columns []Column
+	// releases the context of the query, bounded by the timeout option
+	cancel context.CancelFunc
+}
+
+// Query runs a 'select' or 'with' statement and returns a cursor over its
+// rows, which must be closed. Reading stops with the error of ctx once it is
+// done, or when the timeout option runs out.
+func (c *Engine) Query(ctx context.Context, query *parser.AstNode) (*Cursor, error) {
+	if !isSubquery(query) && query.Value().Value() != parser.WithKeyword.String() {
+		return nil, ErrNotQuery
+	}
+
+	statement, err := newSelectStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.query(ctx, statement)
+}
+
+// QueryTable returns a cursor over the rows of a table or a view, as
+// 'select *' would, taking its name as it is rather than as query text.
+func (c *Engine) QueryTable(ctx context.Context, name string) (*Cursor, error) {
+	star := parser.NewAstNode(parser.ColumnNode("*"))
+	return c.query(ctx, &selectStatement{
+		tableSource: tableSource{from: name},
+		columns:     []selectColumn{{expression: star, name: star.Text()}},
+	})
+}
+
+func (c *Engine) query(ctx context.Context, statement *selectStatement) (*Cursor, error) {
+	ctx, cancel := c.withTimeout(ctx)
+
+	if err := c.lock(ctx, false); err != nil {
+		cancel()
+		return nil, err
+	}
+	defer c.unlock(false)
+
+	op, err := c.open(ctx, statement)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	columns := make([]Column, 0, len(op.columns()))
+	for _, column := range op.columns() {
+		columns = append(columns, Column{Name: column.Name, Type: string(column.ColumnType)})
+	}
+	return &Cursor{engine: c, op: op, columns: columns, cancel: cancel}, nil
+}
+
+// Exec runs a command and returns the number of rows it inserted, updated
+// or deleted. Other commands run as Process runs them and change no rows.
+func (c *Engine) Exec(ctx context.Context, query *parser.AstNode) (int, error) {
+	switch query.Value().Value() {
+	case parser.InsertKeyword.String(), parser.UpdateKeyword.String(), parser.DeleteKeyword.String():
+		ctx, cancel := c.withTimeout(ctx)
+		defer cancel()
+
+		if err := c.lock(ctx, true); err != nil {
+			return 0, err
+		}
+		defer c.unlock(true)
+
+		return c.modifyQuery(ctx, query)
+	}
+
+	return 0, c.Process(ctx, query)
+}
+
+// modifyQuery runs an 'insert', 'update' or 'delete' statement.
+func (c *Engine) modifyQuery(ctx context.Context, query *parser.AstNode) (int, error) {
+	switch query.Value().Value() {
+	case parser.InsertKeyword.String():
+		statement, err := newInsertStatement(query)
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.insertQuery(ctx, statement)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert rows: %w", err)
+		}
+		return n, nil
+	case parser.UpdateKeyword.String():
+		statement, err := newUpdateStatement(query)
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.updateQuery(ctx, statement)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update rows: %w", err)
+		}
+		return n, nil
+	case parser.DeleteKeyword.String():
+		statement, err := newDeleteStatement(query)
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.deleteQuery(ctx, statement)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete rows: %w", err)
+		}
+		return n, nil
+	}
+
+	return 0, ErrUnknownCommand
+}
+
+// open plans a statement and opens the operators running it.
+func (c *Engine) open(ctx context.Context, statement *selectStatement) (resultOperator, error) {
+	s := c.newScope(ctx)
+	plan, err := c.planQuery(statement, s)
+	if err != nil {
+		return nil, err
+	}
+	op, err := c.resultOperator(plan, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := op.Open(); err != nil {
+		op.Close()
+		return nil, err
+	}
+	return op, nil
+}
+
+func (c *Cursor) Columns() []Column {
+	return c.columns
+}
+
+// Next returns the values of the next row, or false when no rows are left.
+func (c *Cursor) Next() ([]any, bool, error) {
+	c.engine.mu.RLock()
+	defer c.engine.mu.RUnlock()
+
+	return c.op.Next()
+}
+
+func (c *Cursor) Close() error {
+	defer c.cancel()
+
+	c.engine.mu.RLock()
+	defer c.engine.mu.RUnlock()
+
+	return c.op.Close()
+}
+
+// TableInfo describes a loaded table or a view.
+type TableInfo struct {
+	Name string
+	View bool
+	// Rows is the number of rows of a table, and zero for views
+	Rows int
+}
+
+// Tables lists the loaded tables and the views, by name, as the default
+// session sees them.
+func (c *Engine) Tables() []TableInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	visible := c.visible(context.Background())
+	tables := make([]TableInfo, 0, len(visible.tables)+len(visible.views))
+	for name, table := range visible.tables {
+		tables = append(tables, TableInfo{Name: name, Rows: len(table.rows)})
+	}
+	for name := range visible.views {
+		tables = append(tables, TableInfo{Name: name, View: true})
+	}
+	slices.SortFunc(tables, func(a, b TableInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tables
+}
+
+// Schema returns the columns of a table or a view, in the order of the
+// table. Views are run to find their columns.
+func (c *Engine) Schema(ctx context.Context, name string) ([]Column, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if err := c.lock(ctx, false); err != nil {
+		return nil, err
+	}
+	defer c.unlock(false)
+
+	table, err := c.newScope(ctx).table(name)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]Column, 0, len(table.columns))
+	for _, column := range table.columnNames() {
+		columns = append(columns, Column{Name: column, Type: string(table.columns[column].ColumnType)})
+	}
+	return columns, nil
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/kotsmile/jql/internal/tableui"
+)
+
+type resultColumn struct {
+	Name       string
+	ColumnType columnType
+}
+
+type resultSet struct {
+	columns []resultColumn
+	rows    [][]any
+}
+
+// pageSize is the number of rows rendered as one table, which bounds the
+// rows held while a result is printed.
+const pageSize = 1000
+
+// render prints the rows of an open operator as tables of up to pageSize
+// rows, so that large results are printed while they are read.
+func render(w io.Writer, op resultOperator) error {
+	var cs []string
+	for _, column := range op.columns() {
+		cs = append(cs, column.Name)
+	}
+
+	rs := make([]tableui.Row, 0)
+	printed := false
+	flush := func() error {
+		tui := tableui.New(cs, rs)
+		if err := tui.Render(w); err != nil {
+			return fmt.Errorf("failed to render table: %w", err)
+		}
+		rs = rs[:0]
+		printed = true
+		return nil
+	}
+
+	for {
+		row, ok, err := op.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		rr := make(tableui.Row, 0, len(cs))
+		for _, value := range row[:len(cs)] {
+			rr = append(rr, formatValue(value))
+		}
+		rs = append(rs, rr)
+
+		if len(rs) == pageSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(rs) > 0 || !printed {
+		return flush()
+	}
+	return nil
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []any, map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// output writes the results of a statement to the writer of the engine. It
+// takes the output lock on its first write and holds it until released.
+type output struct {
+	engine *Engine
+	locked bool
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if !o.locked {
+		o.engine.out.Lock()
+		o.locked = true
+	}
+	return o.engine.writer.Write(p)
+}
+
+func (o *output) release() {
+	if o.locked {
+		o.engine.out.Unlock()
+		o.locked = false
+	}
+}
@@ -0,0 +1,329 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var ErrNoSource = errors.New("table has no source file")
+
+// rename replaces the saved file with the written one, replaced in tests.
+var rename = os.Rename
+
+// source is where a table was loaded from and how its file was laid out, so
+// that saving it changes as little of the file as possible.
+type source struct {
+	filename string
+	// indent is the unit of indentation, empty for compact files
+	indent  string
+	newline bool
+	// keys holds the order of object keys as they first appeared, by the
+	// path of the objects: "[]" for rows, "[].meta" for their 'meta' objects
+	// and "[].tags[]" for objects inside their 'tags' arrays
+	keys map[string][]string
+	// numbers holds the text of the numbers of the file that are written
+	// differently from their value
+	numbers numbers
+}
+
+// numbers maps values to the text they had in a file, for numbers whose
+// text does not survive being read as a float64, such as integers above
+// 2^53 or '1.0', so that saving writes them back unchanged. Values read
+// from several different texts are written as themselves.
+type numbers map[float64]string
+
+// value replaces the numbers of a decoded value with float64, recording
+// their text.
+func (n numbers) value(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := n[f]; !ok {
+			n[f] = v.String()
+		} else if text != v.String() {
+			n[f] = ""
+		}
+		return f, nil
+	case []any:
+		for i, item := range v {
+			var err error
+			if v[i], err = n.value(item); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]any:
+		for key, item := range v {
+			var err error
+			if v[key], err = n.value(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// prune drops the numbers written the same without their text.
+func (n numbers) prune() {
+	for f, text := range n {
+		encoded, err := json.Marshal(f)
+		if text == "" || err == nil && string(encoded) == text {
+			delete(n, f)
+		}
+	}
+}
+
+// newSource records the layout of a json file and the text of its numbers.
+func newSource(filename string, data []byte, numbers numbers) (*source, error) {
+	keys, err := keyOrder(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &source{
+		filename: filename,
+		indent:   detectIndent(data),
+		newline:  bytes.HasSuffix(data, []byte("\n")),
+		keys:     keys,
+		numbers:  numbers,
+	}, nil
+}
+
+// detectIndent returns the whitespace before the first value on its own
+// line, which is the unit of indentation of pretty printed json.
+func detectIndent(data []byte) string {
+	data = bytes.TrimSpace(data)
+	i := bytes.IndexByte(data, '\n')
+	if i == -1 {
+		return ""
+	}
+
+	line := data[i+1:]
+	end := 0
+	for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+		end++
+	}
+	return string(line[:end])
+}
+
+func keyOrder(data []byte) (map[string][]string, error) {
+	keys := make(map[string][]string)
+	seen := make(map[string]map[string]struct{})
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		t, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case json.Delim('{'):
+			if seen[path] == nil {
+				seen[path] = make(map[string]struct{})
+			}
+			for decoder.More() {
+				t, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := t.(string)
+				if _, ok := seen[path][key]; !ok {
+					seen[path][key] = struct{}{}
+					keys[path] = append(keys[path], key)
+				}
+				if err := walk(path + "." + key); err != nil {
+					return err
+				}
+			}
+		case json.Delim('['):
+			for decoder.More() {
+				if err := walk(path + "[]"); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+
+		// closing delimiter
+		_, err = decoder.Token()
+		return err
+	}
+
+	if err := walk(""); err != nil {
+		return nil, fmt.Errorf("failed to read key order: %w", err)
+	}
+	return keys, nil
+}
+
+// encode writes rows as json, with keys in their recorded order followed by
+// new keys sorted by name.
+func (s *source) encode(rows []Row) ([]byte, error) {
+	values := make([]any, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, map[string]any(row))
+	}
+
+	var compact bytes.Buffer
+	if err := s.writeValue(&compact, values, ""); err != nil {
+		return nil, err
+	}
+
+	out := compact.Bytes()
+	if s.indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, out, "", s.indent); err != nil {
+			return nil, err
+		}
+		out = indented.Bytes()
+	}
+	if s.newline {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+func (s *source) writeValue(w *bytes.Buffer, value any, path string) error {
+	switch v := value.(type) {
+	case map[string]any:
+		w.WriteByte('{')
+		for i, key := range s.orderKeys(v, path) {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := s.writeValue(w, key, ""); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			if err := s.writeValue(w, v[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	case []any:
+		w.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := s.writeValue(w, item, path+"[]"); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	default:
+		if f, ok := v.(float64); ok {
+			if text, ok := s.numbers[f]; ok {
+				w.WriteString(text)
+				return nil
+			}
+		}
+
+		var b bytes.Buffer
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		w.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	}
+
+	return nil
+}
+
+func (s *source) orderKeys(object map[string]any, path string) []string {
+	ordered := make([]string, 0, len(object))
+	known := make(map[string]struct{}, len(s.keys[path]))
+	for _, key := range s.keys[path] {
+		known[key] = struct{}{}
+		if _, ok := object[key]; ok {
+			ordered = append(ordered, key)
+		}
+	}
+
+	var added []string
+	for key := range object {
+		if _, ok := known[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+
+	return append(ordered, added...)
+}
+
+// saveCommand writes a table to the file it was loaded from. The file is
+// replaced in one step, after moving the original to a '.bak' file when
+// backup is set.
+func (c *Engine) saveCommand(tablename string, backup bool) (string, error) {
+	// the file cannot be restored on rollback
+	if c.transaction != nil {
+		return "", ErrSaveInTransaction
+	}
+
+	table, err := c.loadedTable(tablename)
+	if err != nil {
+		return "", err
+	}
+	if table.source == nil {
+		return "", fmt.Errorf("%w: '%s'", ErrNoSource, tablename)
+	}
+	filename := table.source.filename
+
+	data, err := table.source.encode(table.rows)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode json: %w", err)
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, bytes.NewReader(data)); err != nil {
+		temp.Close()
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	moved := false
+	if backup {
+		err := os.Rename(filename, filename+".bak")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to create backup: %w", err)
+		}
+		moved = err == nil
+	}
+	if err := rename(temp.Name(), filename); err != nil {
+		// the original is put back, so that it is not left only as the backup
+		if moved {
+			if restoreErr := os.Rename(filename+".bak", filename); restoreErr != nil {
+				return "", fmt.Errorf("failed to replace file: %w, and to restore it from its backup: %v", err, restoreErr)
+			}
+		}
+		return "", fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return filename, nil
+}
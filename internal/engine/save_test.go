@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Save(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		cmds []string
+		want string
+	}{
+		{
+			name: "keeps key order and tab indentation",
+			data: "[\n\t{\n\t\t\"name\": \"ann\",\n\t\t\"id\": 1,\n\t\t\"meta\": {\"z\": 1, \"a\": \"<b>\"}\n\t},\n\t{\n\t\t\"name\": \"bob\",\n\t\t\"id\": 2\n\t}\n]\n",
+			cmds: []string{"update t set id = id + 10 where name = 'bob';", "insert into t (id, name, extra) values (3, 'cid', true);"},
+			want: "[\n\t{\n\t\t\"name\": \"ann\",\n\t\t\"id\": 1,\n\t\t\"meta\": {\n\t\t\t\"z\": 1,\n\t\t\t\"a\": \"<b>\"\n\t\t}\n\t},\n\t{\n\t\t\"name\": \"bob\",\n\t\t\"id\": 12\n\t},\n\t{\n\t\t\"name\": \"cid\",\n\t\t\"id\": 3,\n\t\t\"extra\": true\n\t}\n]\n",
+		},
+		{
+			name: "keeps compact files compact",
+			data: `[{"b":1.5,"a":[{"y":null,"x":"s"}]},{"b":2,"a":[]}]`,
+			cmds: []string{"delete from t where b = 2;"},
+			want: `[{"b":1.5,"a":[{"y":null,"x":"s"}]}]`,
+		},
+		{
+			name: "keeps the text of numbers",
+			data: `[{"id":9007199254740993,"n":1.50,"m":{"e":1e2}},{"id":2,"n":2,"a":[1.0,1]}]`,
+			cmds: []string{"insert into t (id, n) values (3, 4.0);"},
+			want: `[{"id":9007199254740993,"n":1.50,"m":{"e":1e2}},{"id":2,"n":2,"a":[1,1]},{"id":3,"n":4}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "t.json")
+			if err := os.WriteFile(filename, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(io.Discard)
+			if err := e.LoadTable(context.Background(), filename, "t"); err != nil {
+				t.Fatalf("LoadTable() error = %v", err)
+			}
+			for _, cmd := range append(tt.cmds, "save t with backup;") {
+				if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+			}
+
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saved file =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			backup, err := os.ReadFile(filename + ".bak")
+			if err != nil {
+				t.Fatalf("backup: %v", err)
+			}
+			if string(backup) != tt.data {
+				t.Errorf("backup =\n%s\nwant\n%s", backup, tt.data)
+			}
+
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0o600 {
+				t.Errorf("saved file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+			}
+		})
+	}
+}
+
+func Test_SaveRestoresBackup(t *testing.T) {
+	data := `[{"id":1}]`
+	filename := filepath.Join(t.TempDir(), "t.json")
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(io.Discard)
+	if err := e.LoadTable(context.Background(), filename, "t"); err != nil {
+		t.Fatalf("LoadTable() error = %v", err)
+	}
+
+	errRename := errors.New("rename failed")
+	rename = func(string, string) error { return errRename }
+	defer func() { rename = os.Rename }()
+
+	if err := e.Process(context.Background(), parseQuery(t, "save t with backup;")); !errors.Is(err, errRename) {
+		t.Fatalf("Process() error = %v, want %v", err, errRename)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("file = %s, want %s", got, data)
+	}
+	if _, err := os.Stat(filename + ".bak"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup exists after the restore: %v", err)
+	}
+}
+
+func Test_SaveWithoutSource(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+	if err := e.Process(context.Background(), parseQuery(t, "save t;")); !errors.Is(err, ErrNoSource) {
+		t.Errorf("Process() error = %v, want %v", err, ErrNoSource)
+	}
+}
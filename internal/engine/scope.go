@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+// scope resolves names while expressions are typed and evaluated: the
+// columns and current row of the source of a query, the tables defined by
+// 'with' and, for subqueries, the scope of the enclosing query.
+type scope struct {
+	// ctx of the statement, checked while rows are read
+	ctx     context.Context
+	engine  *Engine
+	catalog catalog
+	name    string
+	columns columns
+	row     Row
+	tables  map[string]*Table
+	outer   *scope
+
+	// set when a column is resolved by an enclosing scope
+	correlated *bool
+	// results of uncorrelated subqueries and plans of all subqueries, shared
+	// by all scopes of a statement
+	subqueries map[*parser.AstNode]*resultSet
+	plans      map[*parser.AstNode]planNode
+	// views being run, innermost last
+	views []string
+	// plain names shared by columns of several joined sources
+	ambiguous map[string]struct{}
+	// types inferred for unbound placeholders, which are null, when
+	// ParameterTypes checks a statement
+	parameters parameterTypes
+}
+
+func (c *Engine) newScope(ctx context.Context) *scope {
+	parameters, _ := ctx.Value(parameterTypesKey{}).(parameterTypes)
+	return &scope{
+		ctx:        ctx,
+		engine:     c,
+		catalog:    c.visible(ctx),
+		tables:     make(map[string]*Table),
+		correlated: new(bool),
+		subqueries: make(map[*parser.AstNode]*resultSet),
+		plans:      make(map[*parser.AstNode]planNode),
+		parameters: parameters,
+	}
+}
+
+// nested creates the scope of a query enclosed by s.
+func (s *scope) nested() *scope {
+	return &scope{
+		ctx:        s.ctx,
+		engine:     s.engine,
+		catalog:    s.catalog,
+		tables:     make(map[string]*Table),
+		outer:      s,
+		correlated: new(bool),
+		subqueries: s.subqueries,
+		plans:      s.plans,
+		views:      s.views,
+		parameters: s.parameters,
+	}
+}
+
+// bind returns a copy of s for rows of a source with the given name and
+// columns.
+func (s *scope) bind(name string, columns columns) *scope {
+	bound := *s
+	bound.name = name
+	bound.columns = columns
+	bound.row = nil
+	bound.ambiguous = nil
+	return &bound
+}
+
+func (s *scope) withColumns(columns columns) *scope {
+	bound := *s
+	bound.columns = columns
+	return &bound
+}
+
+func (s *scope) withRow(row Row) *scope {
+	bound := *s
+	bound.row = row
+	return &bound
+}
+
+// resolve finds the scope holding a column and the column key in its rows.
+// Columns can be qualified with the name of their source, as in 'u.id'.
+func (s *scope) resolve(column string) (*scope, string, error) {
+	for current := s; current != nil; current = current.outer {
+		if _, ok := current.columns[column]; ok {
+			return current, column, nil
+		}
+		if _, ok := current.ambiguous[column]; ok {
+			return nil, "", fmt.Errorf("%w: '%s'", ErrAmbiguousColumn, column)
+		}
+
+		qualifier, name, ok := strings.Cut(column, ".")
+		if ok && qualifier == current.name {
+			if _, ok := current.columns[name]; ok {
+				return current, name, nil
+			}
+		}
+
+		*current.correlated = true
+	}
+
+	return nil, "", fmt.Errorf("column '%s' not found", column)
+}
+
+// table finds a table defined by 'with' in s or an enclosing scope, or else
+// a loaded table or a view.
+func (s *scope) table(name string) (*Table, error) {
+	for current := s; current != nil; current = current.outer {
+		if table, ok := current.tables[name]; ok {
+			return table, nil
+		}
+	}
+
+	if query, ok := s.catalog.views[name]; ok {
+		return s.viewTable(name, query)
+	}
+	return s.loadedTable(name)
+}
+
+// loadedTable finds a loaded table the statement sees.
+func (s *scope) loadedTable(name string) (*Table, error) {
+	table, ok := s.catalog.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrTableNotFound, name)
+	}
+	return table, nil
+}
+
+// subquery runs a subquery used as an expression. Subqueries that only
+// refer to their own columns run once per statement.
+func (s *scope) subquery(node *parser.AstNode) (*resultSet, error) {
+	if result, ok := s.subqueries[node]; ok {
+		return result, nil
+	}
+
+	inner := s.nested()
+	plan, ok := s.plans[node]
+	if !ok {
+		statement, err := newSelectStatement(node)
+		if err != nil {
+			return nil, err
+		}
+		plan, err = s.engine.planQuery(statement, inner)
+		if err != nil {
+			return nil, fmt.Errorf("subquery: %w", err)
+		}
+		s.plans[node] = plan
+	}
+
+	result, err := s.engine.run(plan, inner)
+	if err != nil {
+		return nil, fmt.Errorf("subquery: %w", err)
+	}
+
+	if !*inner.correlated {
+		s.subqueries[node] = result
+	}
+
+	return result, nil
+}
+
+// subqueryColumn runs a subquery that must return a single column.
+func (s *scope) subqueryColumn(node *parser.AstNode) (*resultSet, error) {
+	result, err := s.subquery(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.columns) != 1 {
+		return nil, fmt.Errorf("%w: subquery must return one column, got %d", ErrWrongOperandNumber, len(result.columns))
+	}
+
+	return result, nil
+}
+
+func isSubquery(node *parser.AstNode) bool {
+	keyword, ok := node.Value().(*parser.KeywordNode)
+	if !ok {
+		return false
+	}
+
+	switch keyword.Value() {
+	case parser.SelectKeyword.String(), parser.UnionKeyword.String(),
+		parser.IntersectKeyword.String(), parser.ExceptKeyword.String():
+		return true
+	}
+	return false
+}
+
+// scalarSubquery evaluates a subquery used as a value, which must not return
+// more than one row.
+func (s *scope) scalarSubquery(node *parser.AstNode) (any, error) {
+	result, err := s.subqueryColumn(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.rows) > 1 {
+		return nil, fmt.Errorf("subquery used as a value returned %d rows", len(result.rows))
+	}
+
+	row, ok := util.At(result.rows, 0)
+	if !ok {
+		return nil, nil
+	}
+	return row[0], nil
+}
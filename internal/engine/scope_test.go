@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+var members = []Row{
+	{"id": 1.0, "name": "ann", "team": "red"},
+	{"id": 2.0, "name": "bob", "team": "blue"},
+	{"id": 3.0, "name": "cid", "team": "red"},
+}
+
+var purchases = []Row{
+	{"person": 1.0, "amount": 10.0},
+	{"person": 1.0, "amount": 30.0},
+	{"person": 2.0, "amount": 5.0},
+}
+
+func Test_SelectSubqueries(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"people": members, "purchases": purchases})
+
+	tests := []struct {
+		name        string
+		cmd         string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "with",
+			cmd:         "with totals as (select person, sum(amount) as total from purchases group by person) select * from totals where total > 6;",
+			wantColumns: []resultColumn{{"person", NumberType}, {"total", NumberType}},
+			wantRows:    [][]any{{1.0, 40.0}},
+		},
+		{
+			name:        "with referring to an earlier table",
+			cmd:         "with big as (select * from purchases where amount > 6), n as (select count(*) as n from big) select n from n;",
+			wantColumns: []resultColumn{{"n", NumberType}},
+			wantRows:    [][]any{{2.0}},
+		},
+		{
+			name:        "subquery in from with alias",
+			cmd:         "select p.amount * 2 as double, p.person from (select * from purchases where person = 1) p order by 1;",
+			wantColumns: []resultColumn{{"double", NumberType}, {"person", NumberType}},
+			wantRows:    [][]any{{20.0, 1.0}, {60.0, 1.0}},
+		},
+		{
+			name:        "empty subquery in from",
+			cmd:         "select count(*) from (select * from purchases where amount > 100);",
+			wantColumns: []resultColumn{{"count(*)", NumberType}},
+			wantRows:    [][]any{{0.0}},
+		},
+		{
+			name:        "scalar subquery",
+			cmd:         "select name, (select max(amount) from purchases) as top from people where id = 1;",
+			wantColumns: []resultColumn{{"name", StringType}, {"top", NumberType}},
+			wantRows:    [][]any{{"ann", 30.0}},
+		},
+		{
+			name:        "unaliased scalar subquery",
+			cmd:         "select (select count(*) from purchases) from people where id = 1;",
+			wantColumns: []resultColumn{{"(select count(*) from purchases)", NumberType}},
+			wantRows:    [][]any{{3.0}},
+		},
+		{
+			name:        "in subquery",
+			cmd:         "select name from people where id in (select person from purchases);",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"ann"}, {"bob"}},
+		},
+		{
+			name:        "not in subquery",
+			cmd:         "select name from people where id not in (select person from purchases);",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"cid"}},
+		},
+		{
+			name:        "correlated exists",
+			cmd:         "select name from people p where exists (select 1 from purchases where person = p.id and amount > 20);",
+			wantColumns: []resultColumn{{"name", StringType}},
+			wantRows:    [][]any{{"ann"}},
+		},
+		{
+			name:        "correlated scalar subquery",
+			cmd:         "select name, (select sum(amount) from purchases where person = people.id) as total from people order by name;",
+			wantColumns: []resultColumn{{"name", StringType}, {"total", NumberType}},
+			wantRows:    [][]any{{"ann", 40.0}, {"bob", 5.0}, {"cid", nil}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SelectSubqueryErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"people": members, "purchases": purchases})
+
+	tests := []struct {
+		name string
+		cmd  string
+	}{
+		{
+			name: "scalar subquery with many rows",
+			cmd:  "select (select amount from purchases) from people;",
+		},
+		{
+			name: "subquery with many columns",
+			cmd:  "select name from people where id in (select person, amount from purchases);",
+		},
+		{
+			name: "with table is local to its statement",
+			cmd:  "select * from totals;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runSelect(t, e, tt.cmd); err == nil {
+				t.Errorf("selectQuery() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+type selectColumn struct {
+	expression *parser.AstNode
+	name       string
+}
+
+// commonTable is a table defined by 'with' for the statement that follows.
+type commonTable struct {
+	name      string
+	statement *selectStatement
+}
+
+// tableSource is a table or a subquery rows are read from, with an
+// optional alias.
+type tableSource struct {
+	from      string
+	fromQuery *selectStatement
+	alias     string
+}
+
+// joinClause is a source joined to the rows of the sources before it.
+type joinClause struct {
+	tableSource
+	left bool
+	on   *parser.AstNode
+}
+
+type selectStatement struct {
+	tableSource
+	with     []commonTable
+	set      *setOperation
+	distinct bool
+	columns  []selectColumn
+	joins    []joinClause
+	where    *parser.AstNode
+	groupBy  []*parser.AstNode
+	orderBy  []orderItem
+	// row count and offset of 'limit', if any
+	limit  *parser.AstNode
+	offset *parser.AstNode
+}
+
+func newSelectStatement(query *parser.AstNode) (*selectStatement, error) {
+	switch query.Value().Value() {
+	case parser.WithKeyword.String():
+		return newWithStatement(query)
+	case parser.UnionKeyword.String(), parser.IntersectKeyword.String(), parser.ExceptKeyword.String():
+		return newSetStatement(query)
+	}
+
+	statement := &selectStatement{}
+
+	for _, child := range query.Children() {
+		// keyword nodes are clauses, unless they are expressions like
+		// 'case' or subqueries
+		keyword, ok := child.Value().(*parser.KeywordNode)
+		if !ok || keyword.Value() == parser.AsKeyword.String() ||
+			keyword.Value() == parser.CaseKeyword.String() || isSubquery(child) {
+			column, err := newSelectColumn(child)
+			if err != nil {
+				return nil, err
+			}
+			statement.columns = append(statement.columns, column)
+			continue
+		}
+
+		switch keyword.Value() {
+		case parser.DistinctKeyword.String():
+			statement.distinct = true
+		case parser.FromKeyword.String():
+			if err := statement.setSource(child); err != nil {
+				return nil, err
+			}
+		case parser.WhereKeyword.String():
+			condition, ok := util.At(child.Children(), 0)
+			if !ok {
+				return nil, fmt.Errorf("'select' command: missing condition for 'where' keyword")
+			}
+			statement.where = condition
+		case parser.GroupKeyword.String():
+			statement.groupBy = child.Children()
+		case parser.OrderKeyword.String():
+			statement.orderBy = newOrderItems(child)
+		case parser.LimitKeyword.String():
+			if err := statement.setLimit(child); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("'select' command: unexpected keyword '%s'", keyword.Value())
+		}
+	}
+
+	if len(statement.columns) == 0 {
+		return nil, fmt.Errorf("'select' command: missing columns")
+	}
+	if statement.from == "" && statement.fromQuery == nil {
+		return nil, fmt.Errorf("'select' command: missing 'from' keyword")
+	}
+
+	return statement, nil
+}
+
+// setLimit reads a 'limit' clause and its optional 'offset'.
+func (s *selectStatement) setLimit(node *parser.AstNode) error {
+	count, ok := util.At(node.Children(), 0)
+	if !ok {
+		return fmt.Errorf("'select' command: missing row count for 'limit' keyword")
+	}
+	s.limit = count
+
+	if offsetNode, ok := util.At(node.Children(), 1); ok {
+		offset, ok := util.At(offsetNode.Children(), 0)
+		if !ok {
+			return fmt.Errorf("'select' command: missing row count for 'offset' keyword")
+		}
+		s.offset = offset
+	}
+	return nil
+}
+
+// newWithStatement builds the statement that ends a 'with' command, along
+// with the tables defined before it.
+func newWithStatement(query *parser.AstNode) (*selectStatement, error) {
+	children := query.Children()
+	last, ok := util.At(children, len(children)-1)
+	if !ok {
+		return nil, fmt.Errorf("'with' command: missing 'select' statement")
+	}
+
+	statement, err := newSelectStatement(last)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children[:len(children)-1] {
+		nameNode, ok := util.At(child.Children(), 0)
+		if !ok {
+			return nil, fmt.Errorf("'with' command: missing table name")
+		}
+		name, ok := nameNode.Value().(parser.StringNode)
+		if !ok {
+			return nil, fmt.Errorf("'with' command: wrong type for table name")
+		}
+
+		queryNode, ok := util.At(child.Children(), 1)
+		if !ok {
+			return nil, fmt.Errorf("'with' command: missing query for table '%s'", name.Value())
+		}
+		query, err := newSelectStatement(queryNode)
+		if err != nil {
+			return nil, fmt.Errorf("'with' command: table '%s': %w", name.Value(), err)
+		}
+
+		statement.with = append(statement.with, commonTable{
+			name:      name.Value(),
+			statement: query,
+		})
+	}
+
+	return statement, nil
+}
+
+// setSource reads the 'from' clause: a table name or a subquery, an
+// optional alias and the joined sources.
+func (s *selectStatement) setSource(node *parser.AstNode) error {
+	children := node.Children()
+	for len(children) > 0 {
+		last := children[len(children)-1]
+		keyword, ok := last.Value().(*parser.KeywordNode)
+		if !ok || keyword.Value() != parser.JoinKeyword.String() {
+			break
+		}
+		children = children[:len(children)-1]
+
+		join, err := newJoinClause(last)
+		if err != nil {
+			return err
+		}
+		s.joins = append([]joinClause{join}, s.joins...)
+	}
+
+	return s.tableSource.set(children)
+}
+
+func newJoinClause(node *parser.AstNode) (joinClause, error) {
+	join := joinClause{}
+
+	children := node.Children()
+	if first, ok := util.At(children, 0); ok && first.Value().Value() == parser.LeftKeyword.String() {
+		join.left = true
+		children = children[1:]
+	}
+
+	onNode, ok := util.At(children, len(children)-1)
+	if !ok || onNode.Value().Value() != parser.OnKeyword.String() {
+		return joinClause{}, fmt.Errorf("'select' command: missing 'on' keyword")
+	}
+	condition, ok := util.At(onNode.Children(), 0)
+	if !ok {
+		return joinClause{}, fmt.Errorf("'select' command: missing condition for 'on' keyword")
+	}
+	join.on = condition
+
+	if err := join.tableSource.set(children[:len(children)-1]); err != nil {
+		return joinClause{}, err
+	}
+	return join, nil
+}
+
+// set reads a table name or a subquery and an optional alias.
+func (t *tableSource) set(nodes []*parser.AstNode) error {
+	source, ok := util.At(nodes, 0)
+	if !ok || len(nodes) > 2 {
+		return fmt.Errorf("'select' command: wrong number of arguments for 'from' keyword")
+	}
+
+	switch value := source.Value().(type) {
+	case parser.StringNode:
+		t.from = value.Value()
+	case *parser.KeywordNode:
+		if !isSubquery(source) {
+			return fmt.Errorf("'select' command: wrong type for table name")
+		}
+
+		query, err := newSelectStatement(source)
+		if err != nil {
+			return err
+		}
+		t.fromQuery = query
+	default:
+		return fmt.Errorf("'select' command: wrong type for table name")
+	}
+
+	if asNode, ok := util.At(nodes, 1); ok {
+		aliasNode, ok := util.At(asNode.Children(), 0)
+		if !ok {
+			return fmt.Errorf("'select' command: missing table alias")
+		}
+		alias, ok := aliasNode.Value().(parser.StringNode)
+		if !ok {
+			return fmt.Errorf("'select' command: wrong type for table alias")
+		}
+		t.alias = alias.Value()
+	}
+
+	return nil
+}
+
+// name is how columns of the source can be qualified.
+func (t *tableSource) name() string {
+	if t.alias != "" {
+		return t.alias
+	}
+	return t.from
+}
+
+func newSelectColumn(node *parser.AstNode) (selectColumn, error) {
+	keyword, ok := node.Value().(*parser.KeywordNode)
+	if !ok || keyword.Value() != parser.AsKeyword.String() {
+		return selectColumn{
+			expression: node,
+			name:       node.Text(),
+		}, nil
+	}
+
+	expression, ok := util.At(node.Children(), 0)
+	if !ok {
+		return selectColumn{}, fmt.Errorf("'select' command: missing expression for 'as' keyword")
+	}
+
+	aliasNode, ok := util.At(node.Children(), 1)
+	if !ok {
+		return selectColumn{}, fmt.Errorf("'select' command: missing column name for 'as' keyword")
+	}
+
+	alias, ok := aliasNode.Value().(parser.StringNode)
+	if !ok {
+		return selectColumn{}, fmt.Errorf("'select' command: wrong type for column name")
+	}
+
+	return selectColumn{
+		expression: expression,
+		name:       alias.Value(),
+	}, nil
+}
+
+// selectQuery runs a statement in the scope s, which is the scope of the
+// enclosing query for subqueries.
+func (c *Engine) selectQuery(statement *selectStatement, s *scope) (*resultSet, error) {
+	plan, err := c.planQuery(statement, s)
+	if err != nil {
+		return nil, err
+	}
+	return c.run(plan, s)
+}
+
+// checkCondition validates the condition of a 'where' or 'on' clause for
+// rows of the scope.
+func checkCondition(clause parser.KeywordType, condition *parser.AstNode, s *scope) error {
+	if containsAggregate(condition) || containsWindow(condition) {
+		return fmt.Errorf("aggregate and window calls are not allowed in '%s'", clause)
+	}
+
+	t, err := expressionType(condition, s)
+	if err != nil {
+		return err
+	}
+	if t != BooleanType && knownType(t) {
+		return fmt.Errorf("%w: '%s' condition must be boolean, got %s", ErrTypeMismatch, clause, t)
+	}
+	return nil
+}
+
+// holds evaluates the condition of a clause for the row of the scope, where
+// an unknown result does not hold.
+func holds(clause parser.KeywordType, condition *parser.AstNode, s *scope) (bool, error) {
+	value, err := evalExpression(condition, s)
+	if err != nil {
+		return false, fmt.Errorf("'%s' condition: %w", clause, err)
+	}
+
+	ok, err := isTrue(value)
+	if err != nil {
+		return false, fmt.Errorf("'%s' condition: %w", clause, err)
+	}
+	return ok, nil
+}
+
+// sortRows sorts result rows by the values at the given indexes.
+func sortRows(rows [][]any, indexes []int, descending []bool) {
+	if len(indexes) == 0 {
+		return
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		for i, index := range indexes {
+			c := compareSort(rows[a][index], rows[b][index])
+			if descending[i] {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// outputColumn resolves an 'order by' expression that names an output column
+// by its alias or by its position, returning -1 for other expressions.
+func outputColumn(expression *parser.AstNode, columns []selectColumn) (int, error) {
+	switch value := expression.Value().(type) {
+	case parser.ColumnNode:
+		for i, column := range columns {
+			if column.name == value.Value() {
+				return i, nil
+			}
+		}
+	case *parser.LiteralNode:
+		if value.LiteralType() != parser.NumberLiteral {
+			break
+		}
+
+		position, err := strconv.Atoi(value.Value())
+		if err != nil || position < 1 || position > len(columns) {
+			return 0, fmt.Errorf("'order by' position %s is not in select list", value.Value())
+		}
+		return position - 1, nil
+	}
+
+	return -1, nil
+}
@@ -0,0 +1,422 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/kotsmile/jql/internal/lexer"
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+func newTestEngine(t *testing.T, tables map[string][]Row) *Engine {
+	t.Helper()
+
+	e := New(io.Discard)
+	for name, rows := range tables {
+		table, err := NewTable(rows)
+		if err != nil {
+			t.Fatalf("NewTable() error = %v", err)
+		}
+		e.loadedTables[name] = table
+	}
+
+	return e
+}
+
+func parseQuery(t *testing.T, cmd string) *parser.AstNode {
+	t.Helper()
+
+	logger := util.NewLoggerTest()
+	l := lexer.New(logger)
+	l.Lex(cmd)
+
+	queries, err := parser.New(l, logger).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("Parse() got %d queries, want 1", len(queries))
+	}
+
+	return queries[0]
+}
+
+func runSelect(t *testing.T, e *Engine, cmd string) (*resultSet, error) {
+	t.Helper()
+
+	statement, err := newSelectStatement(parseQuery(t, cmd))
+	if err != nil {
+		return nil, err
+	}
+
+	return e.selectQuery(statement, e.newScope(context.Background()))
+}
+
+var orders = []Row{
+	{"id": 1.0, "price": 2.5, "qty": 4.0, "first": "John", "last": "Doe"},
+	{"id": 2.0, "price": 10.0, "qty": 0.0, "first": "Jane", "last": "Roe"},
+}
+
+func Test_SelectExpressions(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": orders})
+
+	tests := []struct {
+		name        string
+		cmd         string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "arithmetic with alias",
+			cmd:         "select id, price * qty as total from t;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"total", NumberType}},
+			wantRows:    [][]any{{1.0, 10.0}, {2.0, 0.0}},
+		},
+		{
+			name:        "precedence",
+			cmd:         "select 1 + price * 2, (1 + price) * 2 from t;",
+			wantColumns: []resultColumn{{"1 + price * 2", NumberType}, {"(1 + price) * 2", NumberType}},
+			wantRows:    [][]any{{6.0, 7.0}, {21.0, 22.0}},
+		},
+		{
+			name:        "concatenation",
+			cmd:         "select first || ' ' || last as name, 'n' || id from t;",
+			wantColumns: []resultColumn{{"name", StringType}, {"'n' || id", StringType}},
+			wantRows:    [][]any{{"John Doe", "n1"}, {"Jane Roe", "n2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SelectExpressionErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": orders})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want error
+	}{
+		{
+			name: "division by zero",
+			cmd:  "select price / qty from t;",
+			want: ErrDivisionByZero,
+		},
+		{
+			name: "arithmetic on string",
+			cmd:  "select first * 2 from t;",
+			want: ErrTypeMismatch,
+		},
+		{
+			name: "aggregate without arguments",
+			cmd:  "select sum() from t;",
+			want: ErrWrongOperandNumber,
+		},
+		{
+			name: "unknown function without arguments",
+			cmd:  "select id from t where foo() = 1;",
+			want: ErrUnknownFunction,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSelect(t, e, tt.cmd)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("selectQuery() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+var people = []Row{
+	{"id": 1.0, "name": "John Doe", "status": "active", "email": "john@example.com"},
+	{"id": 2.0, "name": "jane roe", "status": "blocked", "email": nil},
+	{"id": 3.0, "name": "Greg_Lee", "status": "active", "email": "greg@test.org"},
+	{"id": 4.0, "name": "John Smith", "status": "pending"},
+}
+
+func Test_SelectWhere(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": people})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want []any
+	}{
+		{
+			name: "comparison",
+			cmd:  "select id from t where id >= 2 and id <> 4;",
+			want: []any{2.0, 3.0},
+		},
+		{
+			name: "like",
+			cmd:  "select id from t where name like 'John%';",
+			want: []any{1.0, 4.0},
+		},
+		{
+			name: "like with escaped underscore",
+			cmd:  "select id from t where name like '%\\_%';",
+			want: []any{3.0},
+		},
+		{
+			name: "ilike",
+			cmd:  "select id from t where name ilike 'J%_ROE';",
+			want: []any{2.0},
+		},
+		{
+			name: "regex operator",
+			cmd:  "select id from t where email ~ '\\.org$';",
+			want: []any{3.0},
+		},
+		{
+			name: "regexp_like with flags",
+			cmd:  "select id from t where regexp_like(name, '^john', 'i');",
+			want: []any{1.0, 4.0},
+		},
+		{
+			name: "in list",
+			cmd:  "select id from t where status in ('active', 'pending');",
+			want: []any{1.0, 3.0, 4.0},
+		},
+		{
+			name: "not in list",
+			cmd:  "select id from t where status not in ('active');",
+			want: []any{2.0, 4.0},
+		},
+		{
+			name: "between",
+			cmd:  "select id from t where id between 2 and 3;",
+			want: []any{2.0, 3.0},
+		},
+		{
+			name: "is null matches missing keys",
+			cmd:  "select id from t where email is null;",
+			want: []any{2.0, 4.0},
+		},
+		{
+			name: "is not null",
+			cmd:  "select id from t where email is not null or id = 2;",
+			want: []any{1.0, 2.0, 3.0},
+		},
+		{
+			name: "null comparison is unknown",
+			cmd:  "select id from t where not email = 'x';",
+			want: []any{1.0, 3.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+
+			ids := make([]any, 0)
+			for _, row := range got.rows {
+				ids = append(ids, row[0])
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("selectQuery() ids = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SelectWhereErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": people})
+
+	tests := []struct {
+		name string
+		cmd  string
+	}{
+		{
+			name: "non boolean condition",
+			cmd:  "select id from t where id + 1;",
+		},
+		{
+			name: "like on number",
+			cmd:  "select id from t where id like '1%';",
+		},
+		{
+			name: "comparing number with string",
+			cmd:  "select id from t where id < 'a';",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSelect(t, e, tt.cmd)
+			if !errors.Is(err, ErrTypeMismatch) {
+				t.Errorf("selectQuery() error = %v, want %v", err, ErrTypeMismatch)
+			}
+		})
+	}
+}
+
+var events = []Row{
+	{"id": 1.0, "status": "ok", "tags": []any{"a", "b"}, "meta": map[string]any{"x": 1.0, "y": "z"}},
+	{"id": 2.0, "status": "failed", "tags": []any{"a", "b"}, "meta": map[string]any{"y": "z", "x": 1.0}},
+	{"id": 3.0, "status": "ok", "tags": []any{"b", "a"}, "meta": nil},
+	{"id": 4.0, "status": "ok", "tags": nil, "meta": map[string]any{"x": 2.0}},
+}
+
+func Test_SelectMixedColumns(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"m": {
+		{"id": 1.0, "code": 5.0},
+		{"id": 2.0, "code": "x"},
+		{"id": 3.0, "code": 7.0},
+		{"id": 4.0, "code": true},
+	}})
+
+	tests := []struct {
+		name        string
+		cmd         string
+		wantColumns []resultColumn
+		wantRows    [][]any
+	}{
+		{
+			name:        "column type",
+			cmd:         "select code from m where id = 2;",
+			wantColumns: []resultColumn{{"code", MixedType}},
+			wantRows:    [][]any{{"x"}},
+		},
+		{
+			name:        "equality with a number",
+			cmd:         "select id from m where code = 5;",
+			wantColumns: []resultColumn{{"id", NumberType}},
+			wantRows:    [][]any{{1.0}},
+		},
+		{
+			name:        "comparison skips other types",
+			cmd:         "select id from m where code > 6 or code < 'y';",
+			wantColumns: []resultColumn{{"id", NumberType}},
+			wantRows:    [][]any{{2.0}, {3.0}},
+		},
+		{
+			name:        "like skips other types",
+			cmd:         "select id from m where code like '%';",
+			wantColumns: []resultColumn{{"id", NumberType}},
+			wantRows:    [][]any{{2.0}},
+		},
+		{
+			name:        "arithmetic on other types is null",
+			cmd:         "select id, code + 1 from m;",
+			wantColumns: []resultColumn{{"id", NumberType}, {"code + 1", NumberType}},
+			wantRows:    [][]any{{1.0, 6.0}, {2.0, nil}, {3.0, 8.0}, {4.0, nil}},
+		},
+		{
+			name:        "min and max order values by type",
+			cmd:         "select min(code), max(code) from m;",
+			wantColumns: []resultColumn{{"min(code)", MixedType}, {"max(code)", MixedType}},
+			wantRows:    [][]any{{true, "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.columns, tt.wantColumns) {
+				t.Errorf("selectQuery() columns = %v, want %v", got.columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_SelectDistinctAndAggregates(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": events})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want [][]any
+	}{
+		{
+			name: "distinct scalar",
+			cmd:  "select distinct status from t;",
+			want: [][]any{{"ok"}, {"failed"}},
+		},
+		{
+			name: "distinct compares arrays structurally",
+			cmd:  "select distinct tags from t;",
+			want: [][]any{{[]any{"a", "b"}}, {[]any{"b", "a"}}, {nil}},
+		},
+		{
+			name: "distinct compares objects structurally",
+			cmd:  "select distinct meta from t where meta is not null;",
+			want: [][]any{{map[string]any{"x": 1.0, "y": "z"}}, {map[string]any{"x": 2.0}}},
+		},
+		{
+			name: "count variants",
+			cmd:  "select count(*), count(meta), count(distinct status), count(distinct meta) from t;",
+			want: [][]any{{4.0, 3.0, 2.0, 2.0}},
+		},
+		{
+			name: "count on empty input",
+			cmd:  "select count(*), sum(id) from t where id > 10;",
+			want: [][]any{{0.0, nil}},
+		},
+		{
+			name: "group by",
+			cmd:  "select status, count(distinct tags) as tags, sum(id), max(id) from t group by status;",
+			want: [][]any{{"ok", 2.0, 8.0, 4.0}, {"failed", 1.0, 2.0, 2.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.want) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SelectNotGrouped(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": events})
+
+	_, err := runSelect(t, e, "select id, count(*) from t group by status;")
+	if !errors.Is(err, ErrNotGrouped) {
+		t.Errorf("selectQuery() error = %v, want %v", err, ErrNotGrouped)
+	}
+}
+
+func Test_SelectEmptyClauses(t *testing.T) {
+	// trees the parser does not build, but which must not be indexed past
+	// their ends
+	with := parser.NewAstNode(parser.NewKeyword(parser.WithKeyword))
+	if _, err := newWithStatement(with); err == nil {
+		t.Errorf("newWithStatement() of an empty 'with' error = nil")
+	}
+
+	join := parser.NewAstNode(parser.NewKeyword(parser.JoinKeyword))
+	if _, err := newJoinClause(join); err == nil {
+		t.Errorf("newJoinClause() of an empty 'join' error = nil")
+	}
+	join.AppendChild(parser.NewAstNode(parser.NewKeyword(parser.LeftKeyword)))
+	if _, err := newJoinClause(join); err == nil {
+		t.Errorf("newJoinClause() of an empty left 'join' error = nil")
+	}
+}
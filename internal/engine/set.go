@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var ErrColumnCountMismatch = errors.New("column count mismatch")
+
+// setOperation combines the rows of two statements with 'union',
+// 'intersect' or 'except'. Unless all is set, duplicate rows are removed.
+type setOperation struct {
+	operator string
+	all      bool
+	left     *selectStatement
+	right    *selectStatement
+}
+
+func newSetStatement(query *parser.AstNode) (*selectStatement, error) {
+	set := &setOperation{operator: query.Value().Value()}
+	statement := &selectStatement{set: set}
+
+	var operands []*selectStatement
+	for _, child := range query.Children() {
+		switch child.Value().Value() {
+		case parser.AllKeyword.String():
+			set.all = true
+		case parser.OrderKeyword.String():
+			statement.orderBy = newOrderItems(child)
+		case parser.LimitKeyword.String():
+			if err := statement.setLimit(child); err != nil {
+				return nil, err
+			}
+		default:
+			operand, err := newSelectStatement(child)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, operand)
+		}
+	}
+
+	if len(operands) != 2 {
+		return nil, fmt.Errorf("%w for '%s'", ErrWrongOperandNumber, set.operator)
+	}
+	set.left, set.right = operands[0], operands[1]
+
+	return statement, nil
+}
+
+// setOperator combines the rows of both sides of a set operation. For
+// 'intersect' and 'except' the rows of the right side are counted up
+// front, while 'union' reads one side after the other. Unless all is set,
+// the keys of the rows passed on are held to drop duplicates.
+type setOperator struct {
+	node   *setNode
+	left   resultOperator
+	right  resultOperator
+	result []resultColumn
+
+	// rows of the right side by their encoding, which makes nested values
+	// compare structurally
+	counts   map[string]int
+	seen     map[string]struct{}
+	leftDone bool
+}
+
+func (o *setOperator) Open() error {
+	if err := o.left.Open(); err != nil {
+		return err
+	}
+	if err := o.right.Open(); err != nil {
+		return err
+	}
+
+	columns, err := setColumns(o.node.operator, o.left.columns(), o.right.columns())
+	if err != nil {
+		return err
+	}
+	o.result = columns
+	o.seen = make(map[string]struct{})
+	if o.node.operator == parser.UnionKeyword.String() {
+		return nil
+	}
+
+	o.counts = make(map[string]int)
+	for {
+		row, ok, err := o.right.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		o.counts[valueKey(row...)]++
+	}
+}
+
+func (o *setOperator) Next() ([]any, bool, error) {
+	for {
+		row, ok, err := o.read()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if o.keep(row) {
+			return row, true, nil
+		}
+	}
+}
+
+// read reads the next row of the side being read.
+func (o *setOperator) read() ([]any, bool, error) {
+	if !o.leftDone {
+		row, ok, err := o.left.Next()
+		if err != nil || ok {
+			return row, ok, err
+		}
+		o.leftDone = true
+	}
+	if o.node.operator != parser.UnionKeyword.String() {
+		return nil, false, nil
+	}
+	return o.right.Next()
+}
+
+// keep reports whether a row is part of the result.
+func (o *setOperator) keep(row []any) bool {
+	key := valueKey(row...)
+	switch o.node.operator {
+	case parser.IntersectKeyword.String():
+		if o.counts[key] == 0 {
+			return false
+		}
+		o.counts[key]--
+	case parser.ExceptKeyword.String():
+		if o.counts[key] > 0 && o.node.all {
+			o.counts[key]--
+			return false
+		}
+		if o.counts[key] > 0 {
+			return false
+		}
+	}
+
+	if o.node.all {
+		return true
+	}
+	if _, ok := o.seen[key]; ok {
+		return false
+	}
+	o.seen[key] = struct{}{}
+	return true
+}
+
+func (o *setOperator) Close() error {
+	o.counts, o.seen = nil, nil
+	return errors.Join(o.left.Close(), o.right.Close())
+}
+
+func (o *setOperator) columns() []resultColumn { return o.result }
+
+// setColumns checks that both sides of a set operation have matching
+// columns. The result is named after the left side, null columns take the
+// type of the other side and columns of several types are mixed.
+func setColumns(operator string, left, right []resultColumn) ([]resultColumn, error) {
+	if len(left) != len(right) {
+		return nil, fmt.Errorf("%w: '%s' of %d and %d columns", ErrColumnCountMismatch, operator, len(left), len(right))
+	}
+
+	columns := make([]resultColumn, 0, len(left))
+	for i := range left {
+		column := left[i]
+		switch t := right[i].ColumnType; {
+		case column.ColumnType == NullType:
+			column.ColumnType = t
+		case t == NullType || t == column.ColumnType:
+		case column.ColumnType == MixedType || t == MixedType:
+			column.ColumnType = MixedType
+		default:
+			return nil, fmt.Errorf("%w: '%s' column %d ('%s') is %s and %s", ErrTypeMismatch, operator, i+1, column.Name, column.ColumnType, t)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
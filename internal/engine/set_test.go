@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var yesterday = []Row{
+	{"id": 1.0, "tags": []any{"a"}, "meta": map[string]any{"x": 1.0}},
+	{"id": 2.0, "tags": []any{"b"}, "meta": nil},
+	{"id": 3.0, "tags": nil, "meta": map[string]any{"x": 3.0}},
+	{"id": 3.0, "tags": nil, "meta": map[string]any{"x": 3.0}},
+}
+
+var today = []Row{
+	{"id": 2.0, "tags": []any{"b"}, "meta": nil},
+	{"id": 3.0, "tags": nil, "meta": map[string]any{"x": 3.0}},
+	{"id": 4.0, "tags": []any{"c"}, "meta": map[string]any{"x": 4.0, "y": 1.0}},
+}
+
+func Test_SelectSetOperations(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"yesterday": yesterday, "today": today})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want [][]any
+	}{
+		{
+			name: "union removes duplicates",
+			cmd:  "select id from yesterday union select id from today;",
+			want: [][]any{{1.0}, {2.0}, {3.0}, {4.0}},
+		},
+		{
+			name: "union all keeps duplicates",
+			cmd:  "select id from yesterday where id > 1 union all select id from today order by 1 desc;",
+			want: [][]any{{4.0}, {3.0}, {3.0}, {3.0}, {2.0}, {2.0}},
+		},
+		{
+			name: "disappeared ids",
+			cmd:  "select id from yesterday except select id from today;",
+			want: [][]any{{1.0}},
+		},
+		{
+			name: "new ids",
+			cmd:  "select id from today except select id from yesterday;",
+			want: [][]any{{4.0}},
+		},
+		{
+			name: "except all subtracts counts",
+			cmd:  "select id from yesterday except all select id from today;",
+			want: [][]any{{1.0}, {3.0}},
+		},
+		{
+			name: "intersect compares nested values structurally",
+			cmd:  "select tags, meta from yesterday intersect select tags, meta from today;",
+			want: [][]any{{[]any{"b"}, nil}, {nil, map[string]any{"x": 3.0}}},
+		},
+		{
+			name: "intersect binds tighter than union",
+			cmd:  "select id from yesterday where id = 1 union select id from yesterday intersect select id from today order by id;",
+			want: [][]any{{1.0}, {2.0}, {3.0}},
+		},
+		{
+			name: "null column takes the type of the other side",
+			cmd:  "select null as id from today where id = 4 union select id from today;",
+			want: [][]any{{nil}, {2.0}, {3.0}, {4.0}},
+		},
+		{
+			name: "in subquery",
+			cmd:  "select id from today where id in (select id from yesterday union select 4 from today);",
+			want: [][]any{{2.0}, {3.0}, {4.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.want) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SelectSetOperationErrors(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"yesterday": yesterday, "today": today})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want error
+	}{
+		{
+			name: "column count",
+			cmd:  "select id, tags from yesterday union select id from today;",
+			want: ErrColumnCountMismatch,
+		},
+		{
+			name: "column types",
+			cmd:  "select id from yesterday union select tags from today;",
+			want: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSelect(t, e, tt.cmd)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("selectQuery() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
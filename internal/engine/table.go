@@ -3,6 +3,9 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"sort"
+	"time"
 )
 
 var (
@@ -20,6 +23,10 @@ const (
 	ArrayType   columnType = "array"
 	ObjectType  columnType = "object"
 	NullType    columnType = "null"
+	// mixed columns hold values of several types, as json fields often do
+	MixedType columnType = "mixed"
+	// timestamps only come from conversions, json has no such type
+	TimestampType columnType = "timestamp"
 )
 
 type columnDefinition struct {
@@ -34,6 +41,12 @@ type (
 type Table struct {
 	columns columns
 	rows    rows
+	// order of the columns for '*', sorted by name when empty
+	order []string
+	// file the table was loaded from, nil for other tables
+	source *source
+	// hash indexes, in the order they were created
+	indexes []*hashIndex
 }
 
 type Row map[string]any
@@ -177,6 +190,26 @@ func parseColumns(data []Row) (columns, error) {
 						})
 					}
 				}
+			case time.Time:
+				if cv, ok := columnsCollection[key]; !ok {
+					columnsCollection[key] = append(columnsCollection[key], columnDefinition{
+						ColumnType: TimestampType,
+					})
+				} else {
+					found := false
+					for _, c := range cv {
+						if c.ColumnType == TimestampType {
+							found = true
+							break
+						}
+					}
+
+					if !found {
+						columnsCollection[key] = append(columnsCollection[key], columnDefinition{
+							ColumnType: TimestampType,
+						})
+					}
+				}
 			case nil:
 				if cv, ok := columnsCollection[key]; !ok {
 					columnsCollection[key] = append(columnsCollection[key], columnDefinition{
@@ -230,7 +263,7 @@ func parseColumns(data []Row) (columns, error) {
 				}
 			} else {
 				columns[key] = columnDefinition{
-					ColumnType: StringType,
+					ColumnType: MixedType,
 				}
 			}
 		}
@@ -269,41 +302,155 @@ func NewTable(rows []Row) (*Table, error) {
 	}, nil
 }
 
-func (t *Table) Columns() columns {
-	return t.columns
+// addRows appends rows to a table, merging the types of their values into
+// the column types and adding them to the indexes.
+func (t *Table) addRows(rows []Row) error {
+	if err := t.mergeColumns(rows); err != nil {
+		return err
+	}
+
+	start := len(t.rows)
+	t.rows = append(t.rows, rows...)
+	for _, index := range t.indexes {
+		for i, row := range rows {
+			index.add(start+i, row[index.column])
+		}
+	}
+	return nil
 }
 
-func (t *Table) Rows() rows {
-	return t.rows
+// updateRows replaces the rows of a table with rows that differ from them
+// at the positions in changed, merging the types of the changed rows into
+// the column types and moving them in the indexes.
+func (t *Table) updateRows(rows []Row, changed []int) error {
+	updated := make([]Row, 0, len(changed))
+	for _, i := range changed {
+		updated = append(updated, rows[i])
+	}
+	if err := t.mergeColumns(updated); err != nil {
+		return err
+	}
+
+	for _, index := range t.indexes {
+		for _, i := range changed {
+			index.move(i, t.rows[i][index.column], rows[i][index.column])
+		}
+	}
+	t.rows = rows
+	return nil
 }
 
-type SqliteColumn struct {
-	Name       string
-	SqliteType string
+// setRows replaces the rows of a table, which keeps its column types, and
+// builds its indexes again.
+func (t *Table) setRows(rows []Row) {
+	t.rows = rows
+	t.rebuildIndexes()
 }
 
-func (t *Table) ToSqliteTypes() []SqliteColumn {
-	var cs []SqliteColumn
-	for name, column := range t.columns {
-		dbType := ""
-		switch column.ColumnType {
-		case StringType:
-			dbType = "TEXT"
-		case NumberType:
-			dbType = "REAL"
-		case BooleanType:
-			dbType = "BOOLEAN"
-		case ArrayType:
-			dbType = "TEXT"
-		case ObjectType:
-			dbType = "TEXT"
-		case NullType:
-			dbType = "TEXT"
+// mergeColumns merges the types of the values of rows into the column
+// types. A column holding values of different types is mixed, as in
+// parseColumns.
+func (t *Table) mergeColumns(rows []Row) error {
+	added, err := parseColumns(rows)
+	if err != nil {
+		return err
+	}
+
+	// a new map keeps the columns of copies of the table intact
+	var columns columns
+	for name, column := range added {
+		current, ok := t.columns[name]
+		if ok {
+			column.ColumnType = mergeTypes(current.ColumnType, column.ColumnType)
+			if column == current {
+				continue
+			}
+		}
+		if columns == nil {
+			columns = maps.Clone(t.columns)
 		}
-		cs = append(cs, SqliteColumn{
-			Name:       name,
-			SqliteType: dbType,
-		})
+		columns[name] = column
 	}
-	return cs
+	if columns != nil {
+		t.columns = columns
+	}
+	return nil
+}
+
+func mergeTypes(a, b columnType) columnType {
+	switch {
+	case a == b || b == NullType:
+		return a
+	case a == NullType:
+		return b
+	}
+	return MixedType
+}
+
+// checkValue validates a value written to a column against its type. New
+// columns, null values and columns holding only nulls accept anything.
+func (t *Table) checkValue(name string, value any) error {
+	column, ok := t.columns[name]
+	if !ok || compatibleTypes(column.ColumnType, valueType(value)) {
+		return nil
+	}
+	return fmt.Errorf("%w: column '%s' is %s, got %s", ErrTypeMismatch, name, column.ColumnType, valueType(value))
+}
+
+// compatibleTypes reports whether values of type b can be stored in a
+// column of type a.
+func compatibleTypes(a, b columnType) bool {
+	return a == b || !knownType(a) || !knownType(b)
+}
+
+// knownType reports whether all values of a type have that type. Null
+// columns hold no values, and mixed columns values of several types.
+func knownType(t columnType) bool {
+	return t != NullType && t != MixedType
+}
+
+// newResultTable turns the result of a query into a table, keeping the
+// order of its columns. Unlike NewTable it accepts an empty result.
+func newResultTable(result *resultSet) *Table {
+	table := &Table{
+		columns: make(columns),
+	}
+	for _, column := range result.columns {
+		if _, ok := table.columns[column.Name]; !ok {
+			table.order = append(table.order, column.Name)
+		}
+		table.columns[column.Name] = columnDefinition{ColumnType: column.ColumnType}
+	}
+
+	for _, values := range result.rows {
+		row := make(Row)
+		for i, column := range result.columns {
+			row[column.Name] = values[i]
+		}
+		table.rows = append(table.rows, row)
+	}
+
+	return table
+}
+
+// columnNames lists the columns in the order '*' expands to.
+func (t *Table) columnNames() []string {
+	if len(t.order) > 0 {
+		return t.order
+	}
+
+	names := make([]string, 0, len(t.columns))
+	for name := range t.columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Table) Columns() columns {
+	return t.columns
+}
+
+func (t *Table) Rows() rows {
+	return t.rows
 }
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var (
+	ErrTransactionInProgress = errors.New("transaction already in progress")
+	ErrNoTransaction         = errors.New("no transaction in progress")
+	ErrSaveInTransaction     = errors.New("cannot save inside a transaction")
+)
+
+// Session is a client of the engine, like a network connection. A
+// transaction belongs to the session that began it: until it ends, queries
+// of other sessions read the tables as they were when it began, and their
+// other statements wait for it, so that they neither see its changes nor
+// have theirs rolled back with it. Statements run with a context that
+// carries no session belong to a default session.
+type Session struct {
+	// sessions must be distinct pointers
+	_ byte
+}
+
+func NewSession() *Session {
+	return &Session{}
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context whose statements run in a session.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionOf(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// transaction holds the state of the engine from when it began. Tables are
+// not copied: the snapshot shares them with the engine until a statement
+// writes to one, which then gets its own copy (see writableTable).
+type transaction struct {
+	tables map[string]*Table
+	views  map[string]*parser.AstNode
+	// tables shared with the snapshot, which must not be changed in place
+	shared map[*Table]struct{}
+	owner  *Session
+	// closed when the transaction ends
+	done chan struct{}
+}
+
+// catalog holds the tables and the views that statements see.
+type catalog struct {
+	tables map[string]*Table
+	views  map[string]*parser.AstNode
+}
+
+// visible returns the tables and the views the session of ctx sees: those
+// of the engine, or the snapshot of a transaction of another session.
+func (c *Engine) visible(ctx context.Context) catalog {
+	if t := c.transaction; t != nil && t.owner != sessionOf(ctx) {
+		return catalog{tables: t.tables, views: t.views}
+	}
+	return catalog{tables: c.loadedTables, views: c.views}
+}
+
+// InTransaction reports whether the session of ctx has a transaction in
+// progress.
+func (c *Engine) InTransaction(ctx context.Context) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.transaction != nil && c.transaction.owner == sessionOf(ctx)
+}
+
+// EndSession rolls back the transaction of a session, if it has one. It is
+// called once the session is closed.
+func (c *Engine) EndSession(session *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.transaction != nil && c.transaction.owner == session {
+		c.rollbackCommand()
+	}
+}
+
+// lock takes the lock of the engine for a statement, exclusive or shared.
+// Shared locks are taken at once, since readers of other sessions see the
+// snapshot of a transaction, while exclusive ones wait until no other
+// session has a transaction in progress. It stops with the error of ctx
+// when it is done first.
+func (c *Engine) lock(ctx context.Context, exclusive bool) error {
+	session := sessionOf(ctx)
+	for {
+		if exclusive {
+			c.mu.Lock()
+		} else {
+			c.mu.RLock()
+		}
+		t := c.transaction
+		if t == nil || t.owner == session || !exclusive {
+			return nil
+		}
+		c.unlock(exclusive)
+
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Engine) unlock(exclusive bool) {
+	if exclusive {
+		c.mu.Unlock()
+	} else {
+		c.mu.RUnlock()
+	}
+}
+
+func (c *Engine) beginCommand(ctx context.Context) error {
+	if c.transaction != nil {
+		return ErrTransactionInProgress
+	}
+
+	shared := make(map[*Table]struct{}, len(c.loadedTables))
+	for _, table := range c.loadedTables {
+		shared[table] = struct{}{}
+	}
+
+	c.transaction = &transaction{
+		tables: maps.Clone(c.loadedTables),
+		views:  maps.Clone(c.views),
+		shared: shared,
+		owner:  sessionOf(ctx),
+		done:   make(chan struct{}),
+	}
+	return nil
+}
+
+func (c *Engine) commitCommand() error {
+	if c.transaction == nil {
+		return ErrNoTransaction
+	}
+
+	c.endTransaction()
+	return nil
+}
+
+func (c *Engine) rollbackCommand() error {
+	if c.transaction == nil {
+		return ErrNoTransaction
+	}
+
+	c.loadedTables = c.transaction.tables
+	c.views = c.transaction.views
+	c.endTransaction()
+	return nil
+}
+
+func (c *Engine) endTransaction() {
+	close(c.transaction.done)
+	c.transaction = nil
+}
+
+// writableTable returns a table that a statement can change. Inside a
+// transaction, a table still shared with the snapshot is replaced by a
+// shallow copy first. Rows are never changed in place, so the copy can
+// share them; its rows and indexes are clipped and copied, so that adding
+// to them leaves the snapshot intact.
+func (c *Engine) writableTable(name string) (*Table, error) {
+	table, err := c.loadedTable(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.transaction == nil {
+		return table, nil
+	}
+	if _, ok := c.transaction.shared[table]; !ok {
+		return table, nil
+	}
+
+	copied := *table
+	copied.rows = slices.Clip(table.rows)
+	copied.indexes = make([]*hashIndex, 0, len(table.indexes))
+	for _, index := range table.indexes {
+		copied.indexes = append(copied.indexes, index.clone())
+	}
+	c.loadedTables[name] = &copied
+	return &copied, nil
+}
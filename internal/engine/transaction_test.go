@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_Transaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmds     []string
+		query    string
+		wantRows [][]any
+	}{
+		{
+			name:     "rollback restores tables",
+			cmds:     []string{"begin;", "update t set score = 0;", "delete from t where id = 1;", "insert into t (id) values (9);", "rollback;"},
+			query:    "select id, score from t order by id;",
+			wantRows: [][]any{{1.0, 10.0}, {2.0, nil}, {3.0, 30.0}},
+		},
+		{
+			name:     "commit keeps changes",
+			cmds:     []string{"begin;", "delete from t where id = 1;", "commit;"},
+			query:    "select id from t order by id;",
+			wantRows: [][]any{{2.0}, {3.0}},
+		},
+		{
+			name:     "rollback restores dropped and renamed tables",
+			cmds:     []string{"begin;", "drop table u;", "alter table t rename to v;", "rollback;"},
+			query:    "select count(*) from t where id in (select id from u);",
+			wantRows: [][]any{{3.0}},
+		},
+		{
+			name:     "rollback removes created tables",
+			cmds:     []string{"begin;", "create table u2 as select id from t;", "create view v as select id from t;", "rollback;", "create view u2 as select id from u;"},
+			query:    "select count(*) from u2;",
+			wantRows: [][]any{{3.0}},
+		},
+		{
+			name:     "changes after rollback",
+			cmds:     []string{"begin;", "update t set score = 1;", "rollback;", "update t set score = 2 where id = 1;"},
+			query:    "select score from t where id = 1;",
+			wantRows: [][]any{{2.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows(), "u": fixtureRows()})
+			for _, cmd := range tt.cmds {
+				if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+					t.Fatalf("Process(%s) error = %v", cmd, err)
+				}
+			}
+
+			got, err := runSelect(t, e, tt.query)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.wantRows) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func Test_TransactionCopyOnWrite(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows(), "u": fixtureRows()})
+	t0, u0 := e.loadedTables["t"], e.loadedTables["u"]
+	rows := slices.Clone(t0.rows)
+
+	for _, cmd := range []string{"begin;", "update t set score = 0;", "insert into t (id) values (9);"} {
+		if err := e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+			t.Fatalf("Process(%s) error = %v", cmd, err)
+		}
+	}
+
+	if e.loadedTables["t"] == t0 {
+		t.Errorf("changed table is shared with the snapshot")
+	}
+	if e.loadedTables["u"] != u0 {
+		t.Errorf("unchanged table was copied")
+	}
+	if !reflect.DeepEqual(t0.rows, rows) {
+		t.Errorf("snapshot rows = %v, want %v", t0.rows, rows)
+	}
+}
+
+func Test_TransactionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmds    []string
+		wantErr error
+	}{
+		{
+			name:    "commit without begin",
+			cmds:    []string{"commit;"},
+			wantErr: ErrNoTransaction,
+		},
+		{
+			name:    "rollback after commit",
+			cmds:    []string{"begin;", "commit;", "rollback;"},
+			wantErr: ErrNoTransaction,
+		},
+		{
+			name:    "nested begin",
+			cmds:    []string{"begin;", "begin;"},
+			wantErr: ErrTransactionInProgress,
+		},
+		{
+			name:    "save inside a transaction",
+			cmds:    []string{"begin;", "save t;"},
+			wantErr: ErrSaveInTransaction,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+
+			var err error
+			for _, cmd := range tt.cmds {
+				if err = e.Process(context.Background(), parseQuery(t, cmd)); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_TransactionSessions(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": fixtureRows()})
+	a := WithSession(context.Background(), NewSession())
+	b := WithSession(context.Background(), NewSession())
+
+	process := func(ctx context.Context, cmd string) error {
+		return e.Process(ctx, parseQuery(t, cmd))
+	}
+	count := func(ctx context.Context) any {
+		cursor, err := e.Query(ctx, parseQuery(t, "select count(*) from t;"))
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		defer cursor.Close()
+		values, _, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		return values[0]
+	}
+
+	if err := process(a, "begin;"); err != nil {
+		t.Fatalf("Process(begin) error = %v", err)
+	}
+	if err := process(a, "delete from t where id = 1;"); err != nil {
+		t.Fatalf("Process(delete) error = %v", err)
+	}
+	if !e.InTransaction(a) || e.InTransaction(b) {
+		t.Errorf("InTransaction() = %v, %v, want true, false", e.InTransaction(a), e.InTransaction(b))
+	}
+
+	// queries of another session read the tables from before the
+	// transaction without waiting for it, while its other statements wait
+	if got := count(b); got != 3.0 {
+		t.Errorf("rows in another session = %v, want 3", got)
+	}
+	if got := count(a); got != 2.0 {
+		t.Errorf("rows in the transaction = %v, want 2", got)
+	}
+
+	inserted := make(chan error, 1)
+	go func() {
+		_, err := e.Exec(b, parseQuery(t, "insert into t (id) values (9);"))
+		inserted <- err
+	}()
+	select {
+	case err := <-inserted:
+		t.Fatalf("Exec() in another session ran during the transaction, error = %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// the insert runs once the transaction is rolled back, and is kept
+	if err := process(a, "rollback;"); err != nil {
+		t.Fatalf("Process(rollback) error = %v", err)
+	}
+	if err := <-inserted; err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if got := count(b); got != 4.0 {
+		t.Errorf("rows = %v, want 4", got)
+	}
+
+	// ending a session rolls back its transaction
+	if err := process(b, "begin;"); err != nil {
+		t.Fatalf("Process(begin) error = %v", err)
+	}
+	if err := process(b, "delete from t;"); err != nil {
+		t.Fatalf("Process(delete) error = %v", err)
+	}
+	e.EndSession(sessionOf(b))
+	if got := count(a); got != 4.0 {
+		t.Errorf("rows after the session ended = %v, want 4", got)
+	}
+}
@@ -0,0 +1,508 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+type windowFunction struct {
+	arguments  []columnType
+	optional   int
+	returnType func(arguments []columnType) columnType
+	compute    func(w *window, arguments [][]any) ([]any, error)
+}
+
+var windowFunctions map[string]windowFunction
+
+func init() {
+	number := func([]columnType) columnType {
+		return NumberType
+	}
+	first := func(arguments []columnType) columnType {
+		t, _ := util.At(arguments, 0)
+		return t
+	}
+	rank := func(dense bool) func(*window, [][]any) ([]any, error) {
+		return func(w *window, _ [][]any) ([]any, error) {
+			values := make([]any, len(w.rows))
+			r := 0
+			for i := range w.rows {
+				if i == 0 || !w.peers(i-1, i) {
+					if dense {
+						r++
+					} else {
+						r = i + 1
+					}
+				}
+				values[i] = float64(r)
+			}
+			return values, nil
+		}
+	}
+	shift := func(direction int) func(*window, [][]any) ([]any, error) {
+		return func(w *window, arguments [][]any) ([]any, error) {
+			values := make([]any, len(w.rows))
+			for i := range w.rows {
+				offset := 1.0
+				if o, ok := util.At(arguments, 1); ok && o[i] != nil {
+					offset, ok = toNumber(o[i])
+					if !ok {
+						return nil, fmt.Errorf("%w: offset must be number, got %s", ErrTypeMismatch, valueType(o[i]))
+					}
+				}
+
+				j := i + direction*int(offset)
+				if j >= 0 && j < len(w.rows) {
+					values[i] = arguments[0][j]
+				} else if d, ok := util.At(arguments, 2); ok {
+					values[i] = d[i]
+				}
+			}
+			return values, nil
+		}
+	}
+
+	windowFunctions = map[string]windowFunction{
+		"row_number": {
+			returnType: number,
+			compute: func(w *window, _ [][]any) ([]any, error) {
+				values := make([]any, len(w.rows))
+				for i := range w.rows {
+					values[i] = float64(i + 1)
+				}
+				return values, nil
+			},
+		},
+		"rank": {
+			returnType: number,
+			compute:    rank(false),
+		},
+		"dense_rank": {
+			returnType: number,
+			compute:    rank(true),
+		},
+		"lag": {
+			arguments:  []columnType{"", NumberType, ""},
+			optional:   2,
+			returnType: first,
+			compute:    shift(-1),
+		},
+		"lead": {
+			arguments:  []columnType{"", NumberType, ""},
+			optional:   2,
+			returnType: first,
+			compute:    shift(1),
+		},
+		"first_value": {
+			arguments:  []columnType{""},
+			returnType: first,
+			compute: func(w *window, arguments [][]any) ([]any, error) {
+				values := make([]any, len(w.rows))
+				for i := range w.rows {
+					values[i] = arguments[0][0]
+				}
+				return values, nil
+			},
+		},
+	}
+}
+
+// window is one partition of rows, sorted by the window order.
+type window struct {
+	rows []Row
+	keys [][]any
+}
+
+// peers reports whether two rows of the window share their sort keys.
+func (w *window) peers(i, j int) bool {
+	return compareKeys(w.keys[i], w.keys[j], nil) == 0
+}
+
+type orderItem struct {
+	expression *parser.AstNode
+	descending bool
+}
+
+func newOrderItems(node *parser.AstNode) []orderItem {
+	items := make([]orderItem, 0, len(node.Children()))
+	for _, child := range node.Children() {
+		if keyword, ok := child.Value().(*parser.KeywordNode); ok && keyword.Value() == parser.DescKeyword.String() {
+			expression, _ := util.At(child.Children(), 0)
+			items = append(items, orderItem{expression: expression, descending: true})
+			continue
+		}
+		items = append(items, orderItem{expression: child})
+	}
+	return items
+}
+
+// compareKeys orders sort keys, with descending set per key. Null sorts
+// after every other value, as if it was the largest one.
+func compareKeys(a, b []any, descending []bool) int {
+	for i := range a {
+		c := compareSort(a[i], b[i])
+		if d, ok := util.At(descending, i); ok && d {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareSort is a total order over values: values of different types are
+// ordered by type, nested values by their encoding.
+func compareSort(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		}
+		return -1
+	}
+
+	if c, err := compareValues("", a, b); err == nil {
+		return c
+	}
+
+	at, bt := valueType(a), valueType(b)
+	if at != bt {
+		return strings.Compare(string(at), string(bt))
+	}
+	return strings.Compare(valueKey(a), valueKey(b))
+}
+
+func isWindow(node *parser.AstNode) bool {
+	if _, ok := node.Value().(parser.FunctionNode); !ok {
+		return false
+	}
+
+	last, ok := util.At(node.Children(), len(node.Children())-1)
+	if !ok {
+		return false
+	}
+	keyword, ok := last.Value().(*parser.KeywordNode)
+	return ok && keyword.Value() == parser.OverKeyword.String()
+}
+
+func containsWindow(node *parser.AstNode) bool {
+	if isWindow(node) {
+		return true
+	}
+	if isSubquery(node) {
+		return false
+	}
+	for _, child := range node.Children() {
+		if containsWindow(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// windowCall splits a window call into its arguments, partitioning
+// expressions and order.
+func windowCall(node *parser.AstNode) ([]*parser.AstNode, []*parser.AstNode, []orderItem) {
+	children := node.Children()
+	over := children[len(children)-1]
+
+	var partitionBy []*parser.AstNode
+	var orderBy []orderItem
+	for _, child := range over.Children() {
+		switch child.Value().Value() {
+		case parser.PartitionKeyword.String():
+			partitionBy = child.Children()
+		case parser.OrderKeyword.String():
+			orderBy = newOrderItems(child)
+		}
+	}
+
+	return children[:len(children)-1], partitionBy, orderBy
+}
+
+func windowType(node *parser.AstNode, s *scope) (columnType, error) {
+	name := node.Value().Value()
+	arguments, partitionBy, orderBy := windowCall(node)
+
+	for _, expression := range partitionBy {
+		if _, err := expressionType(expression, s); err != nil {
+			return "", err
+		}
+	}
+	for _, item := range orderBy {
+		if _, err := expressionType(item.expression, s); err != nil {
+			return "", err
+		}
+	}
+
+	if _, ok := aggregates[name]; ok {
+		call := parser.NewAstNode(parser.FunctionNode(name))
+		for _, argument := range arguments {
+			call.AppendChild(argument)
+		}
+		return aggregateType(call, s)
+	}
+
+	f, ok := windowFunctions[name]
+	if !ok {
+		return "", fmt.Errorf("%w: window function '%s'", ErrUnknownFunction, name)
+	}
+	if len(arguments) > len(f.arguments) || len(arguments) < len(f.arguments)-f.optional {
+		return "", fmt.Errorf("%w: '%s' takes %d arguments, got %d", ErrWrongOperandNumber, name, len(f.arguments), len(arguments))
+	}
+
+	types := make([]columnType, 0, len(arguments))
+	for i, argument := range arguments {
+		t, err := expressionType(argument, s)
+		if err != nil {
+			return "", err
+		}
+		if f.arguments[i] != "" && knownType(t) && t != f.arguments[i] {
+			return "", fmt.Errorf("%w: argument %d of '%s' must be %s, got %s", ErrTypeMismatch, i+1, name, f.arguments[i], t)
+		}
+		types = append(types, t)
+	}
+
+	return f.returnType(types), nil
+}
+
+// computeWindow evaluates a window call for every row, returning the values
+// in the order of rows.
+func computeWindow(node *parser.AstNode, s *scope, rows []Row) ([]any, error) {
+	name := node.Value().Value()
+	arguments, partitionBy, orderBy := windowCall(node)
+
+	descending := make([]bool, 0, len(orderBy))
+	for _, item := range orderBy {
+		descending = append(descending, item.descending)
+	}
+
+	var keys []string
+	partitions := make(map[string][]int)
+	sortKeys := make([][]any, len(rows))
+	for i, row := range rows {
+		values, err := evalExpressions(partitionBy, s.withRow(row))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: 'partition by': %w", i+1, err)
+		}
+
+		key := valueKey(values...)
+		if _, ok := partitions[key]; !ok {
+			keys = append(keys, key)
+		}
+		partitions[key] = append(partitions[key], i)
+
+		for _, item := range orderBy {
+			value, err := evalExpression(item.expression, s.withRow(row))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: 'order by': %w", i+1, err)
+			}
+			sortKeys[i] = append(sortKeys[i], value)
+		}
+	}
+
+	result := make([]any, len(rows))
+	for _, key := range keys {
+		indexes := partitions[key]
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return compareKeys(sortKeys[indexes[a]], sortKeys[indexes[b]], descending) < 0
+		})
+
+		w := &window{}
+		for _, i := range indexes {
+			w.rows = append(w.rows, rows[i])
+			w.keys = append(w.keys, sortKeys[i])
+		}
+
+		var values []any
+		var err error
+		if _, ok := aggregates[name]; ok {
+			values, err = runningAggregate(node, s, w, len(orderBy) > 0)
+		} else {
+			values, err = callWindowFunction(windowFunctions[name], s, w, arguments)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", node.Text(), err)
+		}
+
+		for j, i := range indexes {
+			result[i] = values[j]
+		}
+	}
+
+	return result, nil
+}
+
+func callWindowFunction(f windowFunction, s *scope, w *window, arguments []*parser.AstNode) ([]any, error) {
+	values := make([][]any, len(arguments))
+	for i, argument := range arguments {
+		for j, row := range w.rows {
+			value, err := evalExpression(argument, s.withRow(row))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", j+1, err)
+			}
+			values[i] = append(values[i], value)
+		}
+	}
+
+	return f.compute(w, values)
+}
+
+// runningAggregate applies an aggregate to the frame of every row: the rows
+// up to the row and its peers when the window is ordered, the whole
+// partition otherwise.
+func runningAggregate(node *parser.AstNode, s *scope, w *window, ordered bool) ([]any, error) {
+	arguments, _, _ := windowCall(node)
+
+	call := parser.NewAstNode(parser.FunctionNode(node.Value().Value()))
+	for _, argument := range arguments {
+		call.AppendChild(argument)
+	}
+
+	values := make([]any, len(w.rows))
+	if !ordered {
+		value, err := computeAggregate(call, s, w.rows)
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			values[i] = value
+		}
+		return values, nil
+	}
+
+	for i := 0; i < len(w.rows); {
+		end := i + 1
+		for end < len(w.rows) && w.peers(i, end) {
+			end++
+		}
+
+		value, err := computeAggregate(call, s, w.rows[:end])
+		if err != nil {
+			return nil, err
+		}
+		for ; i < end; i++ {
+			values[i] = value
+		}
+	}
+
+	return values, nil
+}
+
+// windowColumns rewrites select columns to refer to the values of window
+// calls, which windowRows stores under the text of the calls, like
+// aggregateColumns does for aggregates. It returns the calls along with the
+// columns.
+func windowColumns(selected []selectColumn) ([]*parser.AstNode, []selectColumn) {
+	var calls []*parser.AstNode
+	rewritten := make([]selectColumn, 0, len(selected))
+	for _, column := range selected {
+		expression := rewriteExpression(column.expression, func(node *parser.AstNode) *parser.AstNode {
+			if !isWindow(node) {
+				return nil
+			}
+
+			calls = append(calls, node)
+			return parser.NewAstNode(parser.ColumnNode(node.Text()))
+		})
+
+		rewritten = append(rewritten, selectColumn{
+			expression: expression,
+			name:       column.name,
+		})
+	}
+
+	return calls, rewritten
+}
+
+// windowRows computes window calls for every row. The values are stored in
+// copies of the rows under the text of their call.
+func windowRows(rows []Row, s *scope, calls []*parser.AstNode) ([]Row, columns, error) {
+	windowColumns := make(columns)
+	for name, column := range s.columns {
+		windowColumns[name] = column
+	}
+
+	windowed := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		r := make(Row)
+		for name, value := range row {
+			r[name] = value
+		}
+		windowed = append(windowed, r)
+	}
+
+	for _, call := range calls {
+		for _, argument := range call.Children() {
+			if containsWindow(argument) {
+				return nil, nil, fmt.Errorf("window calls cannot be nested in '%s'", call.Text())
+			}
+		}
+
+		t, err := windowType(call, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		windowColumns[call.Text()] = columnDefinition{ColumnType: t}
+
+		values, err := computeWindow(call, s, rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, value := range values {
+			windowed[i][call.Text()] = value
+		}
+	}
+
+	return windowed, windowColumns, nil
+}
+
+// windowOperator computes window calls, which needs all rows of its input.
+type windowOperator struct {
+	node  *windowNode
+	input rowOperator
+	bound *scope
+	rows  []Row
+}
+
+func (o *windowOperator) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+
+	rows, err := readAll[Row](o.input)
+	if err != nil {
+		return err
+	}
+	rows, columns, err := windowRows(rows, o.input.scope(), o.node.calls)
+	if err != nil {
+		return fmt.Errorf("table '%s': %w", o.input.name(), err)
+	}
+	o.rows = rows
+	o.bound = o.input.scope().withColumns(columns)
+	return nil
+}
+
+func (o *windowOperator) Next() (Row, bool, error) {
+	if len(o.rows) == 0 {
+		return nil, false, nil
+	}
+	row := o.rows[0]
+	o.rows = o.rows[1:]
+	return row, true, nil
+}
+
+func (o *windowOperator) Close() error {
+	o.rows = nil
+	return o.input.Close()
+}
+
+func (o *windowOperator) scope() *scope { return o.bound }
+func (o *windowOperator) name() string  { return o.input.name() }
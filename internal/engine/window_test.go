@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+var sessions = []Row{
+	{"user": "a", "ts": 3.0, "amount": 10.0},
+	{"user": "b", "ts": 1.0, "amount": 5.0},
+	{"user": "a", "ts": 1.0, "amount": 20.0},
+	{"user": "a", "ts": 2.0, "amount": 20.0},
+	{"user": "b", "ts": 2.0, "amount": 5.0},
+	{"user": "a", "ts": 4.0, "amount": nil},
+}
+
+func Test_SelectWindow(t *testing.T) {
+	e := newTestEngine(t, map[string][]Row{"t": sessions})
+
+	tests := []struct {
+		name string
+		cmd  string
+		want [][]any
+	}{
+		{
+			name: "row_number per partition",
+			cmd:  "select user, ts, row_number() over (partition by user order by ts) as n from t order by user, ts;",
+			want: [][]any{
+				{"a", 1.0, 1.0}, {"a", 2.0, 2.0}, {"a", 3.0, 3.0}, {"a", 4.0, 4.0},
+				{"b", 1.0, 1.0}, {"b", 2.0, 2.0},
+			},
+		},
+		{
+			name: "rank and dense_rank with ties",
+			cmd:  "select ts, rank() over (order by amount desc), dense_rank() over (order by amount desc) from t where user = 'a' order by ts;",
+			want: [][]any{{1.0, 2.0, 2.0}, {2.0, 2.0, 2.0}, {3.0, 4.0, 3.0}, {4.0, 1.0, 1.0}},
+		},
+		{
+			name: "lag and lead",
+			cmd:  "select ts, lag(ts) over (partition by user order by ts), lead(ts, 2, 0) over (partition by user order by ts) from t where user = 'a' order by ts;",
+			want: [][]any{{1.0, nil, 3.0}, {2.0, 1.0, 4.0}, {3.0, 2.0, 0.0}, {4.0, 3.0, 0.0}},
+		},
+		{
+			name: "first_value",
+			cmd:  "select ts, first_value(amount) over (partition by user order by ts desc) from t where user = 'b' order by ts;",
+			want: [][]any{{1.0, 5.0}, {2.0, 5.0}},
+		},
+		{
+			name: "running sum and avg",
+			cmd:  "select ts, sum(amount) over (partition by user order by ts) as total, avg(amount) over (partition by user order by ts) from t where user = 'a' order by 1;",
+			want: [][]any{{1.0, 20.0, 20.0}, {2.0, 40.0, 20.0}, {3.0, 50.0, 50.0 / 3}, {4.0, 50.0, 50.0 / 3}},
+		},
+		{
+			name: "sum over whole partition",
+			cmd:  "select user, ts, sum(amount) over (partition by user) from t where user = 'b' order by ts desc;",
+			want: [][]any{{"b", 2.0, 10.0}, {"b", 1.0, 10.0}},
+		},
+		{
+			name: "window over groups",
+			cmd:  "select user, count(*) as n, rank() over (order by count(*) desc) from t group by user;",
+			want: [][]any{{"a", 4.0, 1.0}, {"b", 2.0, 2.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSelect(t, e, tt.cmd)
+			if err != nil {
+				t.Fatalf("selectQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.rows, tt.want) {
+				t.Errorf("selectQuery() rows = %v, want %v", got.rows, tt.want)
+			}
+		})
+	}
+}
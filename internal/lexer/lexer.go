@@ -17,11 +17,31 @@ var (
 		/* parentheses */ '(', ')', '[', ']', '{', '}',
 		/* arithmetic */ '+', '-', '/', '%', '*',
 		/* punctuation */ ',', ':', ';', '\'', '"', '.',
-		/* logical */ '|', '&', '?', '!',
+		/* logical */ '|', '&', '?', '!', '~',
 		/* comparison */ '<', '>', '=',
 	}
 )
 
+var operators = map[string]token.TokenType{
+	"(":  token.LeftParenthesis,
+	")":  token.RightParenthesis,
+	".":  token.Period,
+	"+":  token.Plus,
+	"-":  token.Minus,
+	"*":  token.Star,
+	"/":  token.Slash,
+	"%":  token.Percent,
+	"||": token.Concat,
+	"~":  token.Tilde,
+	"=":  token.Equal,
+	"<":  token.LessThan,
+	">":  token.GreaterThan,
+	"<=": token.LessThanOrEqual,
+	">=": token.GreaterThanOrEqual,
+	"<>": token.NotEqual,
+	"!=": token.NotEqual,
+}
+
 type lexer struct {
 	index   int
 	cmd     string
@@ -52,17 +72,33 @@ func (p *lexer) Next() (t *token.Token, err error) {
 		t = token.New(token.Semicolon, word)
 	} else if word == "," {
 		t = token.New(token.Comma, word)
-	} else if word[0] == '"' {
-		index := strings.Index(rest, "\"")
-		if index == -1 {
+	} else if word == "?" || (word[0] == '$' && isNumber(word[1:])) {
+		t = token.New(token.Placeholder, word)
+	} else if word[0] == '"' || word[0] == '\'' {
+		content, left, ok := readQuoted(rest, word[0])
+		if !ok {
 			return nil, ErrUnterminatedString
 		}
 
-		content := word[1:] + rest[:index]
-		p.cmdLeft = rest[index+1:]
+		p.cmdLeft = left
 
 		t = token.New(token.String, content)
 		err = nil
+	} else if type_, ok := operators[word+peekSymbol(rest)]; ok && peekSymbol(rest) != "" {
+		p.cmdLeft = rest[1:]
+		t = token.New(type_, word+rest[:1])
+	} else if type_, ok := operators[word]; ok {
+		t = token.New(type_, word)
+	} else if isNumber(word) {
+		if len(rest) > 1 && rest[0] == '.' && isNumber(string(rest[1])) {
+			fraction, left := NextWord(rest[1:], Separators, Symbols)
+			if isNumber(fraction) {
+				word = word + "." + fraction
+				p.cmdLeft = left
+			}
+		}
+
+		t = token.New(token.Number, word)
 	} else {
 		t = token.New(token.Word, word)
 		err = nil
@@ -149,3 +185,54 @@ func NextWord(input string, whitespaces []rune, special []rune) (string, string)
 
 	return input[start:end], input[end:]
 }
+
+// readQuoted reads the rest of a string opened with quote, where a doubled
+// quote stands for the quote character itself.
+func readQuoted(input string, quote byte) (string, string, bool) {
+	var sb strings.Builder
+
+	for {
+		index := strings.IndexByte(input, quote)
+		if index == -1 {
+			return "", "", false
+		}
+
+		sb.WriteString(input[:index])
+		input = input[index+1:]
+
+		if len(input) == 0 || input[0] != quote {
+			return sb.String(), input, true
+		}
+
+		sb.WriteByte(quote)
+		input = input[1:]
+	}
+}
+
+func peekSymbol(input string) string {
+	if len(input) == 0 {
+		return ""
+	}
+
+	for _, s := range Symbols {
+		if rune(input[0]) == s {
+			return input[:1]
+		}
+	}
+
+	return ""
+}
+
+func isNumber(word string) bool {
+	if word == "" {
+		return false
+	}
+
+	for _, r := range word {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -124,6 +124,61 @@ func Test_Next(t *testing.T) {
 			),
 			wantErr: false,
 		},
+		{
+			name: "single quoted string",
+			args: args{
+				s: "'it''s'",
+			},
+			want: token.New(
+				token.String,
+				"it's",
+			),
+			wantErr: false,
+		},
+		{
+			name: "decimal number",
+			args: args{
+				s: "12.5 * 2",
+			},
+			want: token.New(
+				token.Number,
+				"12.5",
+			),
+			wantErr: false,
+		},
+		{
+			name: "concat",
+			args: args{
+				s: "|| name",
+			},
+			want: token.New(
+				token.Concat,
+				"||",
+			),
+			wantErr: false,
+		},
+		{
+			name: "question mark placeholder",
+			args: args{
+				s: "?)",
+			},
+			want: token.New(
+				token.Placeholder,
+				"?",
+			),
+			wantErr: false,
+		},
+		{
+			name: "numbered placeholder",
+			args: args{
+				s: "$12, 3",
+			},
+			want: token.New(
+				token.Placeholder,
+				"$12",
+			),
+			wantErr: false,
+		},
 		{
 			name: "word2",
 			args: args{
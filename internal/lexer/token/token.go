@@ -8,29 +8,34 @@ const (
 	Word      TokenType = "word"
 	String              = "string"
 	Semicolon           = "semicolon"
-	// Number
+	Number              = "number"
+	// Placeholder is '?' or '$' followed by a position, like '$1'
+	Placeholder = "placeholder"
 	// Boolean
 	// Null
 	Comma = "comma"
 	// Colon
-	// Period
-	// Equal
-	// Plus
-	// Minus
-	// Slash
-	// Percent
-	// LessThan
-	// GreaterThan
-	// LessThanOrEqual
-	// GreaterThanOrEqual
-	// NotEqual
+	Period             = "period"
+	Equal              = "equal"
+	Plus               = "plus"
+	Minus              = "minus"
+	Star               = "star"
+	Slash              = "slash"
+	Percent            = "percent"
+	Concat             = "concat"
+	Tilde              = "tilde"
+	LessThan           = "less_than"
+	GreaterThan        = "greater_than"
+	LessThanOrEqual    = "less_than_or_equal"
+	GreaterThanOrEqual = "greater_than_or_equal"
+	NotEqual           = "not_equal"
 	// And
 	// Or
 	// Not
 	// // Parentheses are smooth and curved (),
 	// // brackets are square [], and braces are curly {}
-	// LeftParenthesis
-	// RightParenthesis
+	LeftParenthesis  = "left_parenthesis"
+	RightParenthesis = "right_parenthesis"
 	// LeftBracket
 	// RightBracket
 	// LeftBrace
@@ -0,0 +1,216 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrDuplicateColumn = errors.New("duplicate column")
+
+// the column holding the index of an element in its array, in child tables
+const positionColumn = "position"
+
+// field is a column of a flattened row.
+type field struct {
+	name  string
+	value any
+}
+
+// flatTable is a table of flattened rows, gathered before it is created. Its
+// first column is a generated key named after the table; child tables also
+// have the key of their parent and the position of their element.
+type flatTable struct {
+	name string
+	// the table a child table was split from, empty otherwise
+	parent  string
+	keys    []string
+	columns []string
+	rows    []map[string]any
+}
+
+func newFlatTable(name, parent string) *flatTable {
+	t := &flatTable{name: name, parent: parent, keys: []string{key(name)}}
+	if parent != "" {
+		t.keys = append(t.keys, key(parent), positionColumn)
+	}
+	return t
+}
+
+// key is the name of the generated key of a table.
+func key(table string) string {
+	return table + "_id"
+}
+
+// add adds a row of the fields, given the values of the keys that follow
+// the generated one.
+func (t *flatTable) add(keys []any, fields []field) error {
+	row := make(map[string]any, len(t.keys)+len(fields))
+	row[t.keys[0]] = len(t.rows) + 1
+	for i, key := range t.keys[1:] {
+		row[key] = keys[i]
+	}
+	for _, f := range fields {
+		if _, ok := row[f.name]; ok {
+			return fmt.Errorf("%w: '%s' of table '%s'", ErrDuplicateColumn, f.name, t.name)
+		}
+		if !slices.Contains(t.columns, f.name) {
+			t.columns = append(t.columns, f.name)
+		}
+		row[f.name] = f.value
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// split moves the columns holding arrays of objects into child tables named
+// after the table and the column, and returns the table and its
+// descendants.
+func (t *flatTable) split() ([]*flatTable, error) {
+	tables := []*flatTable{t}
+	columns := t.columns[:0:0]
+	for _, column := range t.columns {
+		if t.nullObject(column) {
+			continue
+		}
+		if !t.objectArrays(column) {
+			columns = append(columns, column)
+			continue
+		}
+
+		child := newFlatTable(t.name+"_"+column, t.name)
+		for _, row := range t.rows {
+			elements, _ := row[column].([]any)
+			for i, element := range elements {
+				if err := child.add([]any{row[key(t.name)], i}, flattenObject("", element.(map[string]any))); err != nil {
+					return nil, err
+				}
+			}
+			delete(row, column)
+		}
+		descendants, err := child.split()
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, descendants...)
+	}
+	t.columns = columns
+	return tables, nil
+}
+
+// nullObject reports whether a column only holds nulls, and is the prefix
+// of columns flattened from objects it held in other rows.
+func (t *flatTable) nullObject(column string) bool {
+	for _, row := range t.rows {
+		if row[column] != nil {
+			return false
+		}
+	}
+	return slices.ContainsFunc(t.columns, func(c string) bool {
+		return strings.HasPrefix(c, column+"_")
+	})
+}
+
+// objectArrays reports whether a column holds arrays whose elements are all
+// objects, at least one of them.
+func (t *flatTable) objectArrays(column string) bool {
+	elements := 0
+	for _, row := range t.rows {
+		value, ok := row[column]
+		if !ok || value == nil {
+			continue
+		}
+		array, ok := value.([]any)
+		if !ok {
+			return false
+		}
+		for _, element := range array {
+			if _, ok := element.(map[string]any); !ok {
+				return false
+			}
+		}
+		elements += len(array)
+	}
+	return elements > 0
+}
+
+// definitions returns the definitions of the columns of the table. The
+// types of the data columns are those of their values, or TEXT when they
+// differ.
+func (t *flatTable) definitions() []string {
+	definitions := []string{quote(key(t.name)) + " INTEGER PRIMARY KEY"}
+	if t.parent != "" {
+		definitions = append(definitions,
+			fmt.Sprintf("%s INTEGER REFERENCES %s (%s)", quote(key(t.parent)), quote(t.parent), quote(key(t.parent))),
+			quote(positionColumn)+" INTEGER",
+		)
+	}
+	for _, column := range t.columns {
+		definitions = append(definitions, quote(column)+" "+t.columnType(column))
+	}
+	return definitions
+}
+
+func (t *flatTable) columnType(column string) string {
+	sqlType := ""
+	for _, row := range t.rows {
+		var current string
+		switch row[column].(type) {
+		case nil:
+			continue
+		case float64:
+			current = "REAL"
+		case bool:
+			current = "BOOLEAN"
+		default:
+			current = "TEXT"
+		}
+		if sqlType != "" && sqlType != current {
+			return "TEXT"
+		}
+		sqlType = current
+	}
+	if sqlType == "" {
+		return "TEXT"
+	}
+	return sqlType
+}
+
+// arrays returns the columns holding arrays of values other than objects.
+func (t *flatTable) arrays() []string {
+	var arrays []string
+	for _, column := range t.columns {
+		for _, row := range t.rows {
+			if _, ok := row[column].([]any); ok {
+				arrays = append(arrays, column)
+				break
+			}
+		}
+	}
+	return arrays
+}
+
+// flattenObject returns the fields of an object, sorted by name, with the
+// fields of nested objects prefixed by the name of the field holding them.
+func flattenObject(prefix string, object map[string]any) []field {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var fields []field
+	for _, name := range names {
+		fields = append(fields, flattenValue(prefix+name, object[name])...)
+	}
+	return fields
+}
+
+// flattenValue returns the fields of a value named name.
+func flattenValue(name string, value any) []field {
+	if object, ok := value.(map[string]any); ok {
+		return flattenObject(name+"_", object)
+	}
+	return []field{{name: name, value: value}}
+}
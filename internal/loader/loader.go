@@ -0,0 +1,267 @@
+// Package loader copies tables of a jql database into SQL databases, like
+// sqlite, through database/sql.
+//
+// Arrays and objects are stored as canonical json text: compact, with the
+// keys of objects sorted and without escaping html characters. The json
+// functions of sqlite read them as they are:
+//
+//	select json_extract(address, '$.city') from people;
+//	select address ->> 'city' from people;
+//
+// Each array column also gets a view named after the table and the column,
+// which expands the arrays with json_each into a row per element:
+//
+//	select p.name, t.value from people p join people_tags t on t.people_rowid = p.rowid;
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/jql"
+)
+
+// Options change how Load stores the values of a table.
+type Options struct {
+	// Flatten stores the fields of objects in columns prefixed by the name
+	// of the object, like extra_foo, rather than as json. Arrays of objects
+	// are stored in child tables named after the table and the column, like
+	// simple_items. Every table then gets a generated key named after it,
+	// simple_id, and child tables hold the key of their parent and the
+	// position of their element in the array:
+	//
+	//	select s.name, i.position, i.price from simple s join simple_items i using (simple_id);
+	//
+	// Other arrays are stored as json, as they are without Flatten.
+	Flatten bool
+}
+
+// Load copies the table name of src into a new table of dst named the same,
+// and returns the number of rows it copied. The rows are inserted by a
+// prepared statement in a single transaction, which is rolled back when a
+// row fails, so that the table is copied whole or not at all. dst must take
+// '?' placeholders.
+//
+// Numbers are REAL columns, booleans BOOLEAN and everything else TEXT.
+// Arrays and objects are stored as json, timestamps in RFC 3339 and missing
+// values as NULL. The views over array columns need json_each, which sqlite
+// has.
+func Load(ctx context.Context, dst *sql.DB, src *jql.DB, name string, options Options) (n int, err error) {
+	rows, err := src.ReadTable(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("table '%s' has no columns", name)
+	}
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if options.Flatten {
+		n, err = loadFlat(ctx, tx, name, rows)
+	} else {
+		n, err = loadRows(ctx, tx, name, rows)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// loadRows copies rows into a table with their columns.
+func loadRows(ctx context.Context, tx *sql.Tx, name string, rows *jql.Rows) (int, error) {
+	columns := rows.Columns()
+	definitions := make([]string, 0, len(columns))
+	names := make([]string, 0, len(columns))
+	var arrays []string
+	for _, column := range columns {
+		definitions = append(definitions, quote(column.Name)+" "+sqlType(column.Type))
+		names = append(names, column.Name)
+		if column.Type == jql.ArrayType {
+			arrays = append(arrays, column.Name)
+		}
+	}
+
+	n, err := writeTable(ctx, tx, name, definitions, names, func(args []any) (bool, error) {
+		if !rows.Next() {
+			return false, rows.Err()
+		}
+		copy(args, rows.Values())
+		return true, nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, createViews(ctx, tx, name, arrays)
+}
+
+// loadFlat copies rows into a table of their flattened fields and the child
+// tables of their arrays of objects.
+func loadFlat(ctx context.Context, tx *sql.Tx, name string, rows *jql.Rows) (int, error) {
+	columns := rows.Columns()
+	root := newFlatTable(name, "")
+	for rows.Next() {
+		var fields []field
+		for i, value := range rows.Values() {
+			fields = append(fields, flattenValue(columns[i].Name, value)...)
+		}
+		if err := root.add(nil, fields); err != nil {
+			return 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tables, err := root.split()
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range tables {
+		names := append(slices.Clone(t.keys), t.columns...)
+		i := 0
+		_, err := writeTable(ctx, tx, t.name, t.definitions(), names, func(args []any) (bool, error) {
+			if i == len(t.rows) {
+				return false, nil
+			}
+			for j, name := range names {
+				args[j] = t.rows[i][name]
+			}
+			i++
+			return true, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if err := createViews(ctx, tx, t.name, t.arrays()); err != nil {
+			return 0, err
+		}
+	}
+	return len(root.rows), nil
+}
+
+// writeTable creates a table of the column definitions, and inserts the
+// values of the named columns next fills args with, until it returns false.
+// It returns the number of rows it inserted.
+func writeTable(ctx context.Context, tx *sql.Tx, name string, definitions, names []string, next func(args []any) (bool, error)) (n int, err error) {
+	if _, err := tx.ExecContext(ctx, createStatement(name, definitions)); err != nil {
+		return 0, fmt.Errorf("failed to create table '%s': %w", name, err)
+	}
+
+	insert, err := tx.PrepareContext(ctx, insertStatement(name, names))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	args := make([]any, len(names))
+	for {
+		ok, err := next(args)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			return n, nil
+		}
+		for i, value := range args {
+			if args[i], err = argument(value); err != nil {
+				return n, fmt.Errorf("row %d: column '%s': %w", n+1, names[i], err)
+			}
+		}
+		if _, err := insert.ExecContext(ctx, args...); err != nil {
+			return n, fmt.Errorf("failed to insert row %d: %w", n+1, err)
+		}
+		n++
+	}
+}
+
+// createViews creates the views over the arrays of the columns of a table.
+func createViews(ctx context.Context, tx *sql.Tx, table string, columns []string) error {
+	for _, column := range columns {
+		if _, err := tx.ExecContext(ctx, arrayView(table, column)); err != nil {
+			return fmt.Errorf("failed to create view of '%s': %w", column, err)
+		}
+	}
+	return nil
+}
+
+func createStatement(name string, definitions []string) string {
+	return fmt.Sprintf("CREATE TABLE %s (%s)", quote(name), strings.Join(definitions, ", "))
+}
+
+func insertStatement(name string, columns []string) string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, quote(column))
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(name), strings.Join(names, ", "), placeholders)
+}
+
+// arrayView creates a view with a row per element of the arrays of a
+// column: the rowid of the row holding the array, and the key, value and
+// json type json_each gives the element.
+func arrayView(table, column string) string {
+	return fmt.Sprintf(
+		"CREATE VIEW %s AS SELECT t.rowid AS %s, e.key, e.value, e.type FROM %s AS t, json_each(t.%s) AS e",
+		quote(table+"_"+column), quote(table+"_rowid"), quote(table), quote(column),
+	)
+}
+
+// quote quotes an identifier.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqlType(t jql.Type) string {
+	switch t {
+	case jql.NumberType:
+		return "REAL"
+	case jql.BooleanType:
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+// argument converts a value to the argument it is inserted as.
+func argument(value any) (any, error) {
+	switch v := value.(type) {
+	case []any, map[string]any:
+		return canonicalJSON(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return value, nil
+}
+
+// canonicalJSON encodes a value as compact json with sorted object keys.
+// Characters like '<' are kept as they are rather than escaped.
+func canonicalJSON(value any) (string, error) {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,303 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kotsmile/jql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDBs(tb testing.TB, name, data string) (*sql.DB, *jql.DB) {
+	tb.Helper()
+
+	filename := filepath.Join(tb.TempDir(), name+".json")
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	src, err := jql.Open(filename)
+	if err != nil {
+		tb.Fatalf("Open() error = %v", err)
+	}
+	return openSQLite(tb), src
+}
+
+func openSQLite(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func Test_Load(t *testing.T) {
+	dst, src := openTestDBs(t, "people", `[
+		{"id": 1, "name": "o'brien", "admin": true, "tags": ["a"], "address": {"city": "Oslo"}},
+		{"id": 2.5, "name": "\"bob\"; drop table people", "admin": false, "tags": []},
+		{"id": 3, "name": null}
+	]`)
+
+	n, err := Load(context.Background(), dst, src, "people", Options{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Load() = %d, want 3", n)
+	}
+
+	rows, err := dst.Query(`SELECT id, name, admin, tags, address, typeof(admin) FROM people ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id float64
+		var name, admin, tags, address sql.NullString
+		var adminType string
+		if err := rows.Scan(&id, &name, &admin, &tags, &address, &adminType); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, fmt.Sprintf("%v|%v|%v|%v|%v|%s", id, name, admin, tags, address, adminType))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`1|{o'brien true}|{true true}|{["a"] true}|{{"city":"Oslo"} true}|integer`,
+		`2.5|{"bob"; drop table people true}|{false true}|{[] true}|{ false}|integer`,
+		`3|{ false}|{ false}|{ false}|{ false}|null`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_LoadRollsBack(t *testing.T) {
+	dst, src := openTestDBs(t, "t", `[{"a": 1}, {"a": 2}]`)
+	if _, err := dst.Exec(`CREATE TABLE other (a REAL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Load(ctx, dst, src, "t", Options{}); err == nil {
+		t.Fatalf("Load() with a canceled context error = nil")
+	}
+
+	if _, err := Load(context.Background(), dst, src, "t", Options{}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// the table exists now, so a second load fails without leaving rows
+	if _, err := Load(context.Background(), dst, src, "t", Options{}); err == nil {
+		t.Errorf("Load() of an existing table error = nil")
+	}
+
+	var count int
+	if err := dst.QueryRow(`SELECT count(*) FROM t`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("rows = %d, want 2", count)
+	}
+}
+
+func Test_LoadName(t *testing.T) {
+	for _, name := range []string{"my-data", "from", `a "quoted" name`} {
+		t.Run(name, func(t *testing.T) {
+			dst, src := openTestDBs(t, "data", `[{"a": 1}, {"a": 2}]`)
+			filename := filepath.Join(t.TempDir(), "data.json")
+			if err := os.WriteFile(filename, []byte(`[{"a": 1}, {"a": 2}]`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := src.LoadAs(filename, name); err != nil {
+				t.Fatalf("LoadAs() error = %v", err)
+			}
+
+			if n, err := Load(context.Background(), dst, src, name, Options{}); err != nil || n != 2 {
+				t.Fatalf("Load() = %d, %v, want 2", n, err)
+			}
+			var count int
+			if err := dst.QueryRow(`SELECT count(*) FROM ` + quote(name)).Scan(&count); err != nil || count != 2 {
+				t.Errorf("rows = %d, %v, want 2", count, err)
+			}
+		})
+	}
+}
+
+func Test_LoadJSON(t *testing.T) {
+	dst, src := openTestDBs(t, "simple", `[
+		{"id": 1, "tags": ["a", "<b>"], "extra": {"foo": 1, "bar": {"baz": "x & y"}}},
+		{"id": 2, "tags": [], "extra": {"foo": 2}},
+		{"id": 3, "tags": ["c"]}
+	]`)
+	if _, err := Load(context.Background(), dst, src, "simple", Options{}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "canonical json",
+			query: `SELECT extra FROM simple ORDER BY id`,
+			want:  []string{`{"bar":{"baz":"x & y"},"foo":1}`, `{"foo":2}`, `<nil>`},
+		},
+		{
+			name:  "json_extract",
+			query: `SELECT json_extract(extra, '$.foo') FROM simple ORDER BY id`,
+			want:  []string{"1", "2", "<nil>"},
+		},
+		{
+			name:  "nested path",
+			query: `SELECT extra ->> '$.bar.baz' FROM simple WHERE id = 1`,
+			want:  []string{"x & y"},
+		},
+		{
+			name:  "json_each",
+			query: `SELECT s.rowid || ':' || e.value FROM simple s, json_each(s.tags) e ORDER BY s.rowid, e.key`,
+			want:  []string{"1:a", "1:<b>", "3:c"},
+		},
+		{
+			name:  "array view",
+			query: `SELECT simple_rowid || ':' || key || ':' || value || ':' || type FROM simple_tags ORDER BY simple_rowid, key`,
+			want:  []string{"1:0:a:text", "1:1:<b>:text", "3:0:c:text"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := dst.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var value any
+				if err := rows.Scan(&value); err != nil {
+					t.Fatalf("Scan() error = %v", err)
+				}
+				if b, ok := value.([]byte); ok {
+					value = string(b)
+				}
+				got = append(got, fmt.Sprint(value))
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_LoadFlatten(t *testing.T) {
+	dst, src := openTestDBs(t, "simple", `[
+		{"name": "a", "extra": {"foo": 1, "bar": {"baz": true}}, "tags": ["x"],
+		 "items": [{"sku": "p1", "price": 2, "parts": [{"n": 1}]}, {"sku": "p2", "price": 3.5, "size": {"w": 4}}]},
+		{"name": "b", "extra": {"foo": 2, "bar": null}, "tags": [], "items": []},
+		{"name": "c", "extra": null, "tags": ["y", "z"], "items": [{"sku": "p3", "price": null}]}
+	]`)
+	n, err := Load(context.Background(), dst, src, "simple", Options{Flatten: true})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Load() = %d, want 3", n)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "schema",
+			query: `SELECT name || ' ' || type FROM pragma_table_info('simple')`,
+			want: []string{
+				"simple_id INTEGER", "extra_bar_baz BOOLEAN", "extra_foo REAL", "name TEXT", "tags TEXT",
+			},
+		},
+		{
+			name:  "child schema",
+			query: `SELECT name || ' ' || type FROM pragma_table_info('simple_items')`,
+			want: []string{
+				"simple_items_id INTEGER", "simple_id INTEGER", "position INTEGER",
+				"price REAL", "sku TEXT", "size_w REAL",
+			},
+		},
+		{
+			name:  "flattened objects",
+			query: `SELECT name || ':' || ifnull(extra_foo, '-') || ':' || ifnull(extra_bar_baz, '-') FROM simple ORDER BY simple_id`,
+			want:  []string{"a:1.0:1", "b:2.0:-", "c:-:-"},
+		},
+		{
+			name: "join child table",
+			query: `SELECT s.name || ':' || i.position || ':' || i.sku || ':' || ifnull(i.price, '-')
+				FROM simple s JOIN simple_items i USING (simple_id) ORDER BY i.simple_items_id`,
+			want: []string{"a:0:p1:2.0", "a:1:p2:3.5", "c:0:p3:-"},
+		},
+		{
+			name: "grandchild table",
+			query: `SELECT i.sku || ':' || p.n FROM simple_items i
+				JOIN simple_items_parts p USING (simple_items_id)`,
+			want: []string{"p1:1.0"},
+		},
+		{
+			name:  "arrays of values stay json",
+			query: `SELECT simple_rowid || ':' || value FROM simple_tags ORDER BY simple_rowid, key`,
+			want:  []string{"1:x", "3:y", "3:z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := dst.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var value string
+				if err := rows.Scan(&value); err != nil {
+					t.Fatalf("Scan() error = %v", err)
+				}
+				got = append(got, value)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_LoadFlattenDuplicateColumn(t *testing.T) {
+	dst, src := openTestDBs(t, "t", `[{"a_b": 1, "a": {"b": 2}}]`)
+	if _, err := Load(context.Background(), dst, src, "t", Options{Flatten: true}); !errors.Is(err, ErrDuplicateColumn) {
+		t.Errorf("Load() error = %v, want %v", err, ErrDuplicateColumn)
+	}
+	if err := dst.QueryRow(`SELECT 1 FROM t`).Scan(new(int)); err == nil {
+		t.Errorf("table t exists after a failed load")
+	}
+}
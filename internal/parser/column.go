@@ -0,0 +1,15 @@
+package parser
+
+type ColumnNode string
+
+func (c ColumnNode) String() string {
+	return string(c)
+}
+
+func (c ColumnNode) Value() string {
+	return string(c)
+}
+
+func (c ColumnNode) Type() string {
+	return "column"
+}
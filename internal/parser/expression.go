@@ -0,0 +1,631 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/lexer/token"
+	"github.com/kotsmile/jql/util"
+)
+
+var binaryOperators = map[token.TokenType]OperatorType{
+	token.Plus:               AddOperator,
+	token.Minus:              SubtractOperator,
+	token.Star:               MultiplyOperator,
+	token.Slash:              DivideOperator,
+	token.Percent:            ModuloOperator,
+	token.Concat:             ConcatOperator,
+	token.Equal:              EqualOperator,
+	token.NotEqual:           NotEqualOperator,
+	token.LessThan:           LessOperator,
+	token.LessThanOrEqual:    LessOrEqualOperator,
+	token.GreaterThan:        GreaterOperator,
+	token.GreaterThanOrEqual: GreaterOrEqualOperator,
+	token.Tilde:              MatchOperator,
+}
+
+var binaryKeywords = map[KeywordType]OperatorType{
+	AndKeyword: AndOperator,
+	OrKeyword:  OrOperator,
+}
+
+func binaryOperator(t token.Token) (OperatorType, bool) {
+	if operator, ok := binaryOperators[t.Type()]; ok {
+		return operator, true
+	}
+	if t.Is(token.Word) {
+		operator, ok := binaryKeywords[KeywordType(strings.ToLower(t.Value()))]
+		return operator, ok
+	}
+	return "", false
+}
+
+func (p *parser) parseExpression(tokens *[]token.Token) (*AstNode, error) {
+	return p.parseBinary(tokens, 1)
+}
+
+// parseBinary uses precedence climbing: operators binding weaker than
+// minPrecedence are left for the caller.
+func (p *parser) parseBinary(tokens *[]token.Token, minPrecedence int) (*AstNode, error) {
+	left, err := p.parseUnary(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := util.Peek(*tokens)
+		if !ok {
+			return left, nil
+		}
+
+		operator, ok := binaryOperator(t)
+		if !ok {
+			if minPrecedence > comparisonPrecedence {
+				return left, nil
+			}
+
+			predicate, err := p.parsePredicate(left, tokens)
+			if err != nil {
+				return nil, err
+			}
+			if predicate == nil {
+				return left, nil
+			}
+
+			left = predicate
+			continue
+		}
+		if operator.Precedence() < minPrecedence {
+			return left, nil
+		}
+		util.Next(tokens)
+
+		right, err := p.parseBinary(tokens, operator.Precedence()+1)
+		if err != nil {
+			return nil, err
+		}
+
+		node := NewAstNode(NewOperator(operator))
+		node.AppendChild(left)
+		node.AppendChild(right)
+		left = node
+	}
+}
+
+// parsePredicate parses the keyword forms applied to an already parsed left
+// operand: like, ilike, in, between and is null, each optionally negated.
+// It returns nil when the next token does not start a predicate.
+func (p *parser) parsePredicate(left *AstNode, tokens *[]token.Token) (*AstNode, error) {
+	rest := *tokens
+
+	t, _ := util.Next(&rest)
+	negated := isKeyword(t, NotKeyword)
+	if negated {
+		t, _ = util.Next(&rest)
+	}
+
+	var node *AstNode
+	switch {
+	case isKeyword(t, LikeKeyword, ILikeKeyword):
+		operator := LikeOperator
+		if isKeyword(t, ILikeKeyword) {
+			operator = ILikeOperator
+		}
+
+		pattern, err := p.parseBinary(&rest, comparisonPrecedence+1)
+		if err != nil {
+			return nil, err
+		}
+
+		node = NewAstNode(NewOperator(operator))
+		node.AppendChild(left)
+		node.AppendChild(pattern)
+	case isKeyword(t, InKeyword):
+		opening, ok := util.Next(&rest)
+		if !ok || !opening.Is(token.LeftParenthesis) {
+			return nil, ErrMissingLeftParenthesis
+		}
+
+		node = NewAstNode(NewOperator(InOperator))
+		node.AppendChild(left)
+
+		if next, ok := util.Peek(rest); ok && isKeyword(next, SelectKeyword) {
+			subquery, err := p.parseSubquery(&rest)
+			if err != nil {
+				return nil, err
+			}
+			node.AppendChild(subquery)
+			break
+		}
+
+		list, err := p.parseExpressionList(&rest)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			node.AppendChild(item)
+		}
+	case isKeyword(t, BetweenKeyword):
+		low, err := p.parseBinary(&rest, comparisonPrecedence+1)
+		if err != nil {
+			return nil, err
+		}
+
+		and, ok := util.Next(&rest)
+		if !ok || !isKeyword(and, AndKeyword) {
+			return nil, ErrMissingAndKeyword
+		}
+
+		high, err := p.parseBinary(&rest, comparisonPrecedence+1)
+		if err != nil {
+			return nil, err
+		}
+
+		node = NewAstNode(NewOperator(BetweenOperator))
+		node.AppendChild(left)
+		node.AppendChild(low)
+		node.AppendChild(high)
+	case isKeyword(t, IsKeyword) && !negated:
+		operator := IsNullOperator
+
+		t, ok := util.Next(&rest)
+		if ok && isKeyword(t, NotKeyword) {
+			operator = IsNotNullOperator
+			t, ok = util.Next(&rest)
+		}
+		if !ok || !isKeyword(t, NullKeyword) {
+			return nil, ErrMissingNullKeyword
+		}
+
+		node = NewAstNode(NewOperator(operator))
+		node.AppendChild(left)
+	default:
+		return nil, nil
+	}
+
+	*tokens = rest
+
+	if negated {
+		not := NewAstNode(NewOperator(NotOperator))
+		not.AppendChild(node)
+		return not, nil
+	}
+
+	return node, nil
+}
+
+// parseExpressionList parses comma separated expressions up to and
+// including the closing parenthesis.
+func (p *parser) parseExpressionList(tokens *[]token.Token) ([]*AstNode, error) {
+	var list []*AstNode
+
+	if t, ok := util.Peek(*tokens); ok && t.Is(token.RightParenthesis) {
+		util.Next(tokens)
+		return list, nil
+	}
+
+	for {
+		item, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+
+		t, ok := util.Next(tokens)
+		if !ok {
+			return nil, ErrMissingRightParenthesis
+		}
+		if t.Is(token.RightParenthesis) {
+			return list, nil
+		}
+		if !t.Is(token.Comma) {
+			return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+		}
+	}
+}
+
+func (p *parser) parseUnary(tokens *[]token.Token) (*AstNode, error) {
+	t, ok := util.Peek(*tokens)
+	if ok && t.Is(token.Minus) {
+		util.Next(tokens)
+
+		operand, err := p.parseUnary(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		node := NewAstNode(NewOperator(NegateOperator))
+		node.AppendChild(operand)
+		return node, nil
+	}
+
+	if ok && isKeyword(t, NotKeyword) {
+		util.Next(tokens)
+
+		operand, err := p.parseBinary(tokens, NotOperator.Precedence())
+		if err != nil {
+			return nil, err
+		}
+
+		node := NewAstNode(NewOperator(NotOperator))
+		node.AppendChild(operand)
+		return node, nil
+	}
+
+	return p.parsePrimary(tokens)
+}
+
+func (p *parser) parsePrimary(tokens *[]token.Token) (*AstNode, error) {
+	t, ok := util.Next(tokens)
+	if !ok {
+		return nil, ErrMissingExpression
+	}
+
+	switch {
+	case t.Is(token.Number):
+		return NewAstNode(NewLiteral(NumberLiteral, t.Value())), nil
+	case t.Is(token.String):
+		return NewAstNode(NewLiteral(StringLiteral, t.Value())), nil
+	case t.Is(token.Placeholder):
+		return p.parsePlaceholder(t)
+	case t.Is(token.LeftParenthesis):
+		if next, ok := util.Peek(*tokens); ok && isKeyword(next, SelectKeyword) {
+			return p.parseSubquery(tokens)
+		}
+
+		node, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		closing, ok := util.Next(tokens)
+		if !ok || !closing.Is(token.RightParenthesis) {
+			return nil, ErrMissingRightParenthesis
+		}
+		return node, nil
+	case t.Is(token.Word):
+		switch strings.ToLower(t.Value()) {
+		case "true", "false":
+			return NewAstNode(NewLiteral(BooleanLiteral, strings.ToLower(t.Value()))), nil
+		case "null":
+			return NewAstNode(NewLiteral(NullLiteral, "null")), nil
+		}
+		if isKeyword(t, CaseKeyword) {
+			return p.parseCase(tokens)
+		}
+		if isKeyword(t, ExistsKeyword) {
+			if next, ok := util.Next(tokens); !ok || !next.Is(token.LeftParenthesis) {
+				return nil, ErrMissingLeftParenthesis
+			}
+
+			subquery, err := p.parseSubquery(tokens)
+			if err != nil {
+				return nil, err
+			}
+
+			node := NewAstNode(NewOperator(ExistsOperator))
+			node.AppendChild(subquery)
+			return node, nil
+		}
+		if isKeyword(t) {
+			return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+		}
+
+		if rest := *tokens; len(rest) > 1 && rest[0].Is(token.Period) && rest[1].Is(token.Word) {
+			util.Next(tokens)
+			util.Next(tokens)
+			return NewAstNode(ColumnNode(t.Value() + "." + rest[1].Value())), nil
+		}
+
+		if next, ok := util.Peek(*tokens); ok && next.Is(token.LeftParenthesis) {
+			util.Next(tokens)
+
+			name := FunctionNode(strings.ToLower(t.Value()))
+			if name == CastFunction || name == TryCastFunction {
+				return p.parseCast(tokens, name)
+			}
+
+			node := NewAstNode(FunctionNode(name))
+
+			if next, ok := util.Peek(*tokens); ok && isKeyword(next, DistinctKeyword) {
+				util.Next(tokens)
+				node.AppendChild(NewAstNode(NewKeyword(DistinctKeyword)))
+			}
+
+			if rest := *tokens; len(rest) > 1 && rest[0].Is(token.Star) && rest[1].Is(token.RightParenthesis) {
+				util.Next(tokens)
+				util.Next(tokens)
+				node.AppendChild(NewAstNode(ColumnNode("*")))
+			} else {
+				arguments, err := p.parseExpressionList(tokens)
+				if err != nil {
+					return nil, err
+				}
+				for _, argument := range arguments {
+					node.AppendChild(argument)
+				}
+			}
+
+			if next, ok := util.Peek(*tokens); ok && isKeyword(next, OverKeyword) {
+				util.Next(tokens)
+
+				window, err := p.parseWindow(tokens)
+				if err != nil {
+					return nil, err
+				}
+				node.AppendChild(window)
+			}
+
+			return node, nil
+		}
+
+		return NewAstNode(ColumnNode(t.Value())), nil
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+}
+
+// parseCase parses a 'case' expression after the 'case' keyword. The node
+// holds the optional operand of a simple case, a 'when' node with the
+// condition and the result of every branch, and an optional 'else' node.
+func (p *parser) parseCase(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(CaseKeyword))
+
+	if t, ok := util.Peek(*tokens); ok && !isKeyword(t, WhenKeyword) {
+		operand, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(operand)
+	}
+
+	for {
+		t, ok := util.Peek(*tokens)
+		if !ok || !isKeyword(t, WhenKeyword) {
+			break
+		}
+		util.Next(tokens)
+
+		condition, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := util.Next(tokens); !ok || !isKeyword(t, ThenKeyword) {
+			return nil, ErrMissingThenKeyword
+		}
+		result, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		whenNode := NewAstNode(NewKeyword(WhenKeyword))
+		whenNode.AppendChild(condition)
+		whenNode.AppendChild(result)
+		root.AppendChild(whenNode)
+	}
+
+	if last, ok := util.At(root.children, len(root.children)-1); !ok || !last.isKeyword(WhenKeyword) {
+		return nil, ErrMissingWhenKeyword
+	}
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, ElseKeyword) {
+		util.Next(tokens)
+
+		result, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		elseNode := NewAstNode(NewKeyword(ElseKeyword))
+		elseNode.AppendChild(result)
+		root.AppendChild(elseNode)
+	}
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, EndKeyword) {
+		return nil, ErrMissingEndKeyword
+	}
+
+	return root, nil
+}
+
+// parseCast parses the arguments of 'cast(x as type)' after the opening
+// parenthesis. The type becomes a string node after the expression.
+func (p *parser) parseCast(tokens *[]token.Token, name FunctionNode) (*AstNode, error) {
+	operand, err := p.parseExpression(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, AsKeyword) {
+		return nil, fmt.Errorf("'%s' expression: %w", name, ErrMissingAsKeyword)
+	}
+
+	t, ok := util.Next(tokens)
+	if !ok || !t.Is(token.Word) {
+		return nil, ErrMissingCastType
+	}
+
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.RightParenthesis) {
+		return nil, ErrMissingRightParenthesis
+	}
+
+	node := NewAstNode(name)
+	node.AppendChild(operand)
+	node.AppendChild(NewAstNode(StringNode(strings.ToLower(t.Value()))))
+	return node, nil
+}
+
+// Text renders an expression tree back into query text.
+func (n *AstNode) Text() string {
+	switch value := n.value.(type) {
+	case *LiteralNode:
+		return value.String()
+	case FunctionNode:
+		if value == CastFunction || value == TryCastFunction {
+			return fmt.Sprintf("%s(%s as %s)", value, n.children[0].Text(), n.children[1].Text())
+		}
+
+		arguments := n.children
+		over := ""
+		if last, ok := util.At(arguments, len(arguments)-1); ok && last.isKeyword(OverKeyword) {
+			arguments = arguments[:len(arguments)-1]
+			over = " " + last.Text()
+		}
+
+		if first, ok := util.At(arguments, 0); ok && first.isKeyword(DistinctKeyword) {
+			return value.Value() + "(distinct " + textList(arguments[1:]) + ")" + over
+		}
+		return value.Value() + "(" + textList(arguments) + ")" + over
+	case *KeywordNode:
+		switch value.type_ {
+		case OverKeyword:
+			return "over (" + textJoin(n.children, " ") + ")"
+		case PartitionKeyword, OrderKeyword, GroupKeyword:
+			return value.Value() + " by " + textList(n.children)
+		case DescKeyword:
+			return textList(n.children) + " desc"
+		case CaseKeyword:
+			return "case " + textJoin(n.children, " ") + " end"
+		case WhenKeyword:
+			return "when " + n.children[0].Text() + " then " + n.children[1].Text()
+		case ElseKeyword:
+			return "else " + n.children[0].Text()
+		case SelectKeyword, UnionKeyword, IntersectKeyword, ExceptKeyword:
+			return "(" + queryText(n) + ")"
+		case WithKeyword:
+			return "with " + textList(n.children[:len(n.children)-1]) + " " + n.children[len(n.children)-1].Text()
+		case AsKeyword:
+			if len(n.children) == 1 {
+				return "as " + n.children[0].Text()
+			}
+			return n.children[0].Text() + " as " + n.children[1].Text()
+		case FromKeyword, WhereKeyword:
+			return value.Value() + " " + textJoin(n.children, " ")
+		case JoinKeyword:
+			if first, ok := util.At(n.children, 0); ok && first.isKeyword(LeftKeyword) {
+				return "left join " + textJoin(n.children[1:], " ")
+			}
+			return "join " + textJoin(n.children, " ")
+		case OnKeyword:
+			return "on " + n.children[0].Text()
+		case LimitKeyword, OffsetKeyword:
+			return value.Value() + " " + textJoin(n.children, " ")
+		}
+	case *OperatorNode:
+		operator := value.OperatorType()
+		operand := func(i int, isRight bool) string {
+			child, ok := util.At(n.children, i)
+			if !ok {
+				return ""
+			}
+			return child.textWithin(operator, isRight)
+		}
+
+		switch operator {
+		case NegateOperator:
+			return "-" + operand(0, true)
+		case NotOperator:
+			return "not " + operand(0, true)
+		case IsNullOperator, IsNotNullOperator:
+			return operand(0, false) + " " + operator.String()
+		case ExistsOperator:
+			return "exists " + operand(0, true)
+		case InOperator:
+			if list := n.children[1:]; len(list) == 1 && (list[0].isKeyword(SelectKeyword) || list[0].isSetOperation()) {
+				return operand(0, false) + " in " + list[0].Text()
+			}
+			return operand(0, false) + " in (" + textList(n.children[1:]) + ")"
+		case BetweenOperator:
+			return fmt.Sprintf("%s between %s and %s", operand(0, false), operand(1, true), operand(2, true))
+		}
+
+		return fmt.Sprintf("%s %s %s", operand(0, false), operator, operand(1, true))
+	}
+
+	return n.value.Value()
+}
+
+func (n *AstNode) textWithin(parent OperatorType, isRight bool) string {
+	operator, ok := n.value.(*OperatorNode)
+	if !ok {
+		return n.Text()
+	}
+
+	if operator.OperatorType().Precedence() < parent.Precedence() ||
+		(isRight && operator.OperatorType().Precedence() == parent.Precedence()) {
+		return "(" + n.Text() + ")"
+	}
+
+	return n.Text()
+}
+
+// queryText renders a select statement or a set operation without the
+// surrounding parentheses.
+func queryText(n *AstNode) string {
+	if !n.isSetOperation() {
+		return selectText(n)
+	}
+
+	var sb strings.Builder
+	operator := n.value.Value()
+	for _, child := range n.children {
+		switch {
+		case child.isKeyword(AllKeyword):
+			operator += " all"
+		case child.isKeyword(OrderKeyword), child.isKeyword(LimitKeyword):
+			sb.WriteString(" ")
+			sb.WriteString(child.Text())
+		case sb.Len() == 0:
+			sb.WriteString(queryText(child))
+		default:
+			sb.WriteString(" " + operator + " ")
+			sb.WriteString(queryText(child))
+		}
+	}
+
+	return sb.String()
+}
+
+func selectText(n *AstNode) string {
+	var sb strings.Builder
+	sb.WriteString("select ")
+
+	var items []*AstNode
+	var clauses []*AstNode
+	for _, child := range n.children {
+		switch {
+		case child.isKeyword(DistinctKeyword):
+			sb.WriteString("distinct ")
+		case child.isKeyword(FromKeyword), child.isKeyword(WhereKeyword),
+			child.isKeyword(GroupKeyword), child.isKeyword(OrderKeyword), child.isKeyword(LimitKeyword):
+			clauses = append(clauses, child)
+		default:
+			items = append(items, child)
+		}
+	}
+
+	sb.WriteString(textList(items))
+	if len(clauses) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(textJoin(clauses, " "))
+	}
+
+	return sb.String()
+}
+
+func (n *AstNode) isKeyword(type_ KeywordType) bool {
+	keyword, ok := n.value.(*KeywordNode)
+	return ok && keyword.type_ == type_
+}
+
+func textList(nodes []*AstNode) string {
+	return textJoin(nodes, ", ")
+}
+
+func textJoin(nodes []*AstNode, separator string) string {
+	texts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		texts = append(texts, node.Text())
+	}
+	return strings.Join(texts, separator)
+}
@@ -0,0 +1,21 @@
+package parser
+
+type FunctionNode string
+
+// cast functions take an expression and a type name instead of arguments
+const (
+	CastFunction    FunctionNode = "cast"
+	TryCastFunction FunctionNode = "try_cast"
+)
+
+func (f FunctionNode) String() string {
+	return string(f)
+}
+
+func (f FunctionNode) Value() string {
+	return string(f)
+}
+
+func (f FunctionNode) Type() string {
+	return "function"
+}
@@ -1,15 +1,166 @@
 package parser
 
+import (
+	"strings"
+
+	"github.com/kotsmile/jql/internal/lexer/token"
+)
+
 type KeywordType string
 
 const (
-	LoadKeyword   KeywordType = "load"
-	TablesKeyword KeywordType = "tables"
-	AsKeyword     KeywordType = "as"
-	SelectKeyword KeywordType = "select"
-	FromKeyword   KeywordType = "from"
+	LoadKeyword      KeywordType = "load"
+	TablesKeyword    KeywordType = "tables"
+	AsKeyword        KeywordType = "as"
+	SelectKeyword    KeywordType = "select"
+	FromKeyword      KeywordType = "from"
+	WhereKeyword     KeywordType = "where"
+	AndKeyword       KeywordType = "and"
+	OrKeyword        KeywordType = "or"
+	NotKeyword       KeywordType = "not"
+	LikeKeyword      KeywordType = "like"
+	ILikeKeyword     KeywordType = "ilike"
+	InKeyword        KeywordType = "in"
+	BetweenKeyword   KeywordType = "between"
+	IsKeyword        KeywordType = "is"
+	NullKeyword      KeywordType = "null"
+	DistinctKeyword  KeywordType = "distinct"
+	GroupKeyword     KeywordType = "group"
+	ByKeyword        KeywordType = "by"
+	OrderKeyword     KeywordType = "order"
+	AscKeyword       KeywordType = "asc"
+	DescKeyword      KeywordType = "desc"
+	OverKeyword      KeywordType = "over"
+	PartitionKeyword KeywordType = "partition"
+	WithKeyword      KeywordType = "with"
+	ExistsKeyword    KeywordType = "exists"
+	UnionKeyword     KeywordType = "union"
+	IntersectKeyword KeywordType = "intersect"
+	ExceptKeyword    KeywordType = "except"
+	AllKeyword       KeywordType = "all"
+	CaseKeyword      KeywordType = "case"
+	WhenKeyword      KeywordType = "when"
+	ThenKeyword      KeywordType = "then"
+	ElseKeyword      KeywordType = "else"
+	EndKeyword       KeywordType = "end"
+	InsertKeyword    KeywordType = "insert"
+	IntoKeyword      KeywordType = "into"
+	ValuesKeyword    KeywordType = "values"
+	UpdateKeyword    KeywordType = "update"
+	SetKeyword       KeywordType = "set"
+	DeleteKeyword    KeywordType = "delete"
+	SaveKeyword      KeywordType = "save"
+	BackupKeyword    KeywordType = "backup"
+	CreateKeyword    KeywordType = "create"
+	TableKeyword     KeywordType = "table"
+	ViewKeyword      KeywordType = "view"
+	DropKeyword      KeywordType = "drop"
+	AlterKeyword     KeywordType = "alter"
+	RenameKeyword    KeywordType = "rename"
+	ToKeyword        KeywordType = "to"
+	ReloadKeyword    KeywordType = "reload"
+	BeginKeyword     KeywordType = "begin"
+	CommitKeyword    KeywordType = "commit"
+	RollbackKeyword  KeywordType = "rollback"
+	JoinKeyword      KeywordType = "join"
+	InnerKeyword     KeywordType = "inner"
+	LeftKeyword      KeywordType = "left"
+	OuterKeyword     KeywordType = "outer"
+	OnKeyword        KeywordType = "on"
+	IndexKeyword     KeywordType = "index"
+	DescribeKeyword  KeywordType = "describe"
+	ExplainKeyword   KeywordType = "explain"
+	LimitKeyword     KeywordType = "limit"
+	OffsetKeyword    KeywordType = "offset"
 )
 
+var keywords = map[KeywordType]struct{}{
+	LoadKeyword:      {},
+	TablesKeyword:    {},
+	AsKeyword:        {},
+	SelectKeyword:    {},
+	FromKeyword:      {},
+	WhereKeyword:     {},
+	AndKeyword:       {},
+	OrKeyword:        {},
+	NotKeyword:       {},
+	LikeKeyword:      {},
+	ILikeKeyword:     {},
+	InKeyword:        {},
+	BetweenKeyword:   {},
+	IsKeyword:        {},
+	NullKeyword:      {},
+	DistinctKeyword:  {},
+	GroupKeyword:     {},
+	ByKeyword:        {},
+	OrderKeyword:     {},
+	AscKeyword:       {},
+	DescKeyword:      {},
+	OverKeyword:      {},
+	PartitionKeyword: {},
+	WithKeyword:      {},
+	ExistsKeyword:    {},
+	UnionKeyword:     {},
+	IntersectKeyword: {},
+	ExceptKeyword:    {},
+	AllKeyword:       {},
+	CaseKeyword:      {},
+	WhenKeyword:      {},
+	ThenKeyword:      {},
+	ElseKeyword:      {},
+	EndKeyword:       {},
+	InsertKeyword:    {},
+	IntoKeyword:      {},
+	ValuesKeyword:    {},
+	UpdateKeyword:    {},
+	SetKeyword:       {},
+	DeleteKeyword:    {},
+	SaveKeyword:      {},
+	BackupKeyword:    {},
+	CreateKeyword:    {},
+	TableKeyword:     {},
+	ViewKeyword:      {},
+	DropKeyword:      {},
+	AlterKeyword:     {},
+	RenameKeyword:    {},
+	ToKeyword:        {},
+	ReloadKeyword:    {},
+	BeginKeyword:     {},
+	CommitKeyword:    {},
+	RollbackKeyword:  {},
+	JoinKeyword:      {},
+	InnerKeyword:     {},
+	LeftKeyword:      {},
+	OuterKeyword:     {},
+	OnKeyword:        {},
+	IndexKeyword:     {},
+	DescribeKeyword:  {},
+	ExplainKeyword:   {},
+	LimitKeyword:     {},
+	OffsetKeyword:    {},
+}
+
+// isKeyword reports whether t is a reserved word, optionally one of types.
+func isKeyword(t token.Token, types ...KeywordType) bool {
+	if !t.Is(token.Word) {
+		return false
+	}
+
+	value := KeywordType(strings.ToLower(t.Value()))
+	if len(types) == 0 {
+		_, ok := keywords[value]
+		return ok
+	}
+
+	for _, type_ := range types {
+		if value == type_ {
+			return true
+		}
+	}
+
+	return false
+}
+
 func NewKeyword(type_ KeywordType) *KeywordNode {
 	return &KeywordNode{
 		type_: type_,
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/lexer/token"
+)
+
+type LiteralType string
+
+const (
+	NumberLiteral  LiteralType = "number"
+	StringLiteral  LiteralType = "string"
+	BooleanLiteral LiteralType = "boolean"
+	NullLiteral    LiteralType = "null"
+)
+
+func NewLiteral(type_ LiteralType, value string) *LiteralNode {
+	return &LiteralNode{
+		type_: type_,
+		value: value,
+	}
+}
+
+type LiteralNode struct {
+	type_ LiteralType
+	value string
+}
+
+func (l *LiteralNode) String() string {
+	if l.type_ == StringLiteral {
+		return "'" + strings.ReplaceAll(l.value, "'", "''") + "'"
+	}
+	return l.value
+}
+
+func (l *LiteralNode) Value() string {
+	return l.value
+}
+
+func (l *LiteralNode) Type() string {
+	return "literal"
+}
+
+func (l *LiteralNode) LiteralType() LiteralType {
+	return l.type_
+}
+
+// PlaceholderNode stands for the argument at a position, counted from 1,
+// until the statement is bound to its arguments.
+type PlaceholderNode int
+
+func (p PlaceholderNode) Value() string {
+	return "$" + strconv.Itoa(int(p))
+}
+
+func (p PlaceholderNode) Type() string {
+	return "placeholder"
+}
+
+// parsePlaceholder numbers '?' placeholders in the order they appear, which
+// a statement cannot mix with numbered ones.
+func (p *parser) parsePlaceholder(t token.Token) (*AstNode, error) {
+	if t.Value() == "?" {
+		if p.numbered {
+			return nil, ErrMixedPlaceholders
+		}
+		p.placeholders++
+		return NewAstNode(PlaceholderNode(p.placeholders)), nil
+	}
+
+	if p.placeholders > 0 {
+		return nil, ErrMixedPlaceholders
+	}
+	position, err := strconv.Atoi(t.Value()[1:])
+	if err != nil || position < 1 {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidPlaceholder, t.Value())
+	}
+	p.numbered = true
+	return NewAstNode(PlaceholderNode(position)), nil
+}
+
+// Placeholders returns the number of arguments a statement takes, which is
+// the highest position of its placeholders.
+func Placeholders(query *AstNode) int {
+	n := 0
+	if placeholder, ok := query.value.(PlaceholderNode); ok {
+		n = int(placeholder)
+	}
+	for _, child := range query.children {
+		n = max(n, Placeholders(child))
+	}
+	return n
+}
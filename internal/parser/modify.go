@@ -0,0 +1,344 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/lexer/token"
+	"github.com/kotsmile/jql/util"
+)
+
+// parseTableName reads the name of the table a command changes.
+func parseTableName(tokens *[]token.Token, missing error) (*AstNode, error) {
+	t, ok := util.Next(tokens)
+	if !ok || !(t.Is(token.Word) || t.Is(token.String)) || isKeyword(t) {
+		return nil, missing
+	}
+	return NewAstNode(StringNode(t.Value())), nil
+}
+
+// parseInsert parses an 'insert' command after the 'insert' keyword. The
+// node holds the table name, the listed columns and either a 'values' node
+// per row or a select statement.
+func (p *parser) parseInsert(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(InsertKeyword))
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, IntoKeyword) {
+		return nil, ErrMissingIntoKeyword
+	}
+
+	table, err := parseTableName(tokens, ErrMissingTableNameInsertCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(table)
+
+	if t, ok := util.Peek(*tokens); ok && t.Is(token.LeftParenthesis) {
+		util.Next(tokens)
+		for {
+			column, ok := util.Next(tokens)
+			if !ok || !(column.Is(token.Word) || column.Is(token.String)) || isKeyword(column) {
+				return nil, ErrMissingColumnNameInsertCommand
+			}
+			root.AppendChild(NewAstNode(ColumnNode(column.Value())))
+
+			t, ok := util.Next(tokens)
+			if ok && t.Is(token.Comma) {
+				continue
+			}
+			if !ok || !t.Is(token.RightParenthesis) {
+				return nil, ErrMissingRightParenthesis
+			}
+			break
+		}
+	}
+
+	t, ok := util.Next(tokens)
+	switch {
+	case ok && isKeyword(t, SelectKeyword):
+		query, err := p.parseQuery(tokens)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(query)
+	case ok && isKeyword(t, ValuesKeyword):
+		for {
+			if t, ok := util.Next(tokens); !ok || !t.Is(token.LeftParenthesis) {
+				return nil, ErrMissingLeftParenthesis
+			}
+
+			values, err := p.parseExpressionList(tokens)
+			if err != nil {
+				return nil, err
+			}
+
+			valuesNode := NewAstNode(NewKeyword(ValuesKeyword))
+			for _, value := range values {
+				valuesNode.AppendChild(value)
+			}
+			root.AppendChild(valuesNode)
+
+			if t, ok := util.Peek(*tokens); !ok || !t.Is(token.Comma) {
+				break
+			}
+			util.Next(tokens)
+		}
+	default:
+		return nil, ErrMissingValuesInsertCommand
+	}
+
+	return root, nil
+}
+
+// parseUpdate parses an 'update' command after the 'update' keyword. The
+// 'set' node holds an '=' node for every assignment.
+func (p *parser) parseUpdate(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(UpdateKeyword))
+
+	table, err := parseTableName(tokens, ErrMissingTableNameUpdateCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(table)
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, SetKeyword) {
+		return nil, ErrMissingSetKeyword
+	}
+
+	setNode := NewAstNode(NewKeyword(SetKeyword))
+	for {
+		column, ok := util.Next(tokens)
+		if !ok || !(column.Is(token.Word) || column.Is(token.String)) || isKeyword(column) {
+			return nil, ErrMissingAssignment
+		}
+		if t, ok := util.Next(tokens); !ok || !t.Is(token.Equal) {
+			return nil, fmt.Errorf("%w: expected '=' after '%s'", ErrMissingAssignment, column.Value())
+		}
+
+		value, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		assignment := NewAstNode(NewOperator(EqualOperator))
+		assignment.AppendChild(NewAstNode(ColumnNode(column.Value())))
+		assignment.AppendChild(value)
+		setNode.AppendChild(assignment)
+
+		if t, ok := util.Peek(*tokens); !ok || !t.Is(token.Comma) {
+			break
+		}
+		util.Next(tokens)
+	}
+	root.AppendChild(setNode)
+
+	if err := p.parseWhere(tokens, root); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// parseDelete parses a 'delete' command after the 'delete' keyword.
+func (p *parser) parseDelete(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(DeleteKeyword))
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, FromKeyword) {
+		return nil, ErrMissingFromKeywordDeleteCommand
+	}
+
+	table, err := parseTableName(tokens, ErrMissingTableNameDeleteCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(table)
+
+	if err := p.parseWhere(tokens, root); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// parseCreate parses a 'create table' or 'create view' command after the
+// 'create' keyword. The node holds the 'table' or 'view' keyword, the name
+// and the query.
+func (p *parser) parseCreate(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(CreateKeyword))
+
+	kind, ok := util.Next(tokens)
+	if ok && isKeyword(kind, IndexKeyword) {
+		return p.parseCreateIndex(tokens)
+	}
+	if !ok || !isKeyword(kind, TableKeyword, ViewKeyword) {
+		return nil, ErrMissingTableOrViewKeyword
+	}
+	root.AppendChild(NewAstNode(NewKeyword(KeywordType(strings.ToLower(kind.Value())))))
+
+	name, err := parseTableName(tokens, ErrMissingNameCreateCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(name)
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, AsKeyword) {
+		return nil, fmt.Errorf("'create' command: %w", ErrMissingAsKeyword)
+	}
+
+	t, ok := util.Next(tokens)
+	var query *AstNode
+	switch {
+	case ok && isKeyword(t, SelectKeyword):
+		query, err = p.parseQuery(tokens)
+	case ok && isKeyword(t, WithKeyword):
+		query, err = p.parseWith(tokens)
+	default:
+		return nil, ErrMissingSubquery
+	}
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(query)
+
+	return root, nil
+}
+
+// parseCreateIndex parses 'create index [name] on t (column)' after the
+// 'index' keyword. The node holds the 'index' keyword, with the name of the
+// index as its child when given, the table name and the column.
+func (p *parser) parseCreateIndex(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(CreateKeyword))
+
+	indexNode := NewAstNode(NewKeyword(IndexKeyword))
+	if t, ok := util.Peek(*tokens); ok && !isKeyword(t, OnKeyword) {
+		name, err := parseTableName(tokens, ErrMissingNameCreateCommand)
+		if err != nil {
+			return nil, err
+		}
+		indexNode.AppendChild(name)
+	}
+	root.AppendChild(indexNode)
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, OnKeyword) {
+		return nil, fmt.Errorf("'create' command: %w", ErrMissingOnKeyword)
+	}
+
+	table, err := parseTableName(tokens, ErrMissingNameCreateCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(table)
+
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.LeftParenthesis) {
+		return nil, ErrMissingLeftParenthesis
+	}
+	column, ok := util.Next(tokens)
+	if !ok || !column.Is(token.Word) || isKeyword(column) {
+		return nil, ErrMissingColumnNameCreateIndex
+	}
+	root.AppendChild(NewAstNode(ColumnNode(column.Value())))
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.RightParenthesis) {
+		return nil, ErrMissingRightParenthesis
+	}
+
+	return root, nil
+}
+
+// parseDrop parses a 'drop table' or 'drop view' command after the 'drop'
+// keyword.
+func (p *parser) parseDrop(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(DropKeyword))
+
+	kind, ok := util.Next(tokens)
+	if !ok || !isKeyword(kind, TableKeyword, ViewKeyword) {
+		return nil, ErrMissingTableOrViewDropCommand
+	}
+	root.AppendChild(NewAstNode(NewKeyword(KeywordType(strings.ToLower(kind.Value())))))
+
+	name, err := parseTableName(tokens, ErrMissingNameDropCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(name)
+
+	return root, nil
+}
+
+// parseAlter parses 'alter table t rename to u' after the 'alter' keyword.
+// The node holds the table name and a 'rename' node with the new name.
+func (p *parser) parseAlter(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(AlterKeyword))
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, TableKeyword) {
+		return nil, ErrMissingTableKeywordAlterCommand
+	}
+
+	name, err := parseTableName(tokens, ErrMissingTableNameAlterCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(name)
+
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, RenameKeyword) {
+		return nil, ErrMissingRenameToKeywords
+	}
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, ToKeyword) {
+		return nil, ErrMissingRenameToKeywords
+	}
+
+	newName, err := parseTableName(tokens, ErrMissingTableNameAlterCommand)
+	if err != nil {
+		return nil, err
+	}
+	renameNode := NewAstNode(NewKeyword(RenameKeyword))
+	renameNode.AppendChild(newName)
+	root.AppendChild(renameNode)
+
+	return root, nil
+}
+
+// parseReload parses a 'reload' command after the 'reload' keyword.
+func (p *parser) parseReload(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(ReloadKeyword))
+
+	name, err := parseTableName(tokens, ErrMissingTableNameReloadCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(name)
+
+	return root, nil
+}
+
+// parseDescribe parses a 'describe' command after the 'describe' keyword.
+func (p *parser) parseDescribe(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(DescribeKeyword))
+
+	name, err := parseTableName(tokens, ErrMissingTableNameDescribeCommand)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(name)
+
+	return root, nil
+}
+
+// parseSet parses a 'set' command after the 'set' keyword. The node holds
+// the name of the option and the expression of its value.
+func (p *parser) parseSet(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(SetKeyword))
+
+	t, ok := util.Next(tokens)
+	if !ok || !t.Is(token.Word) || isKeyword(t) {
+		return nil, ErrMissingOptionSetCommand
+	}
+	root.AppendChild(NewAstNode(StringNode(strings.ToLower(t.Value()))))
+
+	value, err := p.parseExpression(tokens)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(value)
+
+	return root, nil
+}
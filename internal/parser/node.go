@@ -2,7 +2,8 @@ package parser
 
 import (
 	"fmt"
-	"strings"
+
+	"github.com/kotsmile/jql/util"
 )
 
 type Node interface {
@@ -29,7 +30,9 @@ func (n *AstNode) AppendChild(c *AstNode) {
 }
 
 func (n *AstNode) String() string {
-	return n.stringHelper("", true, true)
+	return util.Tree(n, func(n *AstNode) string {
+		return fmt.Sprintf("[%s: %s]", n.value.Type(), n.value.Value())
+	}, (*AstNode).Children)
 }
 
 func (n *AstNode) Value() Node {
@@ -39,31 +42,3 @@ func (n *AstNode) Value() Node {
 func (n *AstNode) Children() []*AstNode {
 	return n.children
 }
-
-func (n *AstNode) stringHelper(prefix string, isLast bool, isRoot bool) string {
-	var sb strings.Builder
-
-	sb.WriteString(prefix)
-	if isRoot {
-	} else if isLast {
-		sb.WriteString("└── ")
-	} else {
-		sb.WriteString("├── ")
-	}
-	sb.WriteString(fmt.Sprintf("[%s: %s]", n.value.Type(), n.value.Value()))
-	sb.WriteString("\n")
-
-	childPrefix := prefix
-	if isRoot {
-	} else if isLast {
-		childPrefix += "    "
-	} else {
-		childPrefix += "│   "
-	}
-
-	for i, child := range n.children {
-		sb.WriteString(child.stringHelper(childPrefix, i == len(n.children)-1, false))
-	}
-
-	return sb.String()
-}
@@ -0,0 +1,96 @@
+package parser
+
+type OperatorType string
+
+const (
+	AddOperator      OperatorType = "+"
+	SubtractOperator OperatorType = "-"
+	MultiplyOperator OperatorType = "*"
+	DivideOperator   OperatorType = "/"
+	ModuloOperator   OperatorType = "%"
+	ConcatOperator   OperatorType = "||"
+	NegateOperator   OperatorType = "neg"
+
+	EqualOperator          OperatorType = "="
+	NotEqualOperator       OperatorType = "<>"
+	LessOperator           OperatorType = "<"
+	LessOrEqualOperator    OperatorType = "<="
+	GreaterOperator        OperatorType = ">"
+	GreaterOrEqualOperator OperatorType = ">="
+	MatchOperator          OperatorType = "~"
+	LikeOperator           OperatorType = "like"
+	ILikeOperator          OperatorType = "ilike"
+	InOperator             OperatorType = "in"
+	BetweenOperator        OperatorType = "between"
+	IsNullOperator         OperatorType = "is null"
+	IsNotNullOperator      OperatorType = "is not null"
+	ExistsOperator         OperatorType = "exists"
+
+	AndOperator OperatorType = "and"
+	OrOperator  OperatorType = "or"
+	NotOperator OperatorType = "not"
+)
+
+const comparisonPrecedence = 4
+
+// precedence of operators, higher binds tighter
+var precedence = map[OperatorType]int{
+	OrOperator:             1,
+	AndOperator:            2,
+	NotOperator:            3,
+	EqualOperator:          comparisonPrecedence,
+	NotEqualOperator:       comparisonPrecedence,
+	LessOperator:           comparisonPrecedence,
+	LessOrEqualOperator:    comparisonPrecedence,
+	GreaterOperator:        comparisonPrecedence,
+	GreaterOrEqualOperator: comparisonPrecedence,
+	MatchOperator:          comparisonPrecedence,
+	LikeOperator:           comparisonPrecedence,
+	ILikeOperator:          comparisonPrecedence,
+	InOperator:             comparisonPrecedence,
+	BetweenOperator:        comparisonPrecedence,
+	IsNullOperator:         comparisonPrecedence,
+	IsNotNullOperator:      comparisonPrecedence,
+	ConcatOperator:         5,
+	AddOperator:            6,
+	SubtractOperator:       6,
+	MultiplyOperator:       7,
+	DivideOperator:         7,
+	ModuloOperator:         7,
+	NegateOperator:         8,
+	ExistsOperator:         8,
+}
+
+func NewOperator(type_ OperatorType) *OperatorNode {
+	return &OperatorNode{
+		type_: type_,
+	}
+}
+
+func (o OperatorType) String() string {
+	return string(o)
+}
+
+func (o OperatorType) Precedence() int {
+	return precedence[o]
+}
+
+type OperatorNode struct {
+	type_ OperatorType
+}
+
+func (o *OperatorNode) String() string {
+	return o.type_.String()
+}
+
+func (o *OperatorNode) Value() string {
+	return o.type_.String()
+}
+
+func (o *OperatorNode) Type() string {
+	return "operator"
+}
+
+func (o *OperatorNode) OperatorType() OperatorType {
+	return o.type_
+}
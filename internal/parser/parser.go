@@ -2,20 +2,68 @@ package parser
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/kotsmile/jql/internal/lexer/token"
 	"github.com/kotsmile/jql/util"
 )
 
 var (
-	ErrUnknownKeyword                 = errors.New("unknown keyword")
-	ErrUnexpectedToken                = errors.New("unexpected token")
-	ErrMissingFileNameLoadCommand     = errors.New("missing file name for 'load' command")
-	ErrMissingTableNameLoadCommand    = errors.New("missing table name for 'load' command")
-	ErrMissingFromKeyword             = errors.New("'select' command: missing 'from' keyword")
-	ErrMissingTableNameSelectCommand  = errors.New("'select' command: missing table name")
-	ErrMissingColumnNameSelectCommand = errors.New("'select' command: missing column name")
-	ErrEmptyCommand                   = errors.New("empty command")
+	ErrUnknownKeyword                  = errors.New("unknown keyword")
+	ErrUnexpectedToken                 = errors.New("unexpected token")
+	ErrMissingFileNameLoadCommand      = errors.New("missing file name for 'load' command")
+	ErrMissingTableNameLoadCommand     = errors.New("missing table name for 'load' command")
+	ErrMissingFromKeyword              = errors.New("'select' command: missing 'from' keyword")
+	ErrMissingTableNameSelectCommand   = errors.New("'select' command: missing table name")
+	ErrMissingColumnNameSelectCommand  = errors.New("'select' command: missing column name")
+	ErrEmptyCommand                    = errors.New("empty command")
+	ErrMissingExpression               = errors.New("missing expression")
+	ErrMissingRightParenthesis         = errors.New("missing ')'")
+	ErrMissingLeftParenthesis          = errors.New("missing '('")
+	ErrMissingAndKeyword               = errors.New("missing 'and' keyword")
+	ErrMissingNullKeyword              = errors.New("missing 'null' keyword")
+	ErrMissingWhereCondition           = errors.New("missing condition for 'where' keyword")
+	ErrMissingByKeyword                = errors.New("'select' command: missing 'by' keyword")
+	ErrMissingTableAlias               = errors.New("'select' command: missing table alias")
+	ErrMissingSubquery                 = errors.New("missing 'select' statement")
+	ErrMissingTableNameWithCommand     = errors.New("'with' command: missing table name")
+	ErrMissingAsKeyword                = errors.New("missing 'as' keyword")
+	ErrMisplacedOrderBy                = errors.New("'order by' must follow the last select statement")
+	ErrMisplacedLimit                  = errors.New("'limit' must follow the last select statement")
+	ErrMissingLimitCount               = errors.New("missing row count for 'limit' keyword")
+	ErrMissingOffsetCount              = errors.New("missing row count for 'offset' keyword")
+	ErrMissingWhenKeyword              = errors.New("'case' expression: missing 'when' keyword")
+	ErrMissingThenKeyword              = errors.New("'case' expression: missing 'then' keyword")
+	ErrMissingEndKeyword               = errors.New("'case' expression: missing 'end' keyword")
+	ErrMissingCastType                 = errors.New("'cast' expression: missing type")
+	ErrMissingIntoKeyword              = errors.New("'insert' command: missing 'into' keyword")
+	ErrMissingTableNameInsertCommand   = errors.New("'insert' command: missing table name")
+	ErrMissingColumnNameInsertCommand  = errors.New("'insert' command: missing column name")
+	ErrMissingValuesInsertCommand      = errors.New("'insert' command: missing 'values' or 'select'")
+	ErrMissingTableNameUpdateCommand   = errors.New("'update' command: missing table name")
+	ErrMissingSetKeyword               = errors.New("'update' command: missing 'set' keyword")
+	ErrMissingAssignment               = errors.New("'update' command: missing assignment")
+	ErrMissingFromKeywordDeleteCommand = errors.New("'delete' command: missing 'from' keyword")
+	ErrMissingTableNameDeleteCommand   = errors.New("'delete' command: missing table name")
+	ErrMissingTableNameSaveCommand     = errors.New("'save' command: missing table name")
+	ErrMissingBackupKeyword            = errors.New("'save' command: missing 'backup' keyword")
+	ErrMissingTableOrViewKeyword       = errors.New("'create' command: missing 'table' or 'view' keyword")
+	ErrMissingNameCreateCommand        = errors.New("'create' command: missing name")
+	ErrMissingTableOrViewDropCommand   = errors.New("'drop' command: missing 'table' or 'view' keyword")
+	ErrMissingNameDropCommand          = errors.New("'drop' command: missing name")
+	ErrMissingTableKeywordAlterCommand = errors.New("'alter' command: missing 'table' keyword")
+	ErrMissingTableNameAlterCommand    = errors.New("'alter' command: missing table name")
+	ErrMissingRenameToKeywords         = errors.New("'alter' command: missing 'rename to' keywords")
+	ErrMissingTableNameReloadCommand   = errors.New("'reload' command: missing table name")
+	ErrMissingJoinKeyword              = errors.New("'select' command: missing 'join' keyword")
+	ErrMissingOnKeyword                = errors.New("missing 'on' keyword")
+	ErrMissingColumnNameCreateIndex    = errors.New("'create' command: missing column name for index")
+	ErrMissingTableNameDescribeCommand = errors.New("'describe' command: missing table name")
+	ErrMissingQueryExplainCommand      = errors.New("'explain' command: missing 'select' or 'with' statement")
+	ErrMissingOptionSetCommand         = errors.New("'set' command: missing option name")
+	ErrMixedPlaceholders               = errors.New("cannot mix '?' and '$n' placeholders")
+	ErrInvalidPlaceholder              = errors.New("placeholder positions start at $1")
 )
 
 type tokenInterator interface {
@@ -25,6 +73,11 @@ type tokenInterator interface {
 type parser struct {
 	tokens tokenInterator
 	logger util.Logger
+
+	// '?' placeholders of the statement so far, which count their
+	// positions, and whether it has '$n' placeholders
+	placeholders int
+	numbered     bool
 }
 
 func New(i tokenInterator, logger util.Logger) *parser {
@@ -48,6 +101,7 @@ func (p *parser) Parse() ([]*AstNode, error) {
 		}
 
 		if t.Is(token.Semicolon) {
+			p.placeholders, p.numbered = 0, false
 			node, err := p.parseNode(tokens)
 			if err != nil {
 				return nil, err
@@ -76,7 +130,7 @@ func (p *parser) parseNode(tokens []token.Token) (*AstNode, error) {
 	}
 
 	if cmdToken.Is(token.Word) {
-		switch cmdToken.Value() {
+		switch strings.ToLower(cmdToken.Value()) {
 		case LoadKeyword.String():
 			filenameToken, ok := util.Next(&tokens)
 			if !ok {
@@ -108,62 +162,113 @@ func (p *parser) parseNode(tokens []token.Token) (*AstNode, error) {
 		case TablesKeyword.String():
 			root.value = NewKeyword(TablesKeyword)
 			return root, nil
+		case BeginKeyword.String(), CommitKeyword.String(), RollbackKeyword.String():
+			root.value = NewKeyword(KeywordType(strings.ToLower(cmdToken.Value())))
+
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+			return root, nil
+		case WithKeyword.String():
+			node, err := p.parseWith(&tokens)
+			if err != nil {
+				return nil, err
+			}
+
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+
+			return node, nil
+		case ExplainKeyword.String():
+			root.value = NewKeyword(ExplainKeyword)
+
+			t, ok := util.Next(&tokens)
+			var node *AstNode
+			var err error
+			switch {
+			case ok && isKeyword(t, SelectKeyword):
+				node, err = p.parseQuery(&tokens)
+			case ok && isKeyword(t, WithKeyword):
+				node, err = p.parseWith(&tokens)
+			default:
+				return nil, ErrMissingQueryExplainCommand
+			}
+			if err != nil {
+				return nil, err
+			}
+			root.AppendChild(node)
+
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+			return root, nil
 		case SelectKeyword.String():
-			root.value = NewKeyword(SelectKeyword)
-			for len(tokens) > 0 {
-				t, ok := util.Next(&tokens)
-				if !ok {
-					return nil, ErrMissingFromKeyword
-				}
-				if t.Is(token.Word) && t.Value() == FromKeyword.String() {
-					fromNode := NewAstNode(NewKeyword(FromKeyword))
-					root.AppendChild(fromNode)
-
-					tableName, ok := util.Next(&tokens)
-					if !ok {
-						return nil, ErrMissingTableNameSelectCommand
-					}
-					fromNode.AppendChild(NewAstNode(StringNode(tableName.Value())))
-					break
-				}
+			node, err := p.parseQuery(&tokens)
+			if err != nil {
+				return nil, err
+			}
+
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+
+			return node, nil
+		case SaveKeyword.String():
+			root.value = NewKeyword(SaveKeyword)
+
+			table, err := parseTableName(&tokens, ErrMissingTableNameSaveCommand)
+			if err != nil {
+				return nil, err
+			}
+			root.AppendChild(table)
 
-				if t.Is(token.Comma) {
-					continue
+			if t, ok := util.Next(&tokens); ok {
+				if !isKeyword(t, WithKeyword) {
+					return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
 				}
-				if t.Is(token.Word) {
-					child := NewAstNode(StringNode(t.Value()))
-					root.AppendChild(child)
-
-					possibleAsToken, ok := util.Peek(tokens)
-					if !ok {
-						return nil, ErrMissingFromKeyword
-					}
-					if !possibleAsToken.Is(token.Word) {
-						continue
-					}
-					if possibleAsToken.Value() != AsKeyword.String() {
-						continue
-					}
-
-					util.Next(&tokens)
-
-					asNode := NewAstNode(NewKeyword(AsKeyword))
-					child.AppendChild(asNode)
-
-					columnNameToken, ok := util.Next(&tokens)
-					if !ok {
-						return nil, ErrMissingColumnNameSelectCommand
-					}
-					if !columnNameToken.Is(token.Word) {
-						continue
-					}
-
-					tableNameNode := NewAstNode(StringNode(columnNameToken.Value()))
-					asNode.AppendChild(tableNameNode)
+				if t, ok := util.Next(&tokens); !ok || !isKeyword(t, BackupKeyword) {
+					return nil, ErrMissingBackupKeyword
 				}
+				root.AppendChild(NewAstNode(NewKeyword(BackupKeyword)))
 			}
 
-			return root, nil
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+		case InsertKeyword.String(), UpdateKeyword.String(), DeleteKeyword.String(), CreateKeyword.String(),
+			DropKeyword.String(), AlterKeyword.String(), ReloadKeyword.String(), DescribeKeyword.String(), SetKeyword.String():
+			var node *AstNode
+			var err error
+			switch strings.ToLower(cmdToken.Value()) {
+			case InsertKeyword.String():
+				node, err = p.parseInsert(&tokens)
+			case UpdateKeyword.String():
+				node, err = p.parseUpdate(&tokens)
+			case DeleteKeyword.String():
+				node, err = p.parseDelete(&tokens)
+			case CreateKeyword.String():
+				node, err = p.parseCreate(&tokens)
+			case DropKeyword.String():
+				node, err = p.parseDrop(&tokens)
+			case AlterKeyword.String():
+				node, err = p.parseAlter(&tokens)
+			case DescribeKeyword.String():
+				node, err = p.parseDescribe(&tokens)
+			case SetKeyword.String():
+				node, err = p.parseSet(&tokens)
+			default:
+				node, err = p.parseReload(&tokens)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if t, ok := util.Peek(tokens); ok {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+			}
+
+			return node, nil
 
 		default:
 			return nil, ErrUnknownKeyword
@@ -0,0 +1,598 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kotsmile/jql/internal/lexer"
+	"github.com/kotsmile/jql/util"
+)
+
+func parse(t *testing.T, cmd string) ([]*AstNode, error) {
+	t.Helper()
+
+	logger := util.NewLoggerTest()
+	l := lexer.New(logger)
+	l.Lex(cmd)
+
+	return New(l, logger).Parse()
+}
+
+func Test_ParseExpression(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "multiplication binds tighter than addition",
+			cmd:  "select a + b * c from t;",
+			want: "a + b * c",
+		},
+		{
+			name: "parentheses",
+			cmd:  "select (a + b) * c from t;",
+			want: "(a + b) * c",
+		},
+		{
+			name: "left associative",
+			cmd:  "select a - (b - c) - d from t;",
+			want: "a - (b - c) - d",
+		},
+		{
+			name: "concatenation binds weaker than arithmetic",
+			cmd:  "select first || ' ' || n + 1 from t;",
+			want: "first || ' ' || n + 1",
+		},
+		{
+			name: "negation",
+			cmd:  "select -price * 2.5 from t;",
+			want: "-price * 2.5",
+		},
+		{
+			name: "and binds tighter than or",
+			cmd:  "select a = 1 or b = 2 and not c from t;",
+			want: "a = 1 or b = 2 and not c",
+		},
+		{
+			name: "predicates",
+			cmd:  "select a between 1 and 2 and b not like 'x%' from t;",
+			want: "a between 1 and 2 and not b like 'x%'",
+		},
+		{
+			name: "in list and is null",
+			cmd:  "select (a in (1, 2)) = (b is not null) from t;",
+			want: "a in (1, 2) = (b is not null)",
+		},
+		{
+			name: "window call",
+			cmd:  "select sum(x) over (partition by a, b order by ts desc, id) from t;",
+			want: "sum(x) over (partition by a, b order by ts desc, id)",
+		},
+		{
+			name: "aggregate call",
+			cmd:  "select count(distinct status) + count(*) from t;",
+			want: "count(distinct status) + count(*)",
+		},
+		{
+			name: "function call",
+			cmd:  "select regexp_like(name, '^j', 'i') from t;",
+			want: "regexp_like(name, '^j', 'i')",
+		},
+		{
+			name: "scalar and in subqueries",
+			cmd:  "select (select max(x) from u) + 1 > 2 and id in (select id from u where u.a = t.a) from t;",
+			want: "(select max(x) from u) + 1 > 2 and id in (select id from u where u.a = t.a)",
+		},
+		{
+			name: "set operations",
+			cmd:  "select x in (select a from u union all select b from v intersect select c from w order by 1) from t;",
+			want: "x in (select a from u union all select b from v intersect select c from w order by 1)",
+		},
+		{
+			name: "limit and offset",
+			cmd:  "select (select a from u order by a limit 1 + 1) + (select a from u union select b from v limit 1 offset 2) from t;",
+			want: "(select a from u order by a limit 1 + 1) + (select a from u union select b from v limit 1 offset 2)",
+		},
+		{
+			name: "subquery with joins",
+			cmd:  "select exists (select 1 from u as x inner join v y on x.id = y.id left outer join (select id from w) z on z.id = x.id) from t;",
+			want: "exists (select 1 from u as x join v as y on x.id = y.id left join (select id from w) as z on z.id = x.id)",
+		},
+		{
+			name: "case and cast",
+			cmd:  "select case when a > 1 then cast(a as string) else 'x' end || case b when 1 then 'one' end from t;",
+			want: "case when a > 1 then cast(a as string) else 'x' end || case b when 1 then 'one' end",
+		},
+		{
+			name: "call without arguments",
+			cmd:  "select random() + sum() from t where foo() = 1;",
+			want: "random() + sum()",
+		},
+		{
+			name: "window call without arguments",
+			cmd:  "select row_number() over (order by a) from t;",
+			want: "row_number() over (order by a)",
+		},
+		{
+			name: "try_cast",
+			cmd:  "select TRY_CAST(a + 1 AS Number) from t;",
+			want: "try_cast(a + 1 as number)",
+		},
+		{
+			name: "exists",
+			cmd:  "select not exists (select * from u) from t;",
+			want: "not exists (select * from u)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries, err := parse(t, tt.cmd)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			item, ok := util.At(queries[0].Children(), 0)
+			if !ok {
+				t.Fatalf("Parse() returned no select items")
+			}
+			if got := item.Text(); got != tt.want {
+				t.Errorf("Text() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseSelectAlias(t *testing.T) {
+	queries, err := parse(t, "select price * qty as total, name from t;")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	children := queries[0].Children()
+	if len(children) != 3 {
+		t.Fatalf("Parse() got %d children, want 3", len(children))
+	}
+
+	asNode, ok := children[0].Value().(*KeywordNode)
+	if !ok || asNode.Value() != AsKeyword.String() {
+		t.Fatalf("first item is %v, want 'as' keyword", children[0].Value())
+	}
+	if got := children[0].Children()[1].Value().Value(); got != "total" {
+		t.Errorf("alias = %s, want total", got)
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want error
+	}{
+		{
+			name: "unbalanced parenthesis",
+			cmd:  "select (a + b from t;",
+			want: ErrMissingRightParenthesis,
+		},
+		{
+			name: "dangling operator",
+			cmd:  "select a + from t;",
+			want: ErrUnexpectedToken,
+		},
+		{
+			name: "between without and",
+			cmd:  "select a from t where a between 1;",
+			want: ErrMissingAndKeyword,
+		},
+		{
+			name: "is without null",
+			cmd:  "select a from t where a is 1;",
+			want: ErrMissingNullKeyword,
+		},
+		{
+			name: "missing from",
+			cmd:  "select a;",
+			want: ErrMissingFromKeyword,
+		},
+		{
+			name: "unclosed subquery",
+			cmd:  "select a from (select a from t;",
+			want: ErrMissingRightParenthesis,
+		},
+		{
+			name: "order by before union",
+			cmd:  "select a from t order by a union select a from u;",
+			want: ErrMisplacedOrderBy,
+		},
+		{
+			name: "limit before union",
+			cmd:  "select a from t limit 1 union select a from u;",
+			want: ErrMisplacedLimit,
+		},
+		{
+			name: "limit without count",
+			cmd:  "select a from t limit;",
+			want: ErrMissingLimitCount,
+		},
+		{
+			name: "union without select",
+			cmd:  "select a from t union all a from u;",
+			want: ErrMissingSubquery,
+		},
+		{
+			name: "case without when",
+			cmd:  "select case a else 1 end from t;",
+			want: ErrMissingWhenKeyword,
+		},
+		{
+			name: "bare case",
+			cmd:  "select case;",
+			want: ErrMissingWhenKeyword,
+		},
+		{
+			name: "bare case in mixed case",
+			cmd:  "seleCt CAse;",
+			want: ErrMissingWhenKeyword,
+		},
+		{
+			name: "union without right select",
+			cmd:  "select a from t union;",
+			want: ErrMissingSubquery,
+		},
+		{
+			name: "case without then",
+			cmd:  "select case when a 1 end from t;",
+			want: ErrMissingThenKeyword,
+		},
+		{
+			name: "case without end",
+			cmd:  "select case when a then 1 from t;",
+			want: ErrMissingEndKeyword,
+		},
+		{
+			name: "cast without type",
+			cmd:  "select cast(a as) from t;",
+			want: ErrMissingCastType,
+		},
+		{
+			name: "insert without into",
+			cmd:  "insert t values (1);",
+			want: ErrMissingIntoKeyword,
+		},
+		{
+			name: "insert without values",
+			cmd:  "insert into t (a, b);",
+			want: ErrMissingValuesInsertCommand,
+		},
+		{
+			name: "update without set",
+			cmd:  "update t a = 1;",
+			want: ErrMissingSetKeyword,
+		},
+		{
+			name: "update without '='",
+			cmd:  "update t set a 1;",
+			want: ErrMissingAssignment,
+		},
+		{
+			name: "delete without from",
+			cmd:  "delete t where a = 1;",
+			want: ErrMissingFromKeywordDeleteCommand,
+		},
+		{
+			name: "save without table",
+			cmd:  "save;",
+			want: ErrMissingTableNameSaveCommand,
+		},
+		{
+			name: "save with unknown option",
+			cmd:  "save t with copy;",
+			want: ErrMissingBackupKeyword,
+		},
+		{
+			name: "create without kind",
+			cmd:  "create t as select a from u;",
+			want: ErrMissingTableOrViewKeyword,
+		},
+		{
+			name: "create without query",
+			cmd:  "create view v as;",
+			want: ErrMissingSubquery,
+		},
+		{
+			name: "drop without kind",
+			cmd:  "drop t;",
+			want: ErrMissingTableOrViewDropCommand,
+		},
+		{
+			name: "drop without name",
+			cmd:  "drop view;",
+			want: ErrMissingNameDropCommand,
+		},
+		{
+			name: "alter without table keyword",
+			cmd:  "alter t rename to u;",
+			want: ErrMissingTableKeywordAlterCommand,
+		},
+		{
+			name: "alter without to",
+			cmd:  "alter table t rename u;",
+			want: ErrMissingRenameToKeywords,
+		},
+		{
+			name: "alter without new name",
+			cmd:  "alter table t rename to;",
+			want: ErrMissingTableNameAlterCommand,
+		},
+		{
+			name: "begin with arguments",
+			cmd:  "begin transaction;",
+			want: ErrUnexpectedToken,
+		},
+		{
+			name: "join without condition",
+			cmd:  "select a from t join u;",
+			want: ErrMissingOnKeyword,
+		},
+		{
+			name: "left without join",
+			cmd:  "select a from t left u on a = b;",
+			want: ErrMissingJoinKeyword,
+		},
+		{
+			name: "join without table",
+			cmd:  "select a from t join on a = b;",
+			want: ErrUnexpectedToken,
+		},
+		{
+			name: "create index without on",
+			cmd:  "create index i t (a);",
+			want: ErrMissingOnKeyword,
+		},
+		{
+			name: "create index without column",
+			cmd:  "create index on t ();",
+			want: ErrMissingColumnNameCreateIndex,
+		},
+		{
+			name: "create index without parenthesis",
+			cmd:  "create index on t a;",
+			want: ErrMissingLeftParenthesis,
+		},
+		{
+			name: "explain without query",
+			cmd:  "explain insert into t values (1);",
+			want: ErrMissingQueryExplainCommand,
+		},
+		{
+			name: "describe without name",
+			cmd:  "describe;",
+			want: ErrMissingTableNameDescribeCommand,
+		},
+		{
+			name: "reload without name",
+			cmd:  "reload;",
+			want: ErrMissingTableNameReloadCommand,
+		},
+		{
+			name: "set without option",
+			cmd:  "set 5;",
+			want: ErrMissingOptionSetCommand,
+		},
+		{
+			name: "set without value",
+			cmd:  "set timeout;",
+			want: ErrMissingExpression,
+		},
+		{
+			name: "with without as",
+			cmd:  "with x (select a from t) select a from x;",
+			want: ErrMissingAsKeyword,
+		},
+		{
+			name: "with without select",
+			cmd:  "with x as (select a from t);",
+			want: ErrMissingSubquery,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(t, tt.cmd)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseSetOperation(t *testing.T) {
+	queries, err := parse(t, "select a from t except select a from u intersect select a from v order by a desc;")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	root := queries[0]
+	if !root.isKeyword(ExceptKeyword) {
+		t.Fatalf("root is %v, want 'except' keyword", root.Value())
+	}
+	if len(root.Children()) != 3 {
+		t.Fatalf("'except' got %d children, want 3", len(root.Children()))
+	}
+	if right := root.Children()[1]; !right.isKeyword(IntersectKeyword) {
+		t.Errorf("right operand is %v, want 'intersect' keyword", right.Value())
+	}
+	if order := root.Children()[2]; !order.isKeyword(OrderKeyword) {
+		t.Errorf("last child is %v, want 'order' keyword", order.Value())
+	}
+}
+
+func Test_ParseModify(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want []string
+	}{
+		{
+			name: "insert values",
+			cmd:  "insert into t (a, b) values (1, 'x'), (2, null);",
+			want: []string{"t", "a", "b", "values", "values"},
+		},
+		{
+			name: "insert select",
+			cmd:  "insert into t select a from u union select b from v;",
+			want: []string{"t", "union"},
+		},
+		{
+			name: "update",
+			cmd:  "update t set a = a + 1, b = 'x' where c;",
+			want: []string{"t", "set", "where"},
+		},
+		{
+			name: "delete",
+			cmd:  "delete from t;",
+			want: []string{"t"},
+		},
+		{
+			name: "create table",
+			cmd:  "create table t as select a from u;",
+			want: []string{"table", "t", "select"},
+		},
+		{
+			name: "create view with common table",
+			cmd:  "create view v as with w as (select a from u) select a from w;",
+			want: []string{"view", "v", "with"},
+		},
+		{
+			name: "save with backup",
+			cmd:  "save t with backup;",
+			want: []string{"t", "backup"},
+		},
+		{
+			name: "drop view",
+			cmd:  "drop view v;",
+			want: []string{"view", "v"},
+		},
+		{
+			name: "alter table",
+			cmd:  "alter table t rename to u;",
+			want: []string{"t", "rename"},
+		},
+		{
+			name: "reload",
+			cmd:  "reload t;",
+			want: []string{"t"},
+		},
+		{
+			name: "rollback",
+			cmd:  "rollback;",
+		},
+		{
+			name: "upper-case command",
+			cmd:  "DELETE FROM t WHERE a;",
+			want: []string{"t", "where"},
+		},
+		{
+			name: "create index",
+			cmd:  "create index on t (a);",
+			want: []string{"index", "t", "a"},
+		},
+		{
+			name: "create named index",
+			cmd:  "create index i on t(a);",
+			want: []string{"index", "t", "a"},
+		},
+		{
+			name: "describe",
+			cmd:  "describe t;",
+			want: []string{"t"},
+		},
+		{
+			name: "explain",
+			cmd:  "explain with w as (select a from t) select a from w;",
+			want: []string{"with"},
+		},
+		{
+			name: "select with join",
+			cmd:  "select a from t join u on t.a = u.a where b;",
+			want: []string{"a", "from", "where"},
+		},
+		{
+			name: "set option",
+			cmd:  "set Timeout '1m30s';",
+			want: []string{"timeout", "1m30s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries, err := parse(t, tt.cmd)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			var got []string
+			for _, child := range queries[0].Children() {
+				got = append(got, child.Value().Value())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("children = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParsePlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     string
+		want    []int
+		wantErr error
+	}{
+		{
+			name: "question marks",
+			cmd:  "select a from t where a = ? and b in (?, ?);",
+			want: []int{3},
+		},
+		{
+			name: "numbered",
+			cmd:  "update t set a = $2 where b = $1 or c = $1;",
+			want: []int{2},
+		},
+		{
+			name: "in a subquery",
+			cmd:  "select a from t where b in (select b from u where c > ?) and d = ?;",
+			want: []int{2},
+		},
+		{
+			name: "counted per statement",
+			cmd:  "insert into t (a) values (?); delete from t where a = ? or b = ?; select a from t;",
+			want: []int{1, 2, 0},
+		},
+		{
+			name:    "mixed",
+			cmd:     "select a from t where a = ? and b = $2;",
+			wantErr: ErrMixedPlaceholders,
+		},
+		{
+			name:    "position zero",
+			cmd:     "select a from t where a = $0;",
+			wantErr: ErrInvalidPlaceholder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries, err := parse(t, tt.cmd)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []int
+			for _, query := range queries {
+				got = append(got, Placeholders(query))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Placeholders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/lexer/token"
+	"github.com/kotsmile/jql/util"
+)
+
+// parseSelect parses a select statement after the 'select' keyword, leaving
+// tokens that follow it untouched.
+func (p *parser) parseSelect(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(SelectKeyword))
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, DistinctKeyword) {
+		util.Next(tokens)
+		root.AppendChild(NewAstNode(NewKeyword(DistinctKeyword)))
+	}
+
+	for {
+		item, err := p.parseSelectItem(tokens)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(item)
+
+		t, ok := util.Next(tokens)
+		if !ok {
+			return nil, ErrMissingFromKeyword
+		}
+		if t.Is(token.Comma) {
+			continue
+		}
+		if isKeyword(t, FromKeyword) {
+			break
+		}
+
+		return nil, fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+	}
+
+	fromNode, err := p.parseFrom(tokens)
+	if err != nil {
+		return nil, err
+	}
+	root.AppendChild(fromNode)
+
+	if err := p.parseWhere(tokens, root); err != nil {
+		return nil, err
+	}
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, GroupKeyword) {
+		util.Next(tokens)
+
+		groupNode, err := p.parseByList(tokens, GroupKeyword, false)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(groupNode)
+	}
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, OrderKeyword) {
+		util.Next(tokens)
+
+		orderNode, err := p.parseByList(tokens, OrderKeyword, true)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(orderNode)
+	}
+
+	limitNode, err := p.parseLimit(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if limitNode != nil {
+		root.AppendChild(limitNode)
+	}
+
+	return root, nil
+}
+
+// parseLimit parses an optional 'limit' clause with an optional 'offset'.
+func (p *parser) parseLimit(tokens *[]token.Token) (*AstNode, error) {
+	t, ok := util.Peek(*tokens)
+	if !ok || !isKeyword(t, LimitKeyword) {
+		return nil, nil
+	}
+	util.Next(tokens)
+
+	if len(*tokens) == 0 {
+		return nil, ErrMissingLimitCount
+	}
+	count, err := p.parseExpression(tokens)
+	if err != nil {
+		return nil, err
+	}
+	node := NewAstNode(NewKeyword(LimitKeyword))
+	node.AppendChild(count)
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, OffsetKeyword) {
+		util.Next(tokens)
+
+		if len(*tokens) == 0 {
+			return nil, ErrMissingOffsetCount
+		}
+		offset, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		offsetNode := NewAstNode(NewKeyword(OffsetKeyword))
+		offsetNode.AppendChild(offset)
+		node.AppendChild(offsetNode)
+	}
+
+	return node, nil
+}
+
+// parseWhere parses an optional 'where' clause into a child of root.
+func (p *parser) parseWhere(tokens *[]token.Token, root *AstNode) error {
+	t, ok := util.Peek(*tokens)
+	if !ok || !isKeyword(t, WhereKeyword) {
+		return nil
+	}
+	util.Next(tokens)
+	if len(*tokens) == 0 {
+		return ErrMissingWhereCondition
+	}
+
+	condition, err := p.parseExpression(tokens)
+	if err != nil {
+		return err
+	}
+
+	whereNode := NewAstNode(NewKeyword(WhereKeyword))
+	whereNode.AppendChild(condition)
+	root.AppendChild(whereNode)
+	return nil
+}
+
+// parseQuery parses a select statement after the 'select' keyword, followed
+// by any set operations with other select statements. 'intersect' binds
+// tighter than 'union' and 'except'. An 'order by' and a 'limit' of the last
+// select apply to the result of the whole set operation, so they are moved to
+// the root.
+func (p *parser) parseQuery(tokens *[]token.Token) (*AstNode, error) {
+	root, err := p.parseSetOperation(tokens, UnionKeyword, ExceptKeyword)
+	if err != nil {
+		return nil, err
+	}
+	if !root.isSetOperation() {
+		return root, nil
+	}
+
+	last := root
+	for last.isSetOperation() && len(last.children) > 0 {
+		last = last.children[len(last.children)-1]
+	}
+	var trailing []*AstNode
+	for {
+		clause, ok := util.At(last.children, len(last.children)-1)
+		if !ok || !clause.isKeyword(OrderKeyword) && !clause.isKeyword(LimitKeyword) {
+			break
+		}
+		last.children = last.children[:len(last.children)-1]
+		trailing = append([]*AstNode{clause}, trailing...)
+	}
+	for _, clause := range trailing {
+		root.AppendChild(clause)
+	}
+
+	return root, nil
+}
+
+// parseSetOperation parses select statements joined by one of operators,
+// where operands are the set operations that bind tighter.
+func (p *parser) parseSetOperation(tokens *[]token.Token, operators ...KeywordType) (*AstNode, error) {
+	operand := func() (*AstNode, error) {
+		if len(operators) > 1 {
+			return p.parseSetOperation(tokens, IntersectKeyword)
+		}
+		return p.parseSelect(tokens)
+	}
+
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := util.Peek(*tokens)
+		if !ok || !isKeyword(t, operators...) {
+			return left, nil
+		}
+		util.Next(tokens)
+
+		if clause, ok := util.At(left.children, len(left.children)-1); ok {
+			switch {
+			case clause.isKeyword(OrderKeyword):
+				return nil, fmt.Errorf("%w before '%s'", ErrMisplacedOrderBy, strings.ToLower(t.Value()))
+			case clause.isKeyword(LimitKeyword):
+				return nil, fmt.Errorf("%w before '%s'", ErrMisplacedLimit, strings.ToLower(t.Value()))
+			}
+		}
+
+		node := NewAstNode(NewKeyword(KeywordType(strings.ToLower(t.Value()))))
+		if t, ok := util.Peek(*tokens); ok && isKeyword(t, AllKeyword) {
+			util.Next(tokens)
+			node.AppendChild(NewAstNode(NewKeyword(AllKeyword)))
+		}
+
+		if t, ok := util.Next(tokens); !ok || !isKeyword(t, SelectKeyword) {
+			return nil, ErrMissingSubquery
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		node.AppendChild(left)
+		node.AppendChild(right)
+		left = node
+	}
+}
+
+// isSetOperation reports whether n joins select statements with 'union',
+// 'intersect' or 'except'.
+func (n *AstNode) isSetOperation() bool {
+	return n.isKeyword(UnionKeyword) || n.isKeyword(IntersectKeyword) || n.isKeyword(ExceptKeyword)
+}
+
+// parseFrom parses the source of a select: a table name or a parenthesized
+// subquery, optionally followed by an alias, and the tables joined to it.
+// Every join is a 'join' node holding a 'left' keyword for left joins, the
+// source, its alias and an 'on' node with the condition.
+func (p *parser) parseFrom(tokens *[]token.Token) (*AstNode, error) {
+	fromNode := NewAstNode(NewKeyword(FromKeyword))
+	if err := p.parseSource(tokens, fromNode); err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := util.Peek(*tokens)
+		if !ok || !isKeyword(t, JoinKeyword, InnerKeyword, LeftKeyword) {
+			return fromNode, nil
+		}
+		util.Next(tokens)
+
+		joinNode := NewAstNode(NewKeyword(JoinKeyword))
+		if !isKeyword(t, JoinKeyword) {
+			if isKeyword(t, LeftKeyword) {
+				joinNode.AppendChild(NewAstNode(NewKeyword(LeftKeyword)))
+				if t, ok := util.Peek(*tokens); ok && isKeyword(t, OuterKeyword) {
+					util.Next(tokens)
+				}
+			}
+			if t, ok := util.Next(tokens); !ok || !isKeyword(t, JoinKeyword) {
+				return nil, ErrMissingJoinKeyword
+			}
+		}
+
+		if err := p.parseSource(tokens, joinNode); err != nil {
+			return nil, err
+		}
+
+		if t, ok := util.Next(tokens); !ok || !isKeyword(t, OnKeyword) {
+			return nil, fmt.Errorf("'select' command: %w", ErrMissingOnKeyword)
+		}
+		condition, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		onNode := NewAstNode(NewKeyword(OnKeyword))
+		onNode.AppendChild(condition)
+		joinNode.AppendChild(onNode)
+
+		fromNode.AppendChild(joinNode)
+	}
+}
+
+// parseSource parses a table name or a parenthesized subquery with an
+// optional alias into children of node.
+func (p *parser) parseSource(tokens *[]token.Token, node *AstNode) error {
+	t, ok := util.Next(tokens)
+	if !ok {
+		return ErrMissingTableNameSelectCommand
+	}
+
+	if t.Is(token.LeftParenthesis) {
+		subquery, err := p.parseSubquery(tokens)
+		if err != nil {
+			return err
+		}
+		node.AppendChild(subquery)
+	} else if t.Is(token.Word) || t.Is(token.String) {
+		if isKeyword(t) {
+			return fmt.Errorf("%w: '%s'", ErrUnexpectedToken, t.Value())
+		}
+		node.AppendChild(NewAstNode(StringNode(t.Value())))
+	} else {
+		return ErrMissingTableNameSelectCommand
+	}
+
+	alias, ok := util.Peek(*tokens)
+	if ok && isKeyword(alias, AsKeyword) {
+		util.Next(tokens)
+		alias, ok = util.Peek(*tokens)
+		if !ok || !alias.Is(token.Word) || isKeyword(alias) {
+			return ErrMissingTableAlias
+		}
+	}
+	if ok && alias.Is(token.Word) && !isKeyword(alias) {
+		util.Next(tokens)
+
+		asNode := NewAstNode(NewKeyword(AsKeyword))
+		asNode.AppendChild(NewAstNode(StringNode(alias.Value())))
+		node.AppendChild(asNode)
+	}
+
+	return nil
+}
+
+// parseSubquery parses a select statement following an opening parenthesis,
+// up to and including the closing one.
+func (p *parser) parseSubquery(tokens *[]token.Token) (*AstNode, error) {
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, SelectKeyword) {
+		return nil, ErrMissingSubquery
+	}
+
+	node, err := p.parseQuery(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.RightParenthesis) {
+		return nil, ErrMissingRightParenthesis
+	}
+
+	return node, nil
+}
+
+// parseWith parses common table expressions after the 'with' keyword and
+// the select statement using them, which becomes the last child.
+func (p *parser) parseWith(tokens *[]token.Token) (*AstNode, error) {
+	root := NewAstNode(NewKeyword(WithKeyword))
+
+	for {
+		name, ok := util.Next(tokens)
+		if !ok || !name.Is(token.Word) || isKeyword(name) {
+			return nil, ErrMissingTableNameWithCommand
+		}
+
+		if t, ok := util.Next(tokens); !ok || !isKeyword(t, AsKeyword) {
+			return nil, ErrMissingAsKeyword
+		}
+		if t, ok := util.Next(tokens); !ok || !t.Is(token.LeftParenthesis) {
+			return nil, ErrMissingLeftParenthesis
+		}
+
+		subquery, err := p.parseSubquery(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		asNode := NewAstNode(NewKeyword(AsKeyword))
+		asNode.AppendChild(NewAstNode(StringNode(name.Value())))
+		asNode.AppendChild(subquery)
+		root.AppendChild(asNode)
+
+		t, ok := util.Next(tokens)
+		if ok && t.Is(token.Comma) {
+			continue
+		}
+		if !ok || !isKeyword(t, SelectKeyword) {
+			return nil, ErrMissingSubquery
+		}
+
+		node, err := p.parseQuery(tokens)
+		if err != nil {
+			return nil, err
+		}
+		root.AppendChild(node)
+
+		return root, nil
+	}
+}
+
+// parseByList parses the expressions of a 'group by', 'partition by' or
+// 'order by' clause into a node of the given keyword. Sort directions are
+// accepted when sorted is set, descending items are wrapped into a 'desc'
+// node.
+func (p *parser) parseByList(tokens *[]token.Token, keyword KeywordType, sorted bool) (*AstNode, error) {
+	if t, ok := util.Next(tokens); !ok || !isKeyword(t, ByKeyword) {
+		return nil, fmt.Errorf("%w after '%s'", ErrMissingByKeyword, keyword)
+	}
+
+	node := NewAstNode(NewKeyword(keyword))
+	for {
+		expression, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		if t, ok := util.Peek(*tokens); ok && sorted && isKeyword(t, AscKeyword, DescKeyword) {
+			util.Next(tokens)
+
+			if isKeyword(t, DescKeyword) {
+				desc := NewAstNode(NewKeyword(DescKeyword))
+				desc.AppendChild(expression)
+				expression = desc
+			}
+		}
+		node.AppendChild(expression)
+
+		if t, ok := util.Peek(*tokens); !ok || !t.Is(token.Comma) {
+			return node, nil
+		}
+		util.Next(tokens)
+	}
+}
+
+// parseWindow parses the parenthesized specification after 'over'.
+func (p *parser) parseWindow(tokens *[]token.Token) (*AstNode, error) {
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.LeftParenthesis) {
+		return nil, ErrMissingLeftParenthesis
+	}
+
+	node := NewAstNode(NewKeyword(OverKeyword))
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, PartitionKeyword) {
+		util.Next(tokens)
+
+		partitionNode, err := p.parseByList(tokens, PartitionKeyword, false)
+		if err != nil {
+			return nil, err
+		}
+		node.AppendChild(partitionNode)
+	}
+
+	if t, ok := util.Peek(*tokens); ok && isKeyword(t, OrderKeyword) {
+		util.Next(tokens)
+
+		orderNode, err := p.parseByList(tokens, OrderKeyword, true)
+		if err != nil {
+			return nil, err
+		}
+		node.AppendChild(orderNode)
+	}
+
+	if t, ok := util.Next(tokens); !ok || !t.Is(token.RightParenthesis) {
+		return nil, ErrMissingRightParenthesis
+	}
+
+	return node, nil
+}
+
+// parseSelectItem parses one entry of the select list, wrapping it into an
+// 'as' node when it is aliased.
+func (p *parser) parseSelectItem(tokens *[]token.Token) (*AstNode, error) {
+	var item *AstNode
+
+	if t, ok := util.Peek(*tokens); ok && t.Is(token.Star) {
+		util.Next(tokens)
+		item = NewAstNode(ColumnNode(t.Value()))
+	} else {
+		expression, err := p.parseExpression(tokens)
+		if err != nil {
+			return nil, err
+		}
+		item = expression
+	}
+
+	asToken, ok := util.Peek(*tokens)
+	if !ok || !isKeyword(asToken, AsKeyword) {
+		return item, nil
+	}
+	util.Next(tokens)
+
+	columnNameToken, ok := util.Next(tokens)
+	if !ok || !(columnNameToken.Is(token.Word) || columnNameToken.Is(token.String)) {
+		return nil, ErrMissingColumnNameSelectCommand
+	}
+
+	asNode := NewAstNode(NewKeyword(AsKeyword))
+	asNode.AppendChild(item)
+	asNode.AppendChild(NewAstNode(StringNode(columnNameToken.Value())))
+
+	return asNode, nil
+}
@@ -0,0 +1,164 @@
+package pgwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrMalformedMessage = errors.New("malformed message")
+
+// maxMessageSize bounds the messages a client may send.
+const maxMessageSize = 1 << 24
+
+// message is a message from the client: a kind byte followed by its data.
+// The startup message has no kind.
+type message struct {
+	kind byte
+	data []byte
+}
+
+// readMessage reads a message with a kind byte.
+func readMessage(r *bufio.Reader) (message, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return message{}, err
+	}
+	data, err := readBody(r)
+	if err != nil {
+		return message{}, err
+	}
+	return message{kind: kind, data: data}, nil
+}
+
+// readBody reads the length of a message, which counts itself, and its data.
+func readBody(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header[:]))
+	if length < 4 || length > maxMessageSize {
+		return nil, fmt.Errorf("%w: length %d", ErrMalformedMessage, length)
+	}
+
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// reader reads the fields of a message. The first read past the end sets
+// err, after which reads return zero values.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = ErrMalformedMessage
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) int16() int {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return int(int16(binary.BigEndian.Uint16(b)))
+}
+
+func (r *reader) int32() int {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(b)))
+}
+
+// string reads a string ended by a zero byte.
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.data {
+		if b == 0 {
+			s := string(r.data[:i])
+			r.data = r.data[i+1:]
+			return s
+		}
+	}
+	r.err = ErrMalformedMessage
+	return ""
+}
+
+// writer buffers messages to the client, which flush sends. The first
+// failed write is kept and returned by flush.
+type writer struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: bufio.NewWriter(w)}
+}
+
+// start begins a message of a kind, which end completes.
+func (w *writer) start(kind byte) {
+	w.buf = append(w.buf[:0], kind, 0, 0, 0, 0)
+}
+
+func (w *writer) byte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *writer) int16(n int) {
+	w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+}
+
+func (w *writer) int32(n int) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+}
+
+func (w *writer) string(s string) {
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+}
+
+func (w *writer) bytes(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *writer) end() {
+	binary.BigEndian.PutUint32(w.buf[1:5], uint32(len(w.buf)-1))
+	if w.err == nil {
+		_, w.err = w.w.Write(w.buf)
+	}
+}
+
+func (w *writer) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
@@ -0,0 +1,215 @@
+// Package pgwire serves an engine over version 3 of the PostgreSQL wire
+// protocol, so that postgres clients and drivers can query it. Both the
+// simple and the extended query protocols are spoken; connections are not
+// encrypted and need no password.
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/util"
+)
+
+var (
+	ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+	ErrInternal            = errors.New("internal error")
+)
+
+// codes of the startup messages
+const (
+	protocolVersion   = 3 << 16
+	cancelRequestCode = 80877102
+	sslRequestCode    = 80877103
+	gssRequestCode    = 80877104
+)
+
+// parameters reported to clients after startup
+var parameters = [][2]string{
+	{"server_version", "14.0"},
+	{"server_encoding", "UTF8"},
+	{"client_encoding", "UTF8"},
+	{"DateStyle", "ISO, MDY"},
+	{"TimeZone", "UTC"},
+	{"integer_datetimes", "on"},
+	{"standard_conforming_strings", "on"},
+}
+
+// Server runs the statements of postgres clients against an engine.
+type Server struct {
+	engine *engine.Engine
+	logger util.Logger
+
+	mu sync.Mutex
+	// sessions by process id, for cancel requests
+	sessions map[int]*session
+	nextID   int
+}
+
+func New(e *engine.Engine, logger util.Logger) *Server {
+	return &Server{engine: e, logger: logger, sessions: make(map[int]*session)}
+}
+
+// ListenAndServe listens on a tcp address and serves the connections to it.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve serves the connections accepted by l until it fails, which happens
+// once it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	logger := s.logger.WithField("remote", conn.RemoteAddr().String())
+
+	r := bufio.NewReader(conn)
+	session, err := s.startup(conn, r)
+	if err != nil {
+		logger.Debugf("failed to start session: %s", err)
+		return
+	}
+	if session == nil {
+		return
+	}
+	defer s.remove(session)
+	defer func() {
+		// a failing statement ends its session rather than the server
+		if r := recover(); r != nil {
+			logger.Errorf("session panicked: %v", r)
+			session.w.errorResponse(fmt.Errorf("%w: %v", ErrInternal, r))
+			session.w.flush()
+		}
+	}()
+
+	if err := session.run(); err != nil {
+		logger.Debugf("session ended: %s", err)
+	}
+}
+
+// startup reads the startup message of a connection and starts its session.
+// Cancel requests return no session.
+func (s *Server) startup(conn net.Conn, r *bufio.Reader) (*session, error) {
+	for {
+		data, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		m := &reader{data: data}
+
+		switch code := m.int32(); code {
+		case sslRequestCode, gssRequestCode:
+			// encryption is declined, after which clients go on without it
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+		case cancelRequestCode:
+			id, key := m.int32(), m.int32()
+			if m.err != nil {
+				return nil, m.err
+			}
+			s.cancel(id, key)
+			return nil, nil
+		case protocolVersion:
+			// parameters like the user and database are accepted as they are
+			for m.string() != "" {
+				m.string()
+			}
+			if m.err != nil {
+				return nil, m.err
+			}
+			return s.start(conn, r)
+		default:
+			w := newWriter(conn)
+			err := fmt.Errorf("%w: %d.%d", ErrUnsupportedProtocol, code>>16, code&0xffff)
+			w.errorResponse(err)
+			w.flush()
+			return nil, err
+		}
+	}
+}
+
+func (s *Server) start(conn net.Conn, r *bufio.Reader) (*session, error) {
+	// the transactions of the session are its own
+	engineSession := engine.NewSession()
+	ctx, cancel := context.WithCancel(engine.WithSession(context.Background(), engineSession))
+	session := &session{
+		server:     s,
+		session:    engineSession,
+		key:        int(rand.Int32()),
+		r:          r,
+		w:          newWriter(conn),
+		ctx:        ctx,
+		close:      cancel,
+		statements: make(map[string]*statement),
+		portals:    make(map[string]*portal),
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	session.id = s.nextID
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	w := session.w
+	w.start('R')
+	w.int32(0) // authentication ok
+	w.end()
+	for _, parameter := range parameters {
+		w.start('S')
+		w.string(parameter[0])
+		w.string(parameter[1])
+		w.end()
+	}
+	w.start('K')
+	w.int32(session.id)
+	w.int32(session.key)
+	w.end()
+	session.readyForQuery()
+
+	if err := w.flush(); err != nil {
+		s.remove(session)
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *Server) remove(session *session) {
+	session.closePortals()
+	session.close()
+	s.engine.EndSession(session.session)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, session.id)
+}
+
+// cancel cancels the running statement of a session, given its process id
+// and secret key.
+func (s *Server) cancel(id, key int) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+
+	if ok && session.key == key {
+		session.cancelStatement()
+	}
+}
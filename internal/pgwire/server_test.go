@@ -0,0 +1,408 @@
+package pgwire
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/util"
+)
+
+// newTestDB serves tables from json files and connects to them with a
+// postgres driver.
+func newTestDB(t *testing.T, files map[string]string) *sql.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	e := engine.New(io.Discard)
+	for name, data := range files {
+		filename := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.LoadTable(context.Background(), filename, name); err != nil {
+			t.Fatalf("LoadTable() error = %v", err)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go New(e, util.NewLogger(false)).Serve(l)
+
+	addr := l.Addr().(*net.TCPAddr)
+	db, err := sql.Open("postgres", fmt.Sprintf("host=127.0.0.1 port=%d user=jql sslmode=disable", addr.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var testFiles = map[string]string{
+	"people": `[
+		{"id": 1, "name": "ann", "admin": true, "tags": ["a"], "joined": "2024-01-02T03:04:05Z"},
+		{"id": 2, "name": "bob", "admin": false, "tags": [], "joined": "2024-02-03T04:05:06Z"}
+	]`,
+	"pets": `[{"owner": 1, "pet": "cat"}]`,
+}
+
+func Test_Query(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	rows, err := db.Query("select id, name, admin, tags, cast(joined as timestamp), null as nothing from people order by id")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotTypes []string
+	for _, ct := range types {
+		gotTypes = append(gotTypes, ct.DatabaseTypeName())
+	}
+	if want := "FLOAT8 TEXT BOOL JSON TIMESTAMPTZ TEXT"; strings.Join(gotTypes, " ") != want {
+		t.Errorf("column types = %v, want %s", gotTypes, want)
+	}
+
+	var got []string
+	for rows.Next() {
+		var id int
+		var name, tags string
+		var admin bool
+		var joined time.Time
+		var nothing sql.NullString
+		if err := rows.Scan(&id, &name, &admin, &tags, &joined, &nothing); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d %s %t %s %s %t", id, name, admin, tags, joined.UTC().Format(time.RFC3339), nothing.Valid))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err() error = %v", err)
+	}
+
+	want := []string{
+		`1 ann true ["a"] 2024-01-02T03:04:05Z false`,
+		`2 bob false [] 2024-02-03T04:05:06Z false`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func Test_Exec(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{query: "insert into pets (owner, pet) values (2, 'dog'), (2, 'eel')", want: 2},
+		{query: "update pets set pet = 'fish' where owner = 2", want: 2},
+		{query: "delete from pets where pet = 'cat'", want: 1},
+		{query: "create view owners as select distinct owner from pets", want: 0},
+	}
+	for _, tt := range tests {
+		result, err := db.Exec(tt.query)
+		if err != nil {
+			t.Fatalf("Exec(%s) error = %v", tt.query, err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n != tt.want {
+			t.Errorf("Exec(%s) rows affected = %d, %v, want %d", tt.query, n, err, tt.want)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("select count(*) from owners").Scan(&count); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("owners = %d, want 1", count)
+	}
+}
+
+func Test_Prepare(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	// prepared statements run with the extended query protocol
+	stmt, err := db.Prepare("select p.name, pet from people p left join pets on pets.owner = p.id order by p.id")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer stmt.Close()
+
+	for range 2 {
+		rows, err := stmt.Query()
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		var got []string
+		for rows.Next() {
+			var name string
+			var pet sql.NullString
+			if err := rows.Scan(&name, &pet); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			got = append(got, name+" "+pet.String)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Err() error = %v", err)
+		}
+		if want := "ann cat,bob "; strings.Join(got, ",") != want {
+			t.Errorf("rows = %q, want %s", got, want)
+		}
+	}
+
+	insert, err := db.Prepare("insert into pets (owner, pet) values (2, 'dog')")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer insert.Close()
+	result, err := insert.Exec()
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("rows affected = %d, want 1", n)
+	}
+}
+
+func Test_Parameters(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	insert, err := db.Prepare("insert into pets (owner, pet) values ($1, $2)")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer insert.Close()
+	for _, pet := range []string{"dog", "eel"} {
+		if _, err := insert.Exec(2, pet); err != nil {
+			t.Fatalf("Exec(%s) error = %v", pet, err)
+		}
+	}
+
+	rows, err := db.Query("select p.name, pet from people p join pets on pets.owner = p.id where p.admin = $1 and p.id > $2 order by pet", false, 1)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var name, pet string
+		if err := rows.Scan(&name, &pet); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, name+" "+pet)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err() error = %v", err)
+	}
+	if want := "bob dog,bob eel"; strings.Join(got, ",") != want {
+		t.Errorf("rows = %q, want %s", got, want)
+	}
+
+	var count int
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if err := db.QueryRow("select count(*) from people where cast(joined as timestamp) > cast($1 as timestamp)", since.Format(time.RFC3339)).Scan(&count); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("people joined since %s = %d, want 1", since, count)
+	}
+
+	// untyped parameters compared with or written to a string column are
+	// strings, whatever their text looks like
+	for _, pet := range []string{"123", "true"} {
+		if _, err := insert.Exec(3, pet); err != nil {
+			t.Fatalf("Exec(%s) error = %v", pet, err)
+		}
+		var owner int
+		if err := db.QueryRow("select owner from pets where pet = $1", pet).Scan(&owner); err != nil {
+			t.Fatalf("QueryRow(%s) error = %v", pet, err)
+		}
+		if owner != 3 {
+			t.Errorf("owner of %s = %d, want 3", pet, owner)
+		}
+	}
+
+	// queries without arguments run as simple queries, which cannot bind
+	// their placeholders
+	_, err = db.Exec("delete from pets where owner = $1")
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "42P02" {
+		t.Errorf("Exec() without an argument error = %v, want code 42P02", err)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	tests := []struct {
+		name  string
+		query string
+		code  pq.ErrorCode
+	}{
+		{name: "missing table", query: "select * from nothing", code: "42P01"},
+		{name: "wrong query", query: "select from people", code: "42000"},
+		{name: "load of a file without extension", query: "load 'nojson' as x", code: "42000"},
+		{name: "load without a table name", query: "load 'nojson'", code: "42000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec(tt.query)
+			var pqErr *pq.Error
+			if !errors.As(err, &pqErr) || pqErr.Code != tt.code {
+				t.Fatalf("Exec() error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+
+	// the connection is still usable after the errors
+	var name string
+	if err := db.QueryRow("select name from people where id = 2").Scan(&name); err != nil || name != "bob" {
+		t.Errorf("QueryRow() = %s, %v, want bob", name, err)
+	}
+}
+
+func Test_Transaction(t *testing.T) {
+	db := newTestDB(t, testFiles)
+
+	for _, commit := range []bool{false, true} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		if _, err := tx.Exec("delete from pets"); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatalf("commit %t error = %v", commit, err)
+		}
+
+		var count int
+		if err := db.QueryRow("select count(*) from pets").Scan(&count); err != nil {
+			t.Fatalf("QueryRow() error = %v", err)
+		}
+		want := 1
+		if commit {
+			want = 0
+		}
+		if count != want {
+			t.Errorf("pets after commit %t = %d, want %d", commit, count, want)
+		}
+	}
+}
+
+func Test_TransactionSessions(t *testing.T) {
+	db := newTestDB(t, testFiles)
+	ctx := context.Background()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := tx.Exec("delete from pets"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	// queries of another connection read the pets from before the
+	// transaction
+	var before int
+	if err := db.QueryRow("select count(*) from pets").Scan(&before); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if before != 1 {
+		t.Errorf("pets in another connection = %d, want 1", before)
+	}
+
+	// the insert of another connection waits for the transaction, and is
+	// not rolled back with it
+	inserted := make(chan error, 1)
+	go func() {
+		_, err := db.Exec("insert into pets (owner, pet) values (2, 'dog')")
+		inserted <- err
+	}()
+	select {
+	case err := <-inserted:
+		t.Fatalf("Exec() ran during the transaction, error = %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err := <-inserted; err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	// the transaction of a closed connection is rolled back
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"begin", "delete from pets"} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatalf("ExecContext(%s) error = %v", query, err)
+		}
+	}
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+
+	var count int
+	if err := db.QueryRow("select count(*) from pets").Scan(&count); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("pets = %d, want 2", count)
+	}
+}
+
+func Test_Cancel(t *testing.T) {
+	rows := make([]string, 0, 3000)
+	for i := range 3000 {
+		rows = append(rows, fmt.Sprintf(`{"id": %d}`, i))
+	}
+	db := newTestDB(t, map[string]string{"big": "[" + strings.Join(rows, ",") + "]"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the join compares every pair of rows, which takes far longer; the
+	// driver sends a cancel request once ctx is done
+	start := time.Now()
+	var count int
+	err := db.QueryRowContext(ctx, "select count(*) from big a join big b on a.id + b.id < 0").Scan(&count)
+	if err == nil {
+		t.Fatalf("QueryRowContext() error = nil, want an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("canceled query took %s", elapsed)
+	}
+
+	var n int
+	if err := db.QueryRow("select count(*) from big").Scan(&n); err != nil || n != 3000 {
+		t.Errorf("QueryRow() = %d, %v, want 3000", n, err)
+	}
+}
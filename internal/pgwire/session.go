@@ -0,0 +1,656 @@
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kotsmile/jql"
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/internal/lexer"
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+var (
+	ErrMultipleCommands   = errors.New("cannot insert multiple commands into a prepared statement")
+	ErrUnknownStatement   = errors.New("prepared statement does not exist")
+	ErrUnknownPortal      = errors.New("portal does not exist")
+	ErrDuplicateStatement = errors.New("prepared statement already exists")
+	ErrDuplicatePortal    = errors.New("portal already exists")
+	ErrUnknownFormat      = errors.New("unknown format code")
+	ErrUnknownMessage     = errors.New("unknown message type")
+	ErrParameter          = errors.New("invalid parameter")
+)
+
+// sqlStates are the error codes sent with errors, by the errors they wrap.
+// Other errors are syntax errors or access rule violations.
+var sqlStates = []struct {
+	err  error
+	code string
+}{
+	{engine.ErrTableNotFound, "42P01"},
+	{context.Canceled, "57014"},
+	{context.DeadlineExceeded, "57014"},
+	{ErrMultipleCommands, "42601"},
+	{engine.ErrUnboundPlaceholder, "42P02"},
+	{engine.ErrArgumentCount, "08P01"},
+	{engine.ErrArgumentType, "0A000"},
+	{ErrParameter, "22P02"},
+	{ErrUnsupportedProtocol, "0A000"},
+	{ErrUnknownStatement, "26000"},
+	{ErrUnknownPortal, "34000"},
+	{ErrDuplicateStatement, "42P05"},
+	{ErrDuplicatePortal, "42P03"},
+	{ErrMalformedMessage, "08P01"},
+	{ErrUnknownFormat, "08P01"},
+	{ErrUnknownMessage, "08P01"},
+	{ErrInternal, "XX000"},
+}
+
+func sqlState(err error) string {
+	for _, state := range sqlStates {
+		if errors.Is(err, state.err) {
+			return state.code
+		}
+	}
+	return "42000"
+}
+
+// statement is a prepared statement.
+type statement struct {
+	// nil for an empty query
+	query *parser.AstNode
+	// oids of the parameters, which are 0 when the client left them out
+	types []int
+}
+
+// portal is a statement ready to run, which may read its rows over several
+// executes.
+type portal struct {
+	// the statement bound to its parameters, nil for an empty query
+	query *parser.AstNode
+	// result formats, one for all columns or one per column
+	formats []int
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// open while the rows of a query are read
+	cursor  *engine.Cursor
+	columns []engine.Column
+	// the statement is not a query
+	exec bool
+	// rows sent so far
+	rows int
+	// tag of the command once it completed
+	tag string
+}
+
+func (p *portal) close() {
+	if p.cursor != nil {
+		p.cursor.Close()
+		p.cursor = nil
+	}
+	p.cancel()
+}
+
+// session is the connection of a client. Its statements run one at a time,
+// and a transaction it begins belongs to its session of the engine, so other
+// sessions do not see its changes until it commits.
+type session struct {
+	server *Server
+	// process id and secret key, which cancel requests give
+	id, key int
+	r       *bufio.Reader
+	w       *writer
+	// done when the session ends, and carrying the session of the engine
+	ctx     context.Context
+	close   context.CancelFunc
+	session *engine.Session
+
+	statements map[string]*statement
+	portals    map[string]*portal
+	// an extended query failed, so messages are skipped until the next sync
+	failed bool
+
+	mu sync.Mutex
+	// cancels the running statement
+	cancel context.CancelFunc
+}
+
+// run handles the messages of the client until it terminates.
+func (s *session) run() error {
+	for {
+		msg, err := readMessage(s.r)
+		if err != nil {
+			return err
+		}
+		if s.failed && msg.kind != 'S' {
+			continue
+		}
+		m := &reader{data: msg.data}
+
+		switch msg.kind {
+		case 'Q':
+			s.simpleQuery(m)
+		case 'P':
+			err = s.parseMessage(m)
+		case 'B':
+			err = s.bind(m)
+		case 'D':
+			err = s.describe(m)
+		case 'E':
+			err = s.execute(m)
+		case 'C':
+			err = s.closeMessage(m)
+		case 'S':
+			// the end of an implicit transaction closes its portals
+			s.failed = false
+			s.closePortals()
+			s.readyForQuery()
+		case 'H':
+		case 'X':
+			return nil
+		default:
+			s.w.errorResponse(fmt.Errorf("%w: '%c'", ErrUnknownMessage, msg.kind))
+			s.w.flush()
+			return fmt.Errorf("%w: '%c'", ErrUnknownMessage, msg.kind)
+		}
+		if err != nil {
+			s.w.errorResponse(err)
+			s.failed = true
+		}
+
+		switch msg.kind {
+		case 'Q', 'S', 'H':
+			if err := s.w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// transactionModes are the modes drivers start transactions with, which
+// jql transactions always have.
+var transactionModes = []string{" read write", " isolation level serializable"}
+
+// parse parses the statements of a query, which are none for an empty one.
+func (s *session) parse(query string) ([]*parser.AstNode, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	if query == "" {
+		return nil, nil
+	}
+	for _, mode := range transactionModes {
+		if strings.EqualFold(query, "begin"+mode) {
+			query = "begin"
+		}
+	}
+
+	logger := s.server.logger
+	l := lexer.New(logger.WithField("module", "lexer"))
+	l.Lex(query + ";")
+	return parser.New(l, logger.WithField("module", "parser")).Parse()
+}
+
+// simpleQuery runs the statements of a query message in turn, stopping at
+// the first error.
+func (s *session) simpleQuery(m *reader) {
+	defer s.readyForQuery()
+
+	text := m.string()
+	if m.err != nil {
+		s.w.errorResponse(m.err)
+		return
+	}
+	statements, err := s.parse(text)
+	if err != nil {
+		s.w.errorResponse(err)
+		return
+	}
+	if len(statements) == 0 {
+		s.w.start('I')
+		s.w.end()
+		return
+	}
+
+	for _, query := range statements {
+		if err := s.runStatement(query); err != nil {
+			s.w.errorResponse(err)
+			return
+		}
+	}
+}
+
+func (s *session) runStatement(query *parser.AstNode) error {
+	p := s.newPortal(query, nil)
+	defer p.close()
+
+	if err := s.open(p); err != nil {
+		return err
+	}
+	if !p.exec {
+		s.w.rowDescription(p.columns, nil)
+	}
+	return s.runPortal(p, 0)
+}
+
+func (s *session) newPortal(query *parser.AstNode, formats []int) *portal {
+	ctx, cancel := context.WithCancel(s.ctx)
+	return &portal{query: query, formats: formats, ctx: ctx, cancel: cancel}
+}
+
+// open opens the cursor of a portal running a query, whose columns are then
+// known.
+func (s *session) open(p *portal) error {
+	if p.query == nil || p.cursor != nil || p.exec || p.tag != "" {
+		return nil
+	}
+	// planning may already read the tables
+	s.setCancel(p.cancel)
+	defer s.setCancel(nil)
+
+	cursor, err := s.server.engine.Query(p.ctx, p.query)
+	if errors.Is(err, engine.ErrNotQuery) {
+		p.exec = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p.cursor = cursor
+	p.columns = cursor.Columns()
+	return nil
+}
+
+// runPortal runs a portal, sending at most maxRows rows of a query when it is
+// positive. A portal with rows left is suspended until it runs again.
+func (s *session) runPortal(p *portal, maxRows int) error {
+	if p.query == nil {
+		s.w.start('I')
+		s.w.end()
+		return nil
+	}
+	if p.tag != "" {
+		s.w.commandComplete(p.tag)
+		return nil
+	}
+	if err := s.open(p); err != nil {
+		return err
+	}
+	s.setCancel(p.cancel)
+	defer s.setCancel(nil)
+
+	if p.exec {
+		n, err := s.server.engine.Exec(p.ctx, p.query)
+		if err != nil {
+			return err
+		}
+		p.tag = commandTag(p.query, n)
+		s.w.commandComplete(p.tag)
+		return nil
+	}
+
+	for sent := 0; maxRows <= 0 || sent < maxRows; sent++ {
+		values, ok, err := p.cursor.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			p.cursor.Close()
+			p.cursor = nil
+			p.tag = fmt.Sprintf("SELECT %d", p.rows)
+			s.w.commandComplete(p.tag)
+			return nil
+		}
+		if err := s.w.dataRow(values, p.formats); err != nil {
+			return err
+		}
+		p.rows++
+	}
+
+	s.w.start('s') // portal suspended
+	s.w.end()
+	return nil
+}
+
+// commandTag is the tag completing a statement which is not a query.
+func commandTag(query *parser.AstNode, n int) string {
+	keyword := query.Value().Value()
+	switch keyword {
+	case parser.InsertKeyword.String():
+		return fmt.Sprintf("INSERT 0 %d", n)
+	case parser.UpdateKeyword.String():
+		return fmt.Sprintf("UPDATE %d", n)
+	case parser.DeleteKeyword.String():
+		return fmt.Sprintf("DELETE %d", n)
+	}
+	return strings.ToUpper(keyword)
+}
+
+func (s *session) parseMessage(m *reader) error {
+	name := m.string()
+	text := m.string()
+	types := make([]int, m.int16())
+	for i := range types {
+		types[i] = m.int32()
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	if _, ok := s.statements[name]; ok && name != "" {
+		return fmt.Errorf("%w: '%s'", ErrDuplicateStatement, name)
+	}
+	statements, err := s.parse(text)
+	if err != nil {
+		return err
+	}
+	if len(statements) > 1 {
+		return ErrMultipleCommands
+	}
+
+	st := &statement{types: types}
+	if len(statements) == 1 {
+		st.query = statements[0]
+		if n := parser.Placeholders(st.query); n > len(types) {
+			st.types = append(types, make([]int, n-len(types))...)
+		}
+		if err := s.inferTypes(st); err != nil {
+			return err
+		}
+	}
+	s.statements[name] = st
+
+	s.w.start('1') // parse complete
+	s.w.end()
+	return nil
+}
+
+// inferTypes gives the parameters the client left without a type the type
+// of where the statement uses them, like postgres does, so that '$1' in
+// 'name = $1' is text and in 'id = $1' a number. Parameters used nowhere
+// with a known type stay text.
+func (s *session) inferTypes(st *statement) error {
+	if !slices.Contains(st.types, 0) {
+		return nil
+	}
+
+	types, err := s.server.engine.ParameterTypes(s.ctx, st.query)
+	if err != nil {
+		return err
+	}
+	for i, t := range types {
+		if i < len(st.types) && st.types[i] == 0 && t != "" {
+			st.types[i] = columnType(jql.Type(t)).oid
+		}
+	}
+	return nil
+}
+
+func (s *session) bind(m *reader) error {
+	portalName := m.string()
+	statementName := m.string()
+	paramFormats := make([]int, m.int16())
+	for i := range paramFormats {
+		paramFormats[i] = m.int16()
+	}
+	// nil values are nulls
+	params := make([][]byte, m.int16())
+	for i := range params {
+		if size := m.int32(); size >= 0 {
+			params[i] = m.next(size)
+		}
+	}
+	formats := make([]int, m.int16())
+	for i := range formats {
+		formats[i] = m.int16()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	for _, f := range append(paramFormats, formats...) {
+		if f != textFormat && f != binaryFormat {
+			return fmt.Errorf("%w: %d", ErrUnknownFormat, f)
+		}
+	}
+
+	st, ok := s.statements[statementName]
+	if !ok {
+		return fmt.Errorf("%w: '%s'", ErrUnknownStatement, statementName)
+	}
+	query := st.query
+	if query != nil {
+		args := make([]any, len(params))
+		for i, param := range params {
+			var oid int
+			if i < len(st.types) {
+				oid = st.types[i]
+			}
+			arg, err := decodeParameter(param, oid, format(paramFormats, i))
+			if err != nil {
+				return fmt.Errorf("parameter $%d: %w", i+1, err)
+			}
+			args[i] = arg
+		}
+
+		bound, err := engine.Bind(query, args)
+		if err != nil {
+			return err
+		}
+		query = bound
+	}
+	if old, ok := s.portals[portalName]; ok {
+		if portalName != "" {
+			return fmt.Errorf("%w: '%s'", ErrDuplicatePortal, portalName)
+		}
+		old.close()
+	}
+	s.portals[portalName] = s.newPortal(query, formats)
+
+	s.w.start('2') // bind complete
+	s.w.end()
+	return nil
+}
+
+// describe describes the parameters and columns of a statement, or the
+// columns of a portal.
+func (s *session) describe(m *reader) error {
+	kind := m.byte()
+	name := m.string()
+	if m.err != nil {
+		return m.err
+	}
+
+	switch kind {
+	case 'S':
+		st, ok := s.statements[name]
+		if !ok {
+			return fmt.Errorf("%w: '%s'", ErrUnknownStatement, name)
+		}
+		s.w.start('t') // parameter description
+		s.w.int16(len(st.types))
+		for _, oid := range st.types {
+			if oid == 0 {
+				oid = textOID
+			}
+			s.w.int32(oid)
+		}
+		s.w.end()
+		if st.query == nil {
+			s.w.start('n') // no data
+			s.w.end()
+			return nil
+		}
+
+		// the columns are known once the query is planned, which a portal
+		// closed right away does, with nulls for the parameters
+		query, err := engine.Bind(st.query, make([]any, parser.Placeholders(st.query)))
+		if err != nil {
+			return err
+		}
+		p := s.newPortal(query, nil)
+		defer p.close()
+		return s.describePortal(p)
+	case 'P':
+		p, ok := s.portals[name]
+		if !ok {
+			return fmt.Errorf("%w: '%s'", ErrUnknownPortal, name)
+		}
+		return s.describePortal(p)
+	}
+	return fmt.Errorf("%w: describe of '%c'", ErrMalformedMessage, kind)
+}
+
+func (s *session) describePortal(p *portal) error {
+	if err := s.open(p); err != nil {
+		return err
+	}
+	if p.query == nil || p.exec {
+		s.w.start('n') // no data
+		s.w.end()
+		return nil
+	}
+	s.w.rowDescription(p.columns, p.formats)
+	return nil
+}
+
+func (s *session) execute(m *reader) error {
+	name := m.string()
+	maxRows := m.int32()
+	if m.err != nil {
+		return m.err
+	}
+
+	p, ok := s.portals[name]
+	if !ok {
+		return fmt.Errorf("%w: '%s'", ErrUnknownPortal, name)
+	}
+	return s.runPortal(p, maxRows)
+}
+
+func (s *session) closeMessage(m *reader) error {
+	kind := m.byte()
+	name := m.string()
+	if m.err != nil {
+		return m.err
+	}
+
+	switch kind {
+	case 'S':
+		delete(s.statements, name)
+	case 'P':
+		if p, ok := s.portals[name]; ok {
+			p.close()
+			delete(s.portals, name)
+		}
+	default:
+		return fmt.Errorf("%w: close of '%c'", ErrMalformedMessage, kind)
+	}
+
+	s.w.start('3') // close complete
+	s.w.end()
+	return nil
+}
+
+func (s *session) closePortals() {
+	for name, p := range s.portals {
+		p.close()
+		delete(s.portals, name)
+	}
+}
+
+func (s *session) readyForQuery() {
+	status := byte('I')
+	if s.server.engine.InTransaction(s.ctx) {
+		status = 'T'
+	}
+	s.w.start('Z')
+	s.w.byte(status)
+	s.w.end()
+}
+
+func (s *session) setCancel(cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel = cancel
+}
+
+func (s *session) cancelStatement() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (w *writer) errorResponse(err error) {
+	w.start('E')
+	w.byte('S')
+	w.string("ERROR")
+	w.byte('V')
+	w.string("ERROR")
+	w.byte('C')
+	w.string(sqlState(err))
+	w.byte('M')
+	w.string(err.Error())
+	w.byte(0)
+	w.end()
+}
+
+func (w *writer) commandComplete(tag string) {
+	w.start('C')
+	w.string(tag)
+	w.end()
+}
+
+func (w *writer) rowDescription(columns []engine.Column, formats []int) {
+	w.start('T')
+	w.int16(len(columns))
+	for i, column := range columns {
+		t := columnType(jql.Type(column.Type))
+		w.string(column.Name)
+		w.int32(0) // table oid
+		w.int16(0) // column number
+		w.int32(t.oid)
+		w.int16(t.size)
+		w.int32(-1) // type modifier
+		w.int16(format(formats, i))
+	}
+	w.end()
+}
+
+func (w *writer) dataRow(values []any, formats []int) error {
+	w.start('D')
+	w.int16(len(values))
+	for i, value := range values {
+		data, err := encodeValue(value, format(formats, i))
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			w.int32(-1)
+			continue
+		}
+		w.int32(len(data))
+		w.bytes(data)
+	}
+	w.end()
+	return nil
+}
+
+// format is the format of a column, given one format for all columns or one
+// per column. No formats mean text.
+func format(formats []int, i int) int {
+	switch {
+	case len(formats) == 0:
+		return textFormat
+	case len(formats) == 1:
+		return formats[0]
+	case i < len(formats):
+		return formats[i]
+	}
+	return textFormat
+}
@@ -0,0 +1,189 @@
+package pgwire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/jql"
+)
+
+// oids of the postgres types the columns are sent as
+const (
+	boolOID        = 16
+	jsonOID        = 114
+	textOID        = 25
+	float8OID      = 701
+	timestamptzOID = 1184
+)
+
+// oids of other types parameters may be declared as
+const (
+	int8OID      = 20
+	int2OID      = 21
+	int4OID      = 23
+	float4OID    = 700
+	dateOID      = 1082
+	timestampOID = 1114
+	numericOID   = 1700
+)
+
+// formats of values
+const (
+	textFormat   = 0
+	binaryFormat = 1
+)
+
+// pgType is the postgres type of a column type.
+type pgType struct {
+	oid int
+	// size of values in bytes, or -1 for variable sizes
+	size int
+}
+
+// columnType maps a column type to a postgres type. Arrays and objects are
+// json, and columns holding only nulls are text.
+func columnType(t jql.Type) pgType {
+	switch t {
+	case jql.NumberType:
+		return pgType{oid: float8OID, size: 8}
+	case jql.BooleanType:
+		return pgType{oid: boolOID, size: 1}
+	case jql.TimestampType:
+		return pgType{oid: timestamptzOID, size: 8}
+	case jql.ArrayType, jql.ObjectType:
+		return pgType{oid: jsonOID, size: -1}
+	}
+	return pgType{oid: textOID, size: -1}
+}
+
+// postgres counts time from 2000-01-01 in binary timestamps
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const timestampLayout = "2006-01-02 15:04:05.999999-07:00"
+
+// encodeValue encodes a value in a format. Nulls are nil.
+func encodeValue(value any, format int) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if format == binaryFormat {
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(v)), nil
+		}
+		return []byte(formatNumber(v)), nil
+	case string:
+		return []byte(v), nil
+	case bool:
+		if format == binaryFormat {
+			if v {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		}
+		if v {
+			return []byte("t"), nil
+		}
+		return []byte("f"), nil
+	case time.Time:
+		if format == binaryFormat {
+			return binary.BigEndian.AppendUint64(nil, uint64(v.UnixMicro()-pgEpoch.UnixMicro())), nil
+		}
+		return []byte(v.Format(timestampLayout)), nil
+	case []any, map[string]any:
+		// the binary format of json is its text
+		return json.Marshal(v)
+	}
+	return nil, fmt.Errorf("cannot encode value of type %T", value)
+}
+
+// formatNumber writes whole numbers without an exponent, like postgres
+// writes float8 values.
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// parameterLayouts are the text forms of timestamp parameters.
+var parameterLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// decodeParameter decodes a parameter of a type in a format. Nulls are nil,
+// and parameters of other types, or without one, are strings.
+func decodeParameter(data []byte, oid, format int) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+	if format == binaryFormat {
+		return decodeBinary(data, oid)
+	}
+
+	text := string(data)
+	switch oid {
+	case boolOID:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: boolean '%s'", ErrParameter, text)
+		}
+		return b, nil
+	case int2OID, int4OID, int8OID, float4OID, float8OID, numericOID:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: number '%s'", ErrParameter, text)
+		}
+		return number, nil
+	case timestamptzOID, timestampOID, dateOID:
+		for _, layout := range parameterLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: timestamp '%s'", ErrParameter, text)
+	}
+	return text, nil
+}
+
+// binarySizes are the sizes of the binary values of fixed size types.
+var binarySizes = map[int]int{
+	boolOID: 1, int2OID: 2, int4OID: 4, int8OID: 8, float4OID: 4, float8OID: 8,
+	timestamptzOID: 8, timestampOID: 8,
+}
+
+func decodeBinary(data []byte, oid int) (any, error) {
+	if n, ok := binarySizes[oid]; ok && len(data) != n {
+		return nil, fmt.Errorf("%w: %d bytes for type %d", ErrParameter, len(data), oid)
+	}
+
+	switch oid {
+	case boolOID:
+		return data[0] != 0, nil
+	case int2OID:
+		return float64(int16(binary.BigEndian.Uint16(data))), nil
+	case int4OID:
+		return float64(int32(binary.BigEndian.Uint32(data))), nil
+	case int8OID:
+		return float64(int64(binary.BigEndian.Uint64(data))), nil
+	case float4OID:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case float8OID:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case timestamptzOID, timestampOID:
+		micros := int64(binary.BigEndian.Uint64(data))
+		return time.UnixMicro(pgEpoch.UnixMicro() + micros).UTC(), nil
+	case 0, textOID:
+		return string(data), nil
+	}
+	return nil, fmt.Errorf("%w: binary format of type %d", ErrParameter, oid)
+}
@@ -0,0 +1,79 @@
+package pgwire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_EncodeValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  any
+		format int
+		want   []byte
+	}{
+		{name: "null", value: nil, want: nil},
+		{name: "whole number", value: 3.0, want: []byte("3")},
+		{name: "fraction", value: 0.25, want: []byte("0.25")},
+		{name: "big number", value: 1e20, want: []byte("1e+20")},
+		{name: "number in binary", value: 1.0, format: binaryFormat, want: []byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0}},
+		{name: "string", value: "ann", want: []byte("ann")},
+		{name: "boolean", value: true, want: []byte("t")},
+		{name: "boolean in binary", value: false, format: binaryFormat, want: []byte{0}},
+		{name: "timestamp", value: time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC), want: []byte("2024-01-02 03:04:05.6+00:00")},
+		{name: "timestamp in binary", value: time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC), format: binaryFormat, want: []byte{0, 0, 0, 0, 0, 0x0f, 0x42, 0x40}},
+		{name: "array", value: []any{1.0, "a"}, want: []byte(`[1,"a"]`)},
+		{name: "object", value: map[string]any{"a": nil}, want: []byte(`{"a":null}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeValue(tt.value, tt.format)
+			if err != nil {
+				t.Fatalf("encodeValue() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_DecodeParameter(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		oid     int
+		format  int
+		want    any
+		wantErr bool
+	}{
+		{name: "null", data: nil, oid: int4OID, want: nil},
+		{name: "untyped number text", data: []byte("42"), want: "42"},
+		{name: "untyped boolean text", data: []byte("true"), want: "true"},
+		{name: "untyped string", data: []byte("ann"), want: "ann"},
+		{name: "text typed as text", data: []byte("42"), oid: textOID, want: "42"},
+		{name: "integer", data: []byte("-7"), oid: int8OID, want: -7.0},
+		{name: "wrong integer", data: []byte("x"), oid: int4OID, wantErr: true},
+		{name: "boolean", data: []byte("t"), oid: boolOID, want: true},
+		{name: "timestamp", data: []byte("2024-01-02 03:04:05.5+01:00"), oid: timestamptzOID, want: time.Date(2024, 1, 2, 2, 4, 5, 500000000, time.UTC)},
+		{name: "binary integer", data: []byte{0, 0, 1, 0}, oid: int4OID, format: binaryFormat, want: 256.0},
+		{name: "binary float", data: []byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, oid: float8OID, format: binaryFormat, want: 1.0},
+		{name: "binary timestamp", data: []byte{0, 0, 0, 0, 0, 0x0f, 0x42, 0x40}, oid: timestamptzOID, format: binaryFormat, want: time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{name: "binary of wrong size", data: []byte{1, 2}, oid: int4OID, format: binaryFormat, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeParameter(tt.data, tt.oid, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeParameter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if moment, ok := got.(time.Time); ok {
+				got = moment.UTC()
+			}
+			if got != tt.want {
+				t.Errorf("decodeParameter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
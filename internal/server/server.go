@@ -0,0 +1,275 @@
+// Package server serves a jql database over HTTP:
+//
+//	POST /query                 run a statement given as {"query": "...", "args": [...]}
+//	GET  /tables                list the tables and views
+//	GET  /tables/{name}/schema  list the columns of a table or view
+//
+// Query results are a json object with the columns and rows, or, when the
+// request accepts application/x-ndjson, a line with the columns followed by
+// a line per row. Transactions, the statements reading and writing files
+// of the host and 'set' are rejected.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/jql"
+	"github.com/kotsmile/jql/internal/lexer"
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var (
+	ErrReadOnly     = errors.New("server is read-only")
+	ErrMissingQuery = errors.New("missing query")
+	// transactions belong to a session, which requests are not
+	ErrTransaction = errors.New("transactions are not supported over http")
+	// statements reading and writing files of the host or changing options
+	// of every client are left to its users
+	ErrForbidden = errors.New("'load', 'save', 'reload' and 'set' are not supported over http")
+)
+
+const (
+	ndjsonType = "application/x-ndjson"
+	// maxRequestSize bounds the body of a query request
+	maxRequestSize = 1 << 20
+)
+
+// Options configure a Server.
+type Options struct {
+	// ReadOnly rejects statements other than queries
+	ReadOnly bool
+	// Timeout bounds the time of a request when it is not zero
+	Timeout time.Duration
+}
+
+// Server is an http.Handler running requests against a database.
+type Server struct {
+	db      *jql.DB
+	options Options
+	mux     *http.ServeMux
+}
+
+func New(db *jql.DB, options Options) *Server {
+	s := &Server{db: db, options: options, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /query", s.handleQuery)
+	s.mux.HandleFunc("GET /tables", s.handleTables)
+	s.mux.HandleFunc("GET /tables/{name}/schema", s.handleSchema)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.options.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), s.options.Timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+type queryRequest struct {
+	Query string `json:"query"`
+	// Args are bound to the placeholders of the query
+	Args []any `json:"args"`
+}
+
+type column struct {
+	Name string   `json:"name"`
+	Type jql.Type `json:"type"`
+}
+
+type queryResponse struct {
+	Columns []column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+type execResponse struct {
+	RowsAffected int `json:"rows_affected"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type tableResponse struct {
+	Name string `json:"name"`
+	// "table" or "view"
+	Type string `json:"type"`
+	Rows *int   `json:"rows,omitempty"`
+}
+
+type schemaResponse struct {
+	Name    string   `json:"name"`
+	Columns []column `json:"columns"`
+}
+
+// handleQuery runs a query, or another statement unless the server is
+// read-only, which returns the number of rows it changed.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var request queryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to decode request: %w", err))
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		writeError(w, http.StatusBadRequest, ErrMissingQuery)
+		return
+	}
+
+	rows, err := s.db.Query(r.Context(), request.Query, request.Args...)
+	if errors.Is(err, jql.ErrNotQuery) {
+		s.exec(w, r, request)
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	defer rows.Close()
+
+	columns := newColumns(rows.Columns())
+	if strings.Contains(r.Header.Get("Accept"), ndjsonType) {
+		writeRows(w, columns, rows)
+		return
+	}
+
+	// rows are read before answering, so that errors get their status
+	response := queryResponse{Columns: columns, Rows: make([][]any, 0)}
+	for rows.Next() {
+		response.Rows = append(response.Rows, rows.Values())
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) exec(w http.ResponseWriter, r *http.Request, request queryRequest) {
+	if s.options.ReadOnly {
+		writeError(w, http.StatusForbidden, ErrReadOnly)
+		return
+	}
+	for _, command := range commands(request.Query) {
+		switch command {
+		case parser.BeginKeyword.String(), parser.CommitKeyword.String(), parser.RollbackKeyword.String():
+			writeError(w, http.StatusBadRequest, ErrTransaction)
+			return
+		case parser.LoadKeyword.String(), parser.SaveKeyword.String(), parser.ReloadKeyword.String(), parser.SetKeyword.String():
+			writeError(w, http.StatusForbidden, ErrForbidden)
+			return
+		}
+	}
+
+	n, err := s.db.Exec(r.Context(), request.Query, request.Args...)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, execResponse{RowsAffected: n})
+}
+
+// commands returns the commands of the statements of a query, such as
+// 'insert'. Queries that fail to parse have none, and fail when they run.
+func commands(query string) []string {
+	query = strings.TrimSpace(query)
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+
+	logger := util.NewLogger(false)
+	l := lexer.New(logger)
+	l.Lex(query)
+	statements, err := parser.New(l, logger).Parse()
+	if err != nil {
+		return nil
+	}
+	commands := make([]string, 0, len(statements))
+	for _, statement := range statements {
+		commands = append(commands, statement.Value().Value())
+	}
+	return commands
+}
+
+// writeRows streams rows as ndjson. An error after the first line can only
+// be reported by a last line holding it.
+func writeRows(w http.ResponseWriter, columns []column, rows *jql.Rows) {
+	w.Header().Set("Content-Type", ndjsonType)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(map[string][]column{"columns": columns}); err != nil {
+		return
+	}
+	for rows.Next() {
+		if err := encoder.Encode(rows.Values()); err != nil {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		encoder.Encode(errorResponse{Error: err.Error()})
+	}
+}
+
+func (s *Server) handleTables(w http.ResponseWriter, r *http.Request) {
+	tables := s.db.Tables()
+	response := make([]tableResponse, 0, len(tables))
+	for _, table := range tables {
+		if table.View {
+			response = append(response, tableResponse{Name: table.Name, Type: "view"})
+		} else {
+			response = append(response, tableResponse{Name: table.Name, Type: "table", Rows: &table.Rows})
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	schema, err := s.db.Schema(r.Context(), name)
+	if errors.Is(err, jql.ErrTableNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schemaResponse{Name: name, Columns: newColumns(schema)})
+}
+
+func newColumns(schema []jql.Column) []column {
+	columns := make([]column, 0, len(schema))
+	for _, c := range schema {
+		columns = append(columns, column{Name: c.Name, Type: c.Type})
+	}
+	return columns
+}
+
+// errorStatus is the status of a failed request. Statements that do not
+// run are bad requests.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
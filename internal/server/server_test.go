@@ -0,0 +1,244 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kotsmile/jql"
+)
+
+func newTestServer(t *testing.T, options Options) *httptest.Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"people.json": `[{"id": 1, "name": "ann", "tags": ["a"]}, {"id": 2, "name": "bob", "tags": []}]`,
+		"pets.json":   `[{"owner": 1, "pet": "cat"}]`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := jql.Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	server := httptest.NewServer(New(db, options))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, accept, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func Test_Server(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    Options
+		method     string
+		path       string
+		accept     string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "query",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "select name, tags from people order by id"}`,
+			wantStatus: http.StatusOK,
+			want: `{"columns":[{"name":"name","type":"string"},{"name":"tags","type":"array"}],` +
+				`"rows":[["ann",["a"]],["bob",[]]]}` + "\n",
+		},
+		{
+			name:       "query without rows",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "select id from people where id > 5"}`,
+			wantStatus: http.StatusOK,
+			want:       `{"columns":[{"name":"id","type":"number"}],"rows":[]}` + "\n",
+		},
+		{
+			name:       "ndjson",
+			method:     http.MethodPost,
+			path:       "/query",
+			accept:     "application/x-ndjson",
+			body:       `{"query": "select p.id, pet from people p left join pets on pets.owner = p.id order by p.id;"}`,
+			wantStatus: http.StatusOK,
+			want:       `{"columns":[{"name":"id","type":"number"},{"name":"pet","type":"string"}]}` + "\n[1,\"cat\"]\n[2,null]\n",
+		},
+		{
+			name:       "insert",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "insert into pets (owner, pet) values (2, 'dog'), (2, 'eel')"}`,
+			wantStatus: http.StatusOK,
+			want:       `{"rows_affected":2}` + "\n",
+		},
+		{
+			name:       "query with arguments",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "select name from people where id = ? or name = ?", "args": [2, "nobody"]}`,
+			wantStatus: http.StatusOK,
+			want:       `{"columns":[{"name":"name","type":"string"}],"rows":[["bob"]]}` + "\n",
+		},
+		{
+			name:       "insert with arguments",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "insert into pets (owner, pet) values ($1, $2)", "args": [1, "dog"]}`,
+			wantStatus: http.StatusOK,
+			want:       `{"rows_affected":1}` + "\n",
+		},
+		{
+			name:       "transaction",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "BEGIN"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       `{"error":"transactions are not supported over http"}` + "\n",
+		},
+		{
+			name:       "load",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "load '/etc/passwd' as secrets"}`,
+			wantStatus: http.StatusForbidden,
+			want:       `{"error":"'load', 'save', 'reload' and 'set' are not supported over http"}` + "\n",
+		},
+		{
+			name:       "set",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "set timeout 0"}`,
+			wantStatus: http.StatusForbidden,
+			want:       `{"error":"'load', 'save', 'reload' and 'set' are not supported over http"}` + "\n",
+		},
+		{
+			name:       "missing argument",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "select name from people where id = ?"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "insert when read-only",
+			options:    Options{ReadOnly: true},
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "delete from pets"}`,
+			wantStatus: http.StatusForbidden,
+			want:       `{"error":"server is read-only"}` + "\n",
+		},
+		{
+			name:       "wrong query",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{"query": "select nothing from people"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing query",
+			method:     http.MethodPost,
+			path:       "/query",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			want:       `{"error":"missing query"}` + "\n",
+		},
+		{
+			name:       "query with get",
+			method:     http.MethodGet,
+			path:       "/query",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "tables",
+			method:     http.MethodGet,
+			path:       "/tables",
+			wantStatus: http.StatusOK,
+			want:       `[{"name":"people","type":"table","rows":2},{"name":"pets","type":"table","rows":1}]` + "\n",
+		},
+		{
+			name:       "schema",
+			method:     http.MethodGet,
+			path:       "/tables/people/schema",
+			wantStatus: http.StatusOK,
+			want:       `{"name":"people","columns":[{"name":"id","type":"number"},{"name":"name","type":"string"},{"name":"tags","type":"array"}]}` + "\n",
+		},
+		{
+			name:       "schema of a missing table",
+			method:     http.MethodGet,
+			path:       "/tables/nothing/schema",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.options)
+
+			status, body := request(t, server, tt.method, tt.path, tt.accept, tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", status, tt.wantStatus, body)
+			}
+			if tt.want != "" && body != tt.want {
+				t.Errorf("body = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ServerTimeout(t *testing.T) {
+	rows := make([]string, 0, 3000)
+	for i := range 3000 {
+		rows = append(rows, fmt.Sprintf(`{"id": %d}`, i))
+	}
+	filename := filepath.Join(t.TempDir(), "big.json")
+	if err := os.WriteFile(filename, []byte("["+strings.Join(rows, ",")+"]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := jql.Open(filename)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	server := httptest.NewServer(New(db, Options{Timeout: 10 * time.Millisecond}))
+	defer server.Close()
+
+	// the join compares every pair of rows, which takes far longer
+	status, body := request(t, server, http.MethodPost, "/query", "",
+		`{"query": "select count(*) from big a join big b on a.id + b.id < 0"}`)
+	if status != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d, body %s", status, http.StatusGatewayTimeout, body)
+	}
+}
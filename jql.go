@@ -0,0 +1,164 @@
+// Package jql queries json files with SQL from Go programs. A DB holds the
+// tables loaded from json files, which queries return typed rows of.
+//
+//	db, err := jql.Open("people.json")
+//	...
+//	rows, err := db.Query(ctx, "select name, age from people where age > ?;", 30)
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		var name string
+//		var age int
+//		if err := rows.Scan(&name, &age); err != nil {
+//			...
+//		}
+//	}
+//	err = rows.Err()
+package jql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/internal/lexer"
+	"github.com/kotsmile/jql/internal/parser"
+	"github.com/kotsmile/jql/util"
+)
+
+var (
+	ErrMultipleStatements = errors.New("more than one statement")
+	ErrNotQuery           = engine.ErrNotQuery
+	ErrTableNotFound      = engine.ErrTableNotFound
+	ErrArgumentCount      = engine.ErrArgumentCount
+	ErrArgumentType       = engine.ErrArgumentType
+)
+
+// DB is a database of tables loaded from json files. It is safe for use
+// by several goroutines.
+type DB struct {
+	engine *engine.Engine
+	logger util.Logger
+}
+
+// Open creates a database and loads the given json files and directories
+// into it, as Load does.
+func Open(paths ...string) (*DB, error) {
+	db := &DB{
+		engine: engine.New(io.Discard),
+		logger: util.NewLogger(false),
+	}
+
+	for _, path := range paths {
+		if err := db.Load(path); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Load loads a json file as a table named after the file, or every json
+// file of a directory.
+func (db *DB) Load(path string) error {
+	return db.engine.LoadPath(context.Background(), path)
+}
+
+// LoadAs loads a json file as the table name.
+func (db *DB) LoadAs(filename, name string) error {
+	if err := db.engine.LoadTable(context.Background(), filename, name); err != nil {
+		return fmt.Errorf("failed to load '%s': %w", filename, err)
+	}
+	return nil
+}
+
+// Table describes a loaded table or a view.
+type Table struct {
+	Name string
+	View bool
+	// Rows is the number of rows of a table, and zero for views
+	Rows int
+}
+
+// Tables lists the tables and views of the database, by name.
+func (db *DB) Tables() []Table {
+	infos := db.engine.Tables()
+	tables := make([]Table, 0, len(infos))
+	for _, info := range infos {
+		tables = append(tables, Table{Name: info.Name, View: info.View, Rows: info.Rows})
+	}
+	return tables
+}
+
+// Schema returns the columns of a table or a view. Views run their query
+// to find them.
+func (db *DB) Schema(ctx context.Context, name string) ([]Column, error) {
+	schema, err := db.engine.Schema(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return newColumns(schema), nil
+}
+
+// Query runs a single 'select' or 'with' statement with its placeholders
+// bound to args. The rows are computed while they are read and must be
+// closed.
+func (db *DB) Query(ctx context.Context, query string, args ...any) (*Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(ctx, args...)
+}
+
+// ReadTable returns every row of a table or a view. Unlike a 'select' built
+// from the name, it takes any name a table can be loaded as.
+func (db *DB) ReadTable(ctx context.Context, name string) (*Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.engine.QueryTable(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, cursor), nil
+}
+
+// Exec runs statements that change the database, like 'insert' or 'create
+// view', in order, and returns the number of rows they inserted, updated or
+// deleted. Statements after a failed one are not run. Only a single
+// statement can take args.
+func (db *DB) Exec(ctx context.Context, query string, args ...any) (int, error) {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	return stmt.Exec(ctx, args...)
+}
+
+// parse parses the statements of a query. The last statement does not
+// need to end with ';'.
+func (db *DB) parse(query string) ([]*parser.AstNode, error) {
+	query = strings.TrimSpace(query)
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+
+	l := lexer.New(db.logger)
+	l.Lex(query)
+	statements, err := parser.New(l, db.logger).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(statements) == 0 {
+		return nil, parser.ErrEmptyCommand
+	}
+	return statements, nil
+}
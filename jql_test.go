@@ -0,0 +1,402 @@
+package jql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const peopleJSON = `[
+	{"id": 1, "name": "ann", "age": 31, "tags": ["a", "b"], "address": {"city": "Oslo"}},
+	{"id": 2, "name": "bob", "age": 25, "tags": [], "address": {"city": "Rome"}},
+	{"id": 3, "name": "cid", "age": null, "tags": ["c"], "address": null}
+]`
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "people.json"), []byte(peopleJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pets.json"), []byte(`[{"owner": 1, "pet": "cat"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return db
+}
+
+func Test_QueryColumns(t *testing.T) {
+	db := openTestDB(t)
+
+	rows, err := db.Query(context.Background(), "select name, age, tags, address, age > 30 as old, null as nothing from people")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	want := []Column{
+		{Name: "name", Type: StringType},
+		{Name: "age", Type: NumberType},
+		{Name: "tags", Type: ArrayType},
+		{Name: "address", Type: ObjectType},
+		{Name: "old", Type: BooleanType},
+		{Name: "nothing", Type: NullType},
+	}
+	if !reflect.DeepEqual(rows.Columns(), want) {
+		t.Errorf("Columns() = %v, want %v", rows.Columns(), want)
+	}
+}
+
+func Test_Scan(t *testing.T) {
+	db := openTestDB(t)
+
+	rows, err := db.Query(context.Background(),
+		"select p.name, p.age, p.tags, p.address, pet, cast('2024-01-02T03:04:05Z' as timestamp) as ts "+
+			"from people p left join pets on pets.owner = p.id order by p.id;")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	type address struct {
+		City string `json:"city"`
+	}
+	type row struct {
+		name    string
+		age     *int
+		tags    []string
+		address *address
+		pet     sql.NullString
+		ts      time.Time
+	}
+
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.name, &r.age, &r.tags, &r.address, &r.pet, &r.ts); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	age := func(n int) *int { return &n }
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []row{
+		{"ann", age(31), []string{"a", "b"}, &address{City: "Oslo"}, sql.NullString{String: "cat", Valid: true}, ts},
+		{"bob", age(25), []string{}, &address{City: "Rome"}, sql.NullString{}, ts},
+		{"cid", nil, []string{"c"}, nil, sql.NullString{}, ts},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+}
+
+func Test_ScanStruct(t *testing.T) {
+	db := openTestDB(t)
+
+	rows, err := db.Query(context.Background(), "select id, name as full_name, age, 'x' as ignored from people where id = 1;")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	type person struct {
+		ID      int
+		Name    string `jql:"full_name"`
+		Age     float64
+		Ignored string `jql:"-"`
+		Extra   string
+	}
+
+	if !rows.Next() {
+		t.Fatalf("Next() = false, err = %v", rows.Err())
+	}
+	var got person
+	if err := rows.ScanStruct(&got); err != nil {
+		t.Fatalf("ScanStruct() error = %v", err)
+	}
+	want := person{ID: 1, Name: "ann", Age: 31}
+	if got != want {
+		t.Errorf("ScanStruct() = %+v, want %+v", got, want)
+	}
+	if rows.Next() {
+		t.Errorf("Next() = true after the last row")
+	}
+}
+
+func Test_ScanErrors(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		name    string
+		cmd     string
+		dest    func() []any
+		wantErr error
+	}{
+		{
+			name:    "null into a string",
+			cmd:     "select age from people where id = 3;",
+			dest:    func() []any { return []any{new(string)} },
+			wantErr: ErrScanNull,
+		},
+		{
+			name:    "fraction into an integer",
+			cmd:     "select age / 2 from people where id = 2;",
+			dest:    func() []any { return []any{new(int)} },
+			wantErr: ErrScanType,
+		},
+		{
+			name:    "number into a bool",
+			cmd:     "select age from people where id = 1;",
+			dest:    func() []any { return []any{new(bool)} },
+			wantErr: ErrScanType,
+		},
+		{
+			name:    "wrong number of destinations",
+			cmd:     "select id, name from people where id = 1;",
+			dest:    func() []any { return []any{new(int)} },
+			wantErr: ErrColumnCount,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query(context.Background(), tt.cmd)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			defer rows.Close()
+
+			if !rows.Next() {
+				t.Fatalf("Next() = false, err = %v", rows.Err())
+			}
+			if err := rows.Scan(tt.dest()...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Scan() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_QueryErrors(t *testing.T) {
+	db := openTestDB(t)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		cmd     string
+		wantErr error
+	}{
+		{
+			name:    "several statements",
+			ctx:     context.Background(),
+			cmd:     "select id from people; select id from pets;",
+			wantErr: ErrMultipleStatements,
+		},
+		{
+			name:    "not a query",
+			ctx:     context.Background(),
+			cmd:     "delete from people;",
+			wantErr: ErrNotQuery,
+		},
+		{
+			name:    "canceled context",
+			ctx:     canceled,
+			cmd:     "select id from people;",
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Query(tt.ctx, tt.cmd)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Query() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Exec(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	n, err := db.Exec(ctx, "insert into pets (owner, pet) values (2, 'dog'), (3, 'eel'); create view owners as select distinct owner from pets")
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Exec() = %d, want 2", n)
+	}
+
+	rows, err := db.Query(ctx, "select count(*) from owners;")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("Next() = false, err = %v", rows.Err())
+	}
+	if err := rows.Scan(&n); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("count = %d, want 3", n)
+	}
+}
+
+func Test_Prepare(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	insert, err := db.Prepare("insert into pets (owner, pet) values (?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if insert.NumInput() != 2 {
+		t.Errorf("NumInput() = %d, want 2", insert.NumInput())
+	}
+	for _, pet := range []string{"dog", "o'possum"} {
+		if n, err := insert.Exec(ctx, 2, pet); err != nil || n != 1 {
+			t.Fatalf("Exec() = %d, %v, want 1", n, err)
+		}
+	}
+
+	rows, err := db.Query(ctx, "select pet from pets where owner = $1 and pet <> $2 order by pet", 2, "cat")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var pet string
+		if err := rows.Scan(&pet); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, pet)
+	}
+	if want := []string{"dog", "o'possum"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pets = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr error
+	}{
+		{
+			name: "missing argument",
+			run: func() error {
+				_, err := insert.Exec(ctx, 2)
+				return err
+			},
+			wantErr: ErrArgumentCount,
+		},
+		{
+			name: "unsupported argument",
+			run: func() error {
+				_, err := db.Query(ctx, "select pet from pets where owner = ?", struct{}{})
+				return err
+			},
+			wantErr: ErrArgumentType,
+		},
+		{
+			name: "placeholders in several statements",
+			run: func() error {
+				_, err := db.Prepare("delete from pets where owner = ?; select pet from pets")
+				return err
+			},
+			wantErr: ErrMultipleStatements,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_TablesAndSchema(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := db.Exec(ctx, "create view owners as select owner, pet from pets"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	wantTables := []Table{
+		{Name: "owners", View: true},
+		{Name: "people", Rows: 3},
+		{Name: "pets", Rows: 1},
+	}
+	if got := db.Tables(); !reflect.DeepEqual(got, wantTables) {
+		t.Errorf("Tables() = %v, want %v", got, wantTables)
+	}
+
+	schema, err := db.Schema(ctx, "owners")
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	wantSchema := []Column{{Name: "owner", Type: NumberType}, {Name: "pet", Type: StringType}}
+	if !reflect.DeepEqual(schema, wantSchema) {
+		t.Errorf("Schema() = %v, want %v", schema, wantSchema)
+	}
+
+	if _, err := db.Schema(ctx, "nothing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("Schema() error = %v, want %v", err, ErrTableNotFound)
+	}
+}
+
+func Test_ReadTable(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"my-data", "from", "it's"} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "data.json")
+			if err := os.WriteFile(filename, []byte(`[{"a": 1}, {"a": 2}]`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.LoadAs(filename, name); err != nil {
+				t.Fatalf("LoadAs() error = %v", err)
+			}
+
+			rows, err := db.ReadTable(ctx, name)
+			if err != nil {
+				t.Fatalf("ReadTable() error = %v", err)
+			}
+			defer rows.Close()
+
+			if want := []Column{{Name: "a", Type: NumberType}}; !reflect.DeepEqual(rows.Columns(), want) {
+				t.Errorf("Columns() = %v, want %v", rows.Columns(), want)
+			}
+			n := 0
+			for rows.Next() {
+				n++
+			}
+			if err := rows.Err(); err != nil || n != 2 {
+				t.Errorf("rows = %d, %v, want 2", n, err)
+			}
+		})
+	}
+
+	if _, err := db.ReadTable(ctx, "nothing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("ReadTable() error = %v, want %v", err, ErrTableNotFound)
+	}
+}
@@ -0,0 +1,132 @@
+package jql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/kotsmile/jql/internal/engine"
+)
+
+var ErrRowsClosed = errors.New("rows are closed")
+
+// Type is the type of a column.
+type Type string
+
+const (
+	NumberType    Type = "number"
+	StringType    Type = "string"
+	BooleanType   Type = "boolean"
+	ArrayType     Type = "array"
+	ObjectType    Type = "object"
+	TimestampType Type = "timestamp"
+	// NullType is the type of columns that only hold nulls
+	NullType Type = "null"
+	// MixedType is the type of columns holding values of several types
+	MixedType Type = "mixed"
+)
+
+// ScanType is the Go type of the values of the type, which Rows.Scan
+// stores into *any.
+func (t Type) ScanType() reflect.Type {
+	switch t {
+	case NumberType:
+		return reflect.TypeFor[float64]()
+	case StringType:
+		return reflect.TypeFor[string]()
+	case BooleanType:
+		return reflect.TypeFor[bool]()
+	case ArrayType:
+		return reflect.TypeFor[[]any]()
+	case ObjectType:
+		return reflect.TypeFor[map[string]any]()
+	case TimestampType:
+		return reflect.TypeFor[time.Time]()
+	}
+	return reflect.TypeFor[any]()
+}
+
+// Column describes a column of the result of a query.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Rows is the result of a query, read one row at a time:
+//
+//	for rows.Next() {
+//		if err := rows.Scan(&a, &b); err != nil {
+//			...
+//		}
+//	}
+//	if err := rows.Err(); err != nil {
+//		...
+//	}
+type Rows struct {
+	ctx     context.Context
+	cursor  *engine.Cursor
+	columns []Column
+	values  []any
+	err     error
+	closed  bool
+}
+
+func newRows(ctx context.Context, cursor *engine.Cursor) *Rows {
+	return &Rows{ctx: ctx, cursor: cursor, columns: newColumns(cursor.Columns())}
+}
+
+func newColumns(schema []engine.Column) []Column {
+	columns := make([]Column, 0, len(schema))
+	for _, column := range schema {
+		columns = append(columns, Column{Name: column.Name, Type: Type(column.Type)})
+	}
+	return columns
+}
+
+func (r *Rows) Columns() []Column {
+	return r.columns
+}
+
+// Next moves to the next row, returning false when no rows are left or
+// reading failed, which Err tells apart. The rows are closed after the
+// last one.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		r.Close()
+		return false
+	}
+
+	values, ok, err := r.cursor.Next()
+	if err != nil || !ok {
+		r.err = err
+		r.Close()
+		return false
+	}
+	r.values = values
+	return true
+}
+
+// Values returns the values of the current row: float64, string, bool,
+// time.Time, []any, map[string]any or nil.
+func (r *Rows) Values() []any {
+	return r.values
+}
+
+// Err returns the error that stopped Next, if any.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close releases the rows. It is safe to call more than once.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.cursor.Close()
+}
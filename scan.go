@@ -0,0 +1,218 @@
+package jql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoRow         = errors.New("no current row")
+	ErrColumnCount   = errors.New("wrong number of scan destinations")
+	ErrScanNull      = errors.New("cannot scan null")
+	ErrScanType      = errors.New("cannot scan value")
+	ErrNotStructDest = errors.New("scan destination must be a pointer to a struct")
+)
+
+// Scanner is implemented by types that scan values themselves, like the
+// nullable types of database/sql.
+type Scanner interface {
+	Scan(value any) error
+}
+
+// Scan copies the values of the current row into dest, one pointer per
+// column. Numbers scan into any integer or float type, as long as they fit,
+// timestamps into time.Time or strings, and arrays and objects into slices,
+// maps or structs, as json.Unmarshal would decode them. Nulls scan into
+// pointers, which are set to nil, into *any and into Scanners.
+func (r *Rows) Scan(dest ...any) error {
+	if r.values == nil || r.closed {
+		return ErrNoRow
+	}
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("%w: %d for %d columns", ErrColumnCount, len(dest), len(r.values))
+	}
+
+	for i, d := range dest {
+		if err := assign(d, r.values[i]); err != nil {
+			return fmt.Errorf("column '%s': %w", r.columns[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// ScanStruct copies the values of the current row into the fields of the
+// struct dest points to. A field takes the column named by its `jql` tag,
+// or else the column whose name matches the field name regardless of case.
+// Fields tagged `jql:"-"`, unexported fields and fields without a column
+// are left as they are, as are columns without a field.
+func (r *Rows) ScanStruct(dest any) error {
+	if r.values == nil || r.closed {
+		return ErrNoRow
+	}
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w, got %T", ErrNotStructDest, dest)
+	}
+	v = v.Elem()
+
+	fields := structFields(v.Type())
+	for i, column := range r.columns {
+		index, ok := fields[column.Name]
+		if !ok {
+			index, ok = fields[strings.ToLower(column.Name)]
+		}
+		if !ok {
+			continue
+		}
+
+		field := v.Field(index)
+		if err := assign(field.Addr().Interface(), r.values[i]); err != nil {
+			return fmt.Errorf("column '%s': %w", column.Name, err)
+		}
+	}
+	return nil
+}
+
+// structFields maps the column names of the fields of a struct type to
+// their index. Tags are matched exactly and field names in lower case.
+func structFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("jql")
+		switch tag {
+		case "-":
+		case "":
+			if _, ok := fields[strings.ToLower(field.Name)]; !ok {
+				fields[strings.ToLower(field.Name)] = i
+			}
+		default:
+			fields[tag] = i
+		}
+	}
+	return fields
+}
+
+// assign stores a value of a row into the variable dest points to.
+func assign(dest any, value any) error {
+	switch d := dest.(type) {
+	case Scanner:
+		return d.Scan(value)
+	case *any:
+		*d = value
+		return nil
+	}
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("%w into %T: not a pointer", ErrScanType, dest)
+	}
+	return assignValue(v.Elem(), value)
+}
+
+func assignValue(dest reflect.Value, value any) error {
+	if dest.Kind() == reflect.Pointer {
+		if value == nil {
+			dest.SetZero()
+			return nil
+		}
+		if dest.IsNil() {
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+		return assignValue(dest.Elem(), value)
+	}
+	if dest.Kind() == reflect.Interface && dest.NumMethod() == 0 {
+		if value == nil {
+			dest.SetZero()
+		} else {
+			dest.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+	if value == nil {
+		return fmt.Errorf("%w into %s", ErrScanNull, dest.Type())
+	}
+
+	switch v := value.(type) {
+	case float64:
+		switch dest.Kind() {
+		case reflect.Float32, reflect.Float64:
+			dest.SetFloat(v)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v != math.Trunc(v) || math.Abs(v) >= 1<<63 || dest.OverflowInt(int64(v)) {
+				return fmt.Errorf("%w %v into %s", ErrScanType, v, dest.Type())
+			}
+			dest.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v != math.Trunc(v) || v < 0 || v >= 1<<64 || dest.OverflowUint(uint64(v)) {
+				return fmt.Errorf("%w %v into %s", ErrScanType, v, dest.Type())
+			}
+			dest.SetUint(uint64(v))
+			return nil
+		case reflect.String:
+			dest.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+			return nil
+		}
+	case string:
+		switch {
+		case dest.Kind() == reflect.String:
+			dest.SetString(v)
+			return nil
+		case dest.Type() == reflect.TypeFor[[]byte]():
+			dest.SetBytes([]byte(v))
+			return nil
+		}
+	case bool:
+		switch dest.Kind() {
+		case reflect.Bool:
+			dest.SetBool(v)
+			return nil
+		case reflect.String:
+			dest.SetString(strconv.FormatBool(v))
+			return nil
+		}
+	case time.Time:
+		switch {
+		case dest.Type() == reflect.TypeFor[time.Time]():
+			dest.Set(reflect.ValueOf(v))
+			return nil
+		case dest.Kind() == reflect.String:
+			dest.SetString(v.Format(time.RFC3339Nano))
+			return nil
+		}
+	case []any, map[string]any:
+		// nested values are decoded like json, which also takes their
+		// encoding for strings and byte slices
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case dest.Kind() == reflect.String:
+			dest.SetString(string(b))
+			return nil
+		case dest.Type() == reflect.TypeFor[[]byte]():
+			dest.SetBytes(b)
+			return nil
+		}
+		if err := json.Unmarshal(b, dest.Addr().Interface()); err != nil {
+			return fmt.Errorf("%w of type %T into %s: %w", ErrScanType, value, dest.Type(), err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w of type %T into %s", ErrScanType, value, dest.Type())
+}
@@ -0,0 +1,83 @@
+package jql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kotsmile/jql/internal/engine"
+	"github.com/kotsmile/jql/internal/parser"
+)
+
+// Stmt is a parsed query, which runs many times with its placeholders bound
+// to different arguments. Placeholders are '?', numbered in the order they
+// appear, or '$1', '$2' and so on. It is safe for use by several goroutines.
+type Stmt struct {
+	db         *DB
+	statements []*parser.AstNode
+	inputs     int
+}
+
+// Prepare parses a query for later runs. A query of several statements
+// cannot have placeholders.
+func (db *DB) Prepare(query string) (*Stmt, error) {
+	statements, err := db.parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := parser.Placeholders(statements[0])
+	if len(statements) > 1 {
+		for _, statement := range statements {
+			if parser.Placeholders(statement) > 0 {
+				return nil, fmt.Errorf("%w with placeholders", ErrMultipleStatements)
+			}
+		}
+	}
+	return &Stmt{db: db, statements: statements, inputs: inputs}, nil
+}
+
+// NumInput returns the number of arguments the statement takes.
+func (s *Stmt) NumInput() int {
+	return s.inputs
+}
+
+// Query runs the statement, which must be a single 'select' or 'with'
+// statement, as DB.Query does.
+func (s *Stmt) Query(ctx context.Context, args ...any) (*Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.statements) > 1 {
+		return nil, ErrMultipleStatements
+	}
+
+	statement, err := engine.Bind(s.statements[0], args)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := s.db.engine.Query(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, cursor), nil
+}
+
+// Exec runs the statements as DB.Exec does.
+func (s *Stmt) Exec(ctx context.Context, args ...any) (int, error) {
+	total := 0
+	for _, statement := range s.statements {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		statement, err := engine.Bind(statement, args)
+		if err != nil {
+			return total, err
+		}
+		n, err := s.db.engine.Exec(ctx, statement)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
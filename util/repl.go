@@ -0,0 +1,75 @@
+package util
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Repl reads commands from r, one per line, and runs them until r ends. An
+// interrupt cancels the context of the running command and returns to the
+// prompt, or discards the line being typed at the prompt.
+func Repl(r io.Reader, w io.Writer, prompt string, run func(ctx context.Context, cmd string)) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	lines := readLines(r)
+	for {
+		fmt.Fprint(w, prompt)
+		select {
+		case <-interrupts:
+			fmt.Fprintln(w)
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(w)
+				return
+			}
+			runInterruptible(interrupts, strings.TrimSpace(line), run)
+		}
+	}
+}
+
+// readLines sends the lines of r, closing the channel when r ends. The
+// last line does not need to end with a newline.
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err == nil || line != "" {
+				lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// runInterruptible runs a command with a context canceled by the first
+// interrupt while it runs.
+func runInterruptible(interrupts <-chan os.Signal, cmd string, run func(ctx context.Context, cmd string)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	run(ctx, cmd)
+}
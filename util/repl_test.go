@@ -0,0 +1,27 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Repl(t *testing.T) {
+	var out strings.Builder
+	var got []string
+	Repl(strings.NewReader("select 1;\n  \n load 'a.json';"), &out, "> ", func(ctx context.Context, cmd string) {
+		if err := ctx.Err(); err != nil {
+			t.Errorf("ctx.Err() = %v", err)
+		}
+		got = append(got, cmd)
+	})
+
+	want := []string{"select 1;", "", "load 'a.json';"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if out.String() != "> > > > \n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,40 @@
+package util
+
+import "strings"
+
+// Tree draws a tree with one line per node, as in
+//
+//	root
+//	├── child
+//	│   └── grandchild
+//	└── child
+func Tree[T any](root T, label func(T) string, children func(T) []T) string {
+	var sb strings.Builder
+	treeHelper(&sb, root, label, children, "", true, true)
+	return sb.String()
+}
+
+func treeHelper[T any](sb *strings.Builder, n T, label func(T) string, children func(T) []T, prefix string, isLast bool, isRoot bool) {
+	sb.WriteString(prefix)
+	if isRoot {
+	} else if isLast {
+		sb.WriteString("└── ")
+	} else {
+		sb.WriteString("├── ")
+	}
+	sb.WriteString(label(n))
+	sb.WriteString("\n")
+
+	childPrefix := prefix
+	if isRoot {
+	} else if isLast {
+		childPrefix += "    "
+	} else {
+		childPrefix += "│   "
+	}
+
+	nodes := children(n)
+	for i, child := range nodes {
+		treeHelper(sb, child, label, children, childPrefix, i == len(nodes)-1, false)
+	}
+}
@@ -2,7 +2,7 @@ package util
 
 func At[T any](slice []T, index int) (T, bool) {
 	var t T
-	if index < len(slice) {
+	if index >= 0 && index < len(slice) {
 		t = slice[index]
 		return t, true
 	}
@@ -41,3 +41,44 @@ func Test_NextUtil(t *testing.T) {
 		})
 	}
 }
+
+type treeNode struct {
+	name     string
+	children []*treeNode
+}
+
+func Test_Tree(t *testing.T) {
+	root := &treeNode{name: "root", children: []*treeNode{
+		{name: "a", children: []*treeNode{{name: "a1"}, {name: "a2"}}},
+		{name: "b", children: []*treeNode{{name: "b1"}}},
+	}}
+
+	got := Tree(root, func(n *treeNode) string { return n.name }, func(n *treeNode) []*treeNode { return n.children })
+	want := "root\n├── a\n│   ├── a1\n│   └── a2\n└── b\n    └── b1\n"
+	if got != want {
+		t.Errorf("Tree() = %q, want %q", got, want)
+	}
+}
+
+func Test_At(t *testing.T) {
+	tests := []struct {
+		name  string
+		slice []int
+		index int
+		want  int
+		ok    bool
+	}{
+		{name: "ok", slice: []int{1, 2, 3}, index: 2, want: 3, ok: true},
+		{name: "past the end", slice: []int{1}, index: 1},
+		{name: "negative", slice: []int{1}, index: -1},
+		{name: "last of empty", slice: []int{}, index: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := At(tt.slice, tt.index)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("At() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}